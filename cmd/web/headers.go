@@ -0,0 +1,26 @@
+package main
+
+// contentSecurityPolicy only allows the inline scripts marked with the nonce of the response,
+// which replaces {nonce}, inline styles are allowed for the colors set by the templates
+const contentSecurityPolicy = "default-src 'self'; " +
+  "script-src 'self' 'nonce-{nonce}' https://cdn.jsdelivr.net https://unpkg.com; " +
+  "style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://unpkg.com; " +
+  "font-src 'self' data: https://cdn.jsdelivr.net; " +
+  "img-src 'self' data:; " +
+  "connect-src 'self'; " +
+  "object-src 'none'; " +
+  "base-uri 'self'; " +
+  "form-action 'self'; " +
+  "frame-ancestors 'none'"
+
+// securityHeaders are set on every response
+var securityHeaders = map[string]string{
+  "Content-Security-Policy": contentSecurityPolicy,
+  "X-Frame-Options":         "DENY",
+  "X-Content-Type-Options":  "nosniff",
+  "Referrer-Policy":         "strict-origin-when-cross-origin",
+  "Permissions-Policy":      "camera=(), microphone=(), geolocation=(), payment=()",
+}
+
+// strictTransportSecurity is only sent in production, browsers keep using https for max-age seconds
+const strictTransportSecurity = "max-age=63072000; includeSubDomains"
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/amartin3659/VacationHomeRental/internal/repository"
+)
+
+// holdDuration is how long a bungalow is held for a guest filling in the reservation form
+const holdDuration = 15 * time.Minute
+
+// sweepHolds releases expired booking holds in the background every interval
+func sweepHolds(db repository.DatabaseRepo, interval time.Duration) {
+  go func() {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for range ticker.C {
+      releaseExpiredHolds(db)
+    }
+  }()
+}
+
+// releaseExpiredHolds deletes the expired holds, so their dates are free again
+func releaseExpiredHolds(db repository.DatabaseRepo) {
+  n, err := db.DeleteExpiredHolds(context.Background())
+  if err != nil {
+    app.Logger.Error("Could not release expired holds", "error", err)
+    return
+  }
+  if n > 0 {
+    app.Logger.Info("Released expired holds", "count", n)
+  }
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
+	"github.com/amartin3659/VacationHomeRental/internal/repository"
+)
+
+type holdsRepo struct {
+  repository.DatabaseRepo
+  released int64
+  err error
+}
+
+func (h *holdsRepo) DeleteExpiredHolds(ctx context.Context) (int64, error) {
+  return h.released, h.err
+}
+
+func TestReleaseExpiredHolds(t *testing.T) {
+  var logBuf bytes.Buffer
+  oldLogger := app.Logger
+  app.Logger = logging.New(&logBuf, slog.LevelInfo, false)
+  defer func() {
+    app.Logger = oldLogger
+  }()
+
+  releaseExpiredHolds(&holdsRepo{released: 2})
+  if !strings.Contains(logBuf.String(), "msg=\"Released expired holds\" count=2") {
+    t.Error("Expected released holds to be logged, but they were not")
+  }
+
+  releaseExpiredHolds(&holdsRepo{err: errors.New("some error")})
+  if !strings.Contains(logBuf.String(), "Could not release expired holds") {
+    t.Error("Expected error to be logged, but it was not")
+  }
+}
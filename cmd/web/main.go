@@ -3,7 +3,7 @@ package main
 import (
 	"encoding/gob"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"time"
@@ -12,43 +12,117 @@ import (
 	env "github.com/amartin3659/VacationHomeRental/cmd"
 	"github.com/amartin3659/VacationHomeRental/internal/config"
 	"github.com/amartin3659/VacationHomeRental/internal/driver"
+	"github.com/amartin3659/VacationHomeRental/internal/forms"
 	"github.com/amartin3659/VacationHomeRental/internal/handlers"
 	"github.com/amartin3659/VacationHomeRental/internal/helpers"
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
+	"github.com/amartin3659/VacationHomeRental/internal/metrics"
 	"github.com/amartin3659/VacationHomeRental/internal/models"
 	"github.com/amartin3659/VacationHomeRental/internal/render"
+	"github.com/amartin3659/VacationHomeRental/internal/spam"
 )
 
 const portNumber = ":8080"
 const versionNumber = "v1.0.170"
 
+// logLevelEnv names the environment variable holding the log level, e.g. LOG_LEVEL=debug
+const logLevelEnv = "LOG_LEVEL"
+
+// timeZoneEnv names the environment variable holding the time zone of the bungalows, e.g. TIME_ZONE=Europe/Berlin,
+// used to determine the current date for bookings
+const timeZoneEnv = "TIME_ZONE"
+
 var app config.AppConfig
 var session *scs.SessionManager
-var infoLog *log.Logger
-var errorLog *log.Logger
 
 func main() {
 	db, err := run()
 	if err != nil {
-		log.Fatal(err)
+		fatal("Could not start", err)
 	}
 
 	defer db.SQL.Close()
 	defer close(app.MailChan)
 
-	fmt.Println("Starting Email listener")
-	listenForMail(func(m models.MailData){})
+	app.Logger.Info("Starting email listener")
+	listenForMail(handlers.Repo.DB, func(m models.MailData){})
+
+	app.Logger.Info("Starting hold sweeper")
+	sweepHolds(handlers.Repo.DB, time.Minute)
+
+	app.Logger.Info("Starting trash purger")
+	purgeTrash(handlers.Repo.DB, time.Hour, app.ReservationRetention)
+
+	if app.MetricsAddr != "" {
+		app.Logger.Info("Serving metrics", "addr", app.MetricsAddr)
+		go func() {
+			err := http.ListenAndServe(app.MetricsAddr, metricsRoutes())
+			if err != nil {
+				fatal("Could not serve metrics", err)
+			}
+		}()
+	} else if app.MetricsToken == "" {
+		app.Logger.Warn("Not serving metrics, set " + metricsAddrEnv + " or " + metricsTokenEnv)
+	}
+
+	certFile, keyFile := os.Getenv(tlsCertFileEnv), os.Getenv(tlsKeyFileEnv)
+	if certFile == "" || keyFile == "" {
+		app.Logger.Info("Starting server", "port", portNumber)
+
+		src := &http.Server{
+			Addr:    portNumber,
+			Handler: routes(&app),
+		}
+
+		err = src.ListenAndServe()
+		if err != nil {
+			fatal("Could not serve http", err)
+		}
+		return
+	}
+
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		fatal("Error loading TLS certificate", err)
+	}
+	certs.watch(certReloadInterval)
+
+	publicPort, err := parsePublicPort(os.Getenv(httpsPublicPortEnv))
+	if err != nil {
+		fatal("Invalid https port", err)
+	}
 
-	fmt.Println("Starting server on port: ", portNumber)
+	app.Logger.Info("Redirecting to https", "port", portNumber, "https_port", publicPort)
+	go func() {
+		err := http.ListenAndServe(portNumber, redirectToHTTPS(publicPort))
+		if err != nil {
+			fatal("Could not serve the https redirect", err)
+		}
+	}()
+
+	app.Logger.Info("Starting https server", "port", httpsPortNumber)
 
 	src := &http.Server{
-		Addr:    portNumber,
-		Handler: routes(&app),
+		Addr:      httpsPortNumber,
+		Handler:   routes(&app),
+		TLSConfig: tlsConfig(certs),
 	}
 
-	err = src.ListenAndServe()
+	err = src.ListenAndServeTLS("", "")
 	if err != nil {
-		log.Fatalln(err)
+		fatal("Could not serve https", err)
+	}
+}
+
+// fatal logs err and stops the application, like log.Fatal but through the structured logger
+func fatal(msg string, err error) {
+	logger := app.Logger
+	if logger == nil {
+		// run failed before the logger was set up
+		logger = slog.Default()
 	}
+	logger.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func run() (*driver.DB, error) {
@@ -59,45 +133,82 @@ func run() (*driver.DB, error) {
 	gob.Register(models.Bungalow{})
 	gob.Register(models.BungalowRestriction{})
 	gob.Register(models.Restriction{})
-  gob.Register(map[string]int{})
 
-	mailChan := make(chan models.MailData)
+	mailChan := make(chan models.MailData, mailQueueSize)
 	app.MailChan = mailChan
 
+	app.Metrics = metrics.New()
+	app.Metrics.CollectMailQueue(func() int { return len(app.MailChan) })
+	app.MetricsAddr = os.Getenv(metricsAddrEnv)
+	app.MetricsToken = os.Getenv(metricsTokenEnv)
+
 	app.InProduction = false
   app.UseCache = false
+	app.HoldDuration = holdDuration
 
-	infoLog = log.New(os.Stdout, "[INFO]\t", log.Ldate|log.Ltime)
-	app.InfoLog = infoLog
+	// records are written as json in production, so they can be collected, and as text otherwise
+	level, err := logging.ParseLevel(os.Getenv(logLevelEnv))
+	if err != nil {
+		return nil, err
+	}
+	app.Logger = logging.New(os.Stdout, level, app.InProduction)
+	slog.SetDefault(app.Logger)
 
-	errorLog = log.New(os.Stdout, "[ERROR]\t", log.Ldate|log.Ltime|log.Lshortfile)
-	app.ErrorLog = errorLog
+	app.ReservationRetention, err = parseRetention(os.Getenv(reservationRetentionEnv))
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := spamKey(os.Getenv(spamKeyEnv), app.InProduction)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := loadSpamPolicies(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	app.Spam = spam.New(key, policies)
+
+	app.TrustedProxies, err = loadTrustedProxies(os.Getenv(trustedProxiesEnv))
+	if err != nil {
+		return nil, err
+	}
+	app.RateLimits, err = loadRateLimits(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := loadTimeZone(os.Getenv(timeZoneEnv))
+	if err != nil {
+		return nil, err
+	}
+	forms.SetLocation(loc)
 
 	session = scs.New()
 	session.Lifetime = 24 * time.Hour
 	session.Cookie.Persist = true
 	session.Cookie.SameSite = http.SameSiteLaxMode
-	session.Cookie.Secure = app.InProduction
+	// the cookie is marked Secure by SecureCookies for requests sent over https
+	session.Cookie.Secure = false
 
 	app.Session = session
 
 	// connecting to database
-	log.Println("Conecting to database...")
+	app.Logger.Info("Connecting to database")
 	env.SetPass()
   connectionString := fmt.Sprintf("host=localhost port=5432 dbname=mygowebapp user=%s password=%s", env.GetUser(), env.GetPass())
 	connStr := fmt.Sprintf(connectionString)
 	db, err := driver.ConnectSQL(connStr)
 	if err != nil {
-		log.Fatal("No connection to database! Terminating ...")
-		return nil, err
+		return nil, fmt.Errorf("no connection to database: %w", err)
 	}
-	log.Println("Successfully connected to database.")
+	app.Logger.Info("Connected to database")
+	app.Metrics.CollectDBStats(db.SQL)
 
 	// create a template cache
 	tc, err := render.CreateTemplateCache()
 	if err != nil {
-		log.Fatalln("Error creating template cache", err)
-		return nil, err
+		return nil, fmt.Errorf("error creating template cache: %w", err)
 	}
 
 	app.TemplateCache = tc
@@ -110,3 +221,15 @@ func run() (*driver.DB, error) {
 	helpers.NewHelpers(&app)
 	return db, nil
 }
+
+// loadTimeZone returns the time zone named, or the time zone of the server if name is empty
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", timeZoneEnv, err)
+	}
+	return loc, nil
+}
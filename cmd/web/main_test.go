@@ -1,11 +1,18 @@
 package main
 
 import (
+	"log/slog"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
+	"github.com/amartin3659/VacationHomeRental/internal/metrics"
 )
 
 func TestMain(m *testing.M) {
+  app.Logger = logging.New(os.Stdout, slog.LevelInfo, false)
+  app.Metrics = metrics.New()
   os.Exit(m.Run())
 }
 
@@ -15,3 +22,20 @@ func TestRun(t *testing.T) {
     t.Error("Test did not pass: FAIL")
   }
 }
+
+func TestLoadTimeZone(t *testing.T) {
+  loc, err := loadTimeZone("")
+  if err != nil || loc != time.Local {
+    t.Errorf("Expected the local time zone by default, but got %v and %v", loc, err)
+  }
+
+  loc, err = loadTimeZone("Europe/Berlin")
+  if err != nil || loc.String() != "Europe/Berlin" {
+    t.Errorf("Expected Europe/Berlin, but got %v and %v", loc, err)
+  }
+
+  _, err = loadTimeZone("Europe/Atlantis")
+  if err == nil {
+    t.Error("Expected an error for an unknown time zone, but got none")
+  }
+}
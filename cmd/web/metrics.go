@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// the metrics are served on the address given in the environment when it is set, e.g. METRICS_ADDR=127.0.0.1:9090,
+// and require the token given in the environment as bearer token when it is set. The site only serves them
+// without an address of their own and with a token, so they are never public
+const (
+  metricsAddrEnv  = "METRICS_ADDR"
+  metricsTokenEnv = "METRICS_TOKEN"
+)
+
+// metricsPath is the path prometheus scrapes the metrics from
+const metricsPath = "/metrics"
+
+// metricsHandler serves the metrics in the prometheus text format, to requests sending the token if one is set
+func metricsHandler(token string) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+    if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+      w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+      http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+      return
+    }
+    app.Metrics.ServeHTTP(w, r)
+  })
+}
+
+// knownMethods are the methods recorded by name, others are recorded as OTHER so clients cannot add series
+var knownMethods = map[string]bool{
+  http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+  http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Instrument records how long every request takes by method, route pattern and status
+func Instrument(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+    start := time.Now()
+    ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+    defer func() {
+      // the pattern is complete once the request has been routed through all sub routers
+      route := "unmatched"
+      if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+        route = rctx.RoutePattern()
+      }
+      method := r.Method
+      if !knownMethods[method] {
+        method = "OTHER"
+      }
+      status := ww.Status()
+      if status == 0 {
+        status = http.StatusOK
+      }
+      app.Metrics.HTTPRequestDuration.Observe(time.Since(start).Seconds(), method, route, strconv.Itoa(status))
+    }()
+    next.ServeHTTP(ww, r)
+  })
+}
+
+// metricsRoutes serves only the metrics, for the admin port
+func metricsRoutes() http.Handler {
+  mux := http.NewServeMux()
+  mux.Handle(metricsPath, metricsHandler(app.MetricsToken))
+  return mux
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/amartin3659/VacationHomeRental/internal/metrics"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMetricsHandler(t *testing.T) {
+  defer func(m *metrics.Metrics) {
+    app.Metrics = m
+  }(app.Metrics)
+  app.Metrics = metrics.New()
+
+  var tokenTests = []struct {
+    name          string
+    token         string
+    authorization string
+    expected      int
+  }{
+    {"open", "", "", http.StatusOK},
+    {"valid-token", "secret", "Bearer secret", http.StatusOK},
+    {"missing-token", "secret", "", http.StatusUnauthorized},
+    {"wrong-token", "secret", "Bearer guess", http.StatusUnauthorized},
+  }
+
+  for _, e := range tokenTests {
+    req := httptest.NewRequest("GET", metricsPath, nil)
+    if e.authorization != "" {
+      req.Header.Set("Authorization", e.authorization)
+    }
+    rr := httptest.NewRecorder()
+    metricsHandler(e.token).ServeHTTP(rr, req)
+
+    if rr.Code != e.expected {
+      t.Errorf("for %s expected status code %d, but got %d", e.name, e.expected, rr.Code)
+    }
+    if e.expected == http.StatusOK && !strings.Contains(rr.Body.String(), "# TYPE http_request_duration_seconds histogram") {
+      t.Errorf("for %s expected the metrics, but got %q", e.name, rr.Body.String())
+    }
+  }
+}
+
+func TestMetricsRoute(t *testing.T) {
+  defer func(addr, token string) {
+    app.MetricsAddr, app.MetricsToken = addr, token
+  }(app.MetricsAddr, app.MetricsToken)
+  defer func(s *scs.SessionManager) {
+    session = s
+  }(session)
+  session = scs.New()
+
+  var routeTests = []struct {
+    name     string
+    addr     string
+    token    string
+    expected int
+  }{
+    {"without-token", "", "", http.StatusNotFound},
+    {"with-token", "", "secret", http.StatusUnauthorized},
+    {"own-address", "127.0.0.1:9090", "secret", http.StatusNotFound},
+  }
+
+  for _, e := range routeTests {
+    app.MetricsAddr, app.MetricsToken = e.addr, e.token
+    req := httptest.NewRequest("GET", metricsPath, nil)
+    rr := httptest.NewRecorder()
+    routes(&app).ServeHTTP(rr, req)
+
+    if rr.Code != e.expected {
+      t.Errorf("for %s expected status code %d, but got %d", e.name, e.expected, rr.Code)
+    }
+  }
+}
+
+func TestInstrument(t *testing.T) {
+  defer func(m *metrics.Metrics) {
+    app.Metrics = m
+  }(app.Metrics)
+  app.Metrics = metrics.New()
+
+  mux := chi.NewRouter()
+  mux.Use(Instrument)
+  mux.Route("/admin", func(mux chi.Router){
+    mux.Get("/reservations/{src}/{id}/show", func(w http.ResponseWriter, r *http.Request) {
+      w.WriteHeader(http.StatusNotFound)
+    })
+  })
+  mux.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+  for _, target := range []string{"/admin/reservations/all/1/show", "/admin/reservations/new/2/show"} {
+    mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+  }
+  mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+  mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+  mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/", nil))
+
+  var countTests = []struct {
+    method   string
+    route    string
+    status   string
+    expected uint64
+  }{
+    {"GET", "/admin/reservations/{src}/{id}/show", "404", 2},
+    {"GET", "/", "200", 1},
+    {"GET", "unmatched", "404", 1},
+    {"OTHER", "unmatched", "405", 1},
+  }
+
+  for _, e := range countTests {
+    if n := app.Metrics.HTTPRequestDuration.Count(e.method, e.route, e.status); n != e.expected {
+      t.Errorf("for %s %s %s expected %d requests, but got %d", e.method, e.route, e.status, e.expected, n)
+    }
+  }
+}
@@ -1,9 +1,19 @@
 package main
 
 import (
+	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/amartin3659/VacationHomeRental/internal/handlers"
 	"github.com/amartin3659/VacationHomeRental/internal/helpers"
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
+	"github.com/amartin3659/VacationHomeRental/internal/ratelimit"
+	"github.com/amartin3659/VacationHomeRental/internal/spam"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/justinas/nosurf"
 )
 
@@ -14,13 +24,120 @@ func NoSurf(next http.Handler) http.Handler {
   csrfHandler.SetBaseCookie(http.Cookie{
     HttpOnly: true,
     Path: "/",
-    Secure: app.InProduction,
     SameSite: http.SameSiteLaxMode,
   })
 
   return csrfHandler
 }
 
+// requestIDHeader carries the id of a request, it is taken over from trusted proxies and sent back in the response
+const requestIDHeader = "X-Request-ID"
+
+// RequestID gives every request an id which is added to the records logged with its context,
+// so the records of one request can be found together
+func RequestID(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+    id := r.Header.Get(requestIDHeader)
+    if !ratelimit.FromTrustedProxy(r, app.TrustedProxies) || !logging.ValidRequestID(id) {
+      id = logging.NewRequestID()
+    }
+    w.Header().Set(requestIDHeader, id)
+    next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+  })
+}
+
+// AccessLog logs every request with the status and size of its response and how long it took
+func AccessLog(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+    start := time.Now()
+    ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+    defer func() {
+      status := ww.Status()
+      if status == 0 {
+        status = http.StatusOK
+      }
+      app.Logger.InfoContext(r.Context(), "request",
+        "method", r.Method,
+        "path", r.URL.Path,
+        "status", status,
+        "bytes", ww.BytesWritten(),
+        "duration_ms", float64(time.Since(start).Microseconds())/1000,
+        "ip", ratelimit.ClientIP(r, app.TrustedProxies),
+      )
+    }()
+    next.ServeHTTP(ww, r)
+  })
+}
+
+// SecureHeaders sets the security headers of every response and creates the nonce
+// of its Content-Security-Policy, HSTS is only sent in production
+func SecureHeaders(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+    nonce, err := helpers.NewNonce()
+    if err != nil {
+      http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+      return
+    }
+
+    for name, value := range securityHeaders {
+      w.Header().Set(name, strings.ReplaceAll(value, "{nonce}", nonce))
+    }
+    if app.InProduction {
+      w.Header().Set("Strict-Transport-Security", strictTransportSecurity)
+    }
+
+    next.ServeHTTP(w, helpers.WithNonce(r, nonce))
+  })
+}
+
+// SecureCookies marks the cookies of responses to requests sent over https as Secure,
+// so the session and CSRF cookies follow the actual scheme
+func SecureCookies(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+    if !isHTTPS(r) {
+      next.ServeHTTP(w, r)
+      return
+    }
+    sw := &secureCookieWriter{ResponseWriter: w}
+    next.ServeHTTP(sw, r)
+    // when nothing is written, e.g. the session cookie scs adds after the handler returns, the server writes the header
+    if !sw.wroteHeader {
+      sw.secure()
+    }
+  })
+}
+
+// secureCookieWriter adds the Secure attribute to the cookies set before the header is written
+type secureCookieWriter struct {
+  http.ResponseWriter
+  wroteHeader bool
+}
+
+// secure adds the Secure attribute to the cookies in the header
+func (s *secureCookieWriter) secure() {
+  cookies := s.Header()["Set-Cookie"]
+  for i, c := range cookies {
+    if !strings.Contains(strings.ToLower(c), "; secure") {
+      cookies[i] = c + "; Secure"
+    }
+  }
+}
+
+func (s *secureCookieWriter) WriteHeader(status int) {
+  if !s.wroteHeader {
+    s.wroteHeader = true
+    s.secure()
+  }
+  s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *secureCookieWriter) Write(b []byte) (int, error) {
+  if !s.wroteHeader {
+    s.WriteHeader(http.StatusOK)
+  }
+  return s.ResponseWriter.Write(b)
+}
+
 // SessionLoad loads, saves session data for each request
 func SessionLoad(next http.Handler) http.Handler {
   return session.LoadAndSave(next)
@@ -37,3 +154,67 @@ func Auth(next http.Handler) http.Handler {
     next.ServeHTTP(w, r)
   })
 }
+
+// SpamGuard rejects submissions of a public form which look like they are sent by a bot
+// and counts them for the dashboard
+func SpamGuard(form string) func(http.Handler) http.Handler {
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+      if app.Spam == nil || r.ParseForm() != nil {
+        next.ServeHTTP(w, r)
+        return
+      }
+
+      ip := ratelimit.ClientIP(r, app.TrustedProxies)
+      reason := app.Spam.Check(form, ip, r.PostForm)
+      if reason == "" {
+        next.ServeHTTP(w, r)
+        return
+      }
+
+      app.Logger.InfoContext(r.Context(), "Rejected spam submission", "form", form, "ip", ip, "reason", reason)
+      err := handlers.Repo.DB.InsertSpamRejection(r.Context(), form, reason)
+      if err != nil {
+        app.Logger.ErrorContext(r.Context(), "Could not count spam rejection", "error", err)
+      }
+
+      policy, _ := app.Spam.Policy(form)
+      if policy.Redirect == "" {
+        output, _ := json.MarshalIndent(map[string]interface{}{
+          "ok":      false,
+          "message": spam.Message(reason),
+        }, "", "    ")
+        w.Header().Set("Content-Type", "application/json")
+        w.Write(output)
+        return
+      }
+
+      session.Put(r.Context(), "error", spam.Message(reason))
+      http.Redirect(w, r, policy.Redirect, http.StatusSeeOther)
+    })
+  }
+}
+
+// RateLimit answers requests beyond the limits of rule with 429 Too Many Requests,
+// requests are counted per client address
+func RateLimit(rule ratelimit.Rule) func(http.Handler) http.Handler {
+  return RateLimitBy(rule, func(r *http.Request) string {
+    return ratelimit.ClientIP(r, app.TrustedProxies)
+  })
+}
+
+// RateLimitBy limits requests like RateLimit, but counts them per key returned by key
+func RateLimitBy(rule ratelimit.Rule, key ratelimit.KeyFunc) func(http.Handler) http.Handler {
+  limiter := ratelimit.New(rule)
+  return func(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+      ok, retry := limiter.Allow(key(r))
+      if !ok {
+        w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+        http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+        return
+      }
+      next.ServeHTTP(w, r)
+    })
+  }
+}
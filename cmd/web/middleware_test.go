@@ -1,14 +1,28 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/alexedwards/scs/v2"
 	"github.com/amartin3659/VacationHomeRental/internal/config"
+	"github.com/amartin3659/VacationHomeRental/internal/handlers"
+	"github.com/amartin3659/VacationHomeRental/internal/helpers"
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
+	"github.com/amartin3659/VacationHomeRental/internal/ratelimit"
+	"github.com/amartin3659/VacationHomeRental/internal/repository"
+	"github.com/amartin3659/VacationHomeRental/internal/spam"
 )
 
 func TestNoSurf(t *testing.T) {
@@ -83,3 +97,242 @@ func getCtx(req *http.Request) context.Context {
 	}
 	return ctx
 }
+
+type spamRepo struct {
+  repository.DatabaseRepo
+  form, reason string
+}
+
+func (s *spamRepo) InsertSpamRejection(ctx context.Context, form, reason string) error {
+  s.form, s.reason = form, reason
+  return nil
+}
+
+func TestSpamGuard(t *testing.T) {
+  oldSpam, oldLogger, oldRepo := app.Spam, app.Logger, handlers.Repo
+  defer func() {
+    app.Spam, app.Logger, handlers.Repo = oldSpam, oldLogger, oldRepo
+  }()
+
+  repo := &spamRepo{}
+  handlers.NewHandlers(&handlers.Repository{App: &app, DB: repo})
+  app.Logger = logging.New(io.Discard, slog.LevelInfo, false)
+  app.Spam = spam.New([]byte("secret"), map[string]spam.Policy{
+    "reservation-json": {Honeypot: true},
+  })
+
+  var called bool
+  h := SpamGuard("reservation-json")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    called = true
+  }))
+
+  // case #1: a person leaves the honeypot empty
+  req := httptest.NewRequest("POST", "/reservation-json", strings.NewReader("start=2036-02-01"))
+  req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+  rr := httptest.NewRecorder()
+  h.ServeHTTP(rr, req)
+  if !called || repo.reason != "" {
+    t.Error("Expected the submission to be passed on, but it was rejected")
+  }
+
+  // case #2: a bot fills in the honeypot
+  called = false
+  req = httptest.NewRequest("POST", "/reservation-json", strings.NewReader("start=2036-02-01&website=spam"))
+  req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+  rr = httptest.NewRecorder()
+  h.ServeHTTP(rr, req)
+  if called {
+    t.Error("Expected the submission to be rejected, but it was passed on")
+  }
+  if repo.form != "reservation-json" || repo.reason != spam.ReasonHoneypot {
+    t.Errorf("Expected the rejection to be counted, but got %q for %q", repo.reason, repo.form)
+  }
+  if !strings.Contains(rr.Body.String(), `"ok": false`) {
+    t.Errorf("Expected a json rejection, but got %s", rr.Body.String())
+  }
+}
+
+func TestRateLimit(t *testing.T) {
+  var myH myHandler
+  h := RateLimit(ratelimit.Rule{Requests: 1, Per: time.Minute, Burst: 2})(&myH)
+
+  for i, expected := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+    req := httptest.NewRequest("GET", "/reservation-json", nil)
+    rr := httptest.NewRecorder()
+    h.ServeHTTP(rr, req)
+    if rr.Code != expected {
+      t.Errorf("Expected request %d to get status code %d, but got %d", i+1, expected, rr.Code)
+    }
+    if expected == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "60" {
+      t.Errorf("Expected to be asked to retry after 60 seconds, but got %q", rr.Header().Get("Retry-After"))
+    }
+  }
+
+  // another client has its own bucket
+  req := httptest.NewRequest("GET", "/reservation-json", nil)
+  req.RemoteAddr = "198.51.100.1:1234"
+  rr := httptest.NewRecorder()
+  h.ServeHTTP(rr, req)
+  if rr.Code != http.StatusOK {
+    t.Errorf("Expected another client not to be limited, but got status code %d", rr.Code)
+  }
+}
+
+func TestSecureHeaders(t *testing.T) {
+  defer func(inProduction bool) {
+    app.InProduction = inProduction
+  }(app.InProduction)
+
+  var nonce string
+  h := SecureHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    nonce = helpers.Nonce(r)
+  }))
+
+  app.InProduction = false
+  rr := httptest.NewRecorder()
+  h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+  if nonce == "" || !strings.Contains(rr.Header().Get("Content-Security-Policy"), "'nonce-"+nonce+"'") {
+    t.Errorf("Expected the policy to allow the nonce %q of the request, but got %q", nonce, rr.Header().Get("Content-Security-Policy"))
+  }
+  if rr.Header().Get("X-Frame-Options") != "DENY" {
+    t.Error("Expected the security headers to be set, but they were not")
+  }
+  if rr.Header().Get("Strict-Transport-Security") != "" {
+    t.Error("Expected no HSTS header outside of production, but got one")
+  }
+
+  previous := nonce
+  app.InProduction = true
+  rr = httptest.NewRecorder()
+  h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+  if nonce == previous {
+    t.Error("Expected a new nonce for every request, but got the same")
+  }
+  if rr.Header().Get("Strict-Transport-Security") == "" {
+    t.Error("Expected a HSTS header in production, but got none")
+  }
+}
+
+func TestSecureCookies(t *testing.T) {
+  defer func(trusted []*net.IPNet) {
+    app.TrustedProxies = trusted
+  }(app.TrustedProxies)
+  app.TrustedProxies, _ = ratelimit.ParseNetworks([]string{"127.0.0.1/32"})
+
+  h := SecureCookies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+    w.Write([]byte("ok"))
+  }))
+
+  var cookieTests = []struct {
+    name       string
+    tls        bool
+    remoteAddr string
+    proto      string
+    secure     bool
+  }{
+    {"http", false, "203.0.113.7:1234", "", false},
+    {"https", true, "203.0.113.7:1234", "", true},
+    {"trusted-proxy", false, "127.0.0.1:1234", "https", true},
+    {"untrusted-proxy", false, "203.0.113.7:1234", "https", false},
+  }
+
+  for _, e := range cookieTests {
+    req := httptest.NewRequest("GET", "/", nil)
+    req.RemoteAddr = e.remoteAddr
+    if e.tls {
+      req.TLS = &tls.ConnectionState{}
+    }
+    if e.proto != "" {
+      req.Header.Set("X-Forwarded-Proto", e.proto)
+    }
+    rr := httptest.NewRecorder()
+    h.ServeHTTP(rr, req)
+
+    cookies := rr.Result().Cookies()
+    if len(cookies) != 1 || cookies[0].Secure != e.secure {
+      t.Errorf("for %s expected a cookie with Secure %v, but got %v", e.name, e.secure, rr.Header()["Set-Cookie"])
+    }
+  }
+
+  // the session cookie of a handler writing nothing is set by scs after the handler returns
+  sessions := scs.New()
+  h = SecureCookies(sessions.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    sessions.Put(r.Context(), "user_id", 1)
+  })))
+
+  req := httptest.NewRequest("GET", "/", nil)
+  req.TLS = &tls.ConnectionState{}
+  rr := httptest.NewRecorder()
+  h.ServeHTTP(rr, req)
+
+  cookies := rr.Result().Cookies()
+  if len(cookies) != 1 || !cookies[0].Secure {
+    t.Errorf("expected a Secure session cookie, but got %v", rr.Header()["Set-Cookie"])
+  }
+}
+
+func TestRequestID(t *testing.T) {
+  defer func(trusted []*net.IPNet) {
+    app.TrustedProxies = trusted
+  }(app.TrustedProxies)
+  app.TrustedProxies, _ = ratelimit.ParseNetworks([]string{"127.0.0.1/32"})
+
+  var seen string
+  h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    seen = logging.RequestID(r.Context())
+  }))
+
+  var idTests = []struct {
+    name       string
+    remoteAddr string
+    header     string
+    kept       bool
+  }{
+    {"new", "203.0.113.7:1234", "", false},
+    {"trusted-proxy", "127.0.0.1:1234", "abc-123", true},
+    {"untrusted-proxy", "203.0.113.7:1234", "abc-123", false},
+    {"invalid", "127.0.0.1:1234", "abc 123\n", false},
+  }
+
+  for _, e := range idTests {
+    req := httptest.NewRequest("GET", "/", nil)
+    req.RemoteAddr = e.remoteAddr
+    if e.header != "" {
+      req.Header.Set(requestIDHeader, e.header)
+    }
+    rr := httptest.NewRecorder()
+    h.ServeHTTP(rr, req)
+
+    if seen == "" || rr.Header().Get(requestIDHeader) != seen {
+      t.Errorf("for %s expected the request id %q to be sent back, but got %q", e.name, seen, rr.Header().Get(requestIDHeader))
+    }
+    if (seen == e.header) != e.kept {
+      t.Errorf("for %s expected the sent id to be kept %v, but got %q", e.name, e.kept, seen)
+    }
+  }
+}
+
+func TestAccessLog(t *testing.T) {
+  var logBuf bytes.Buffer
+  defer func(logger *slog.Logger) {
+    app.Logger = logger
+  }(app.Logger)
+  app.Logger = logging.New(&logBuf, slog.LevelInfo, false)
+
+  h := RequestID(AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusNotFound)
+    w.Write([]byte("not here"))
+  })))
+
+  req := httptest.NewRequest("GET", "/missing", nil)
+  rr := httptest.NewRecorder()
+  h.ServeHTTP(rr, req)
+
+  output := logBuf.String()
+  for _, field := range []string{"method=GET", "path=/missing", "status=404", "bytes=8", "duration_ms=", "request_id=" + rr.Header().Get(requestIDHeader)} {
+    if !strings.Contains(output, field) {
+      t.Errorf("Expected the access log to contain %q, but got %q", field, output)
+    }
+  }
+}
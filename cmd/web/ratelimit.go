@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/amartin3659/VacationHomeRental/internal/ratelimit"
+)
+
+// trustedProxiesEnv names the environment variable listing the reverse proxies whose X-Forwarded-For header
+// is used to find the client address, comma separated in CIDR notation, e.g. TRUSTED_PROXIES="10.0.0.0/8"
+const trustedProxiesEnv = "TRUSTED_PROXIES"
+
+// defaultTrustedProxies are trusted when TRUSTED_PROXIES is not set, a proxy on the same host
+var defaultTrustedProxies = []string{"127.0.0.1/32", "::1/128"}
+
+// rateLimitEnvPrefix prefixes the environment variables changing the rate limit of a route group, e.g.
+// RATE_LIMIT_LOGIN="requests=10,per=1m" or RATE_LIMIT_AVAILABILITY="burst=20"
+const rateLimitEnvPrefix = "RATE_LIMIT_"
+
+// loadTrustedProxies returns the networks listed in s, or the default ones if s is empty
+func loadTrustedProxies(s string) ([]*net.IPNet, error) {
+  cidrs := defaultTrustedProxies
+  if strings.TrimSpace(s) != "" {
+    cidrs = nil
+    for _, cidr := range strings.Split(s, ",") {
+      cidrs = append(cidrs, strings.TrimSpace(cidr))
+    }
+  }
+
+  networks, err := ratelimit.ParseNetworks(cidrs)
+  if err != nil {
+    return nil, fmt.Errorf("%s: %w", trustedProxiesEnv, err)
+  }
+  return networks, nil
+}
+
+// rateLimitEnv returns the name of the environment variable changing the rate limit of group
+func rateLimitEnv(group string) string {
+  return rateLimitEnvPrefix + strings.ToUpper(group)
+}
+
+// loadRateLimits returns the default rate limits changed by the settings getenv returns for each group
+func loadRateLimits(getenv func(string) string) (map[string]ratelimit.Rule, error) {
+  rules := make(map[string]ratelimit.Rule, len(rateLimits))
+  for group, rule := range rateLimits {
+    rule, err := ratelimit.ParseRule(getenv(rateLimitEnv(group)), rule)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", rateLimitEnv(group), err)
+    }
+    rules[group] = rule
+  }
+  return rules, nil
+}
+
+// rateLimits are the default rate limits of the route groups, each client address has its own bucket per group.
+// The page limit applies to every request, static files included, the availability limit to the availability
+// searches, each of them querying the database, and the login limit to login attempts
+var rateLimits = map[string]ratelimit.Rule{
+  "page":         {Requests: 300, Per: time.Minute, Burst: 100},
+  "availability": {Requests: 20, Per: time.Minute, Burst: 10},
+  "login":        {Requests: 5, Per: time.Minute, Burst: 5},
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadTrustedProxies(t *testing.T) {
+  networks, err := loadTrustedProxies("")
+  if err != nil || len(networks) != len(defaultTrustedProxies) {
+    t.Errorf("Expected the default proxies, but got %v and error %v", networks, err)
+  }
+
+  networks, err = loadTrustedProxies("10.0.0.0/8, 192.0.2.1/32")
+  if err != nil || len(networks) != 2 || networks[0].String() != "10.0.0.0/8" {
+    t.Errorf("Expected the listed proxies, but got %v and error %v", networks, err)
+  }
+
+  if _, err := loadTrustedProxies("10.0.0.0"); err == nil {
+    t.Error("Expected an error for an address without prefix length, but got none")
+  }
+}
+
+func TestLoadRateLimits(t *testing.T) {
+  env := map[string]string{
+    "RATE_LIMIT_LOGIN": "requests=10,per=1h",
+  }
+  rules, err := loadRateLimits(func(name string) string { return env[name] })
+  if err != nil {
+    t.Fatalf("Expected no error, but got %s", err)
+  }
+
+  if login := rules["login"]; login.Requests != 10 || login.Per != time.Hour || login.Burst != rateLimits["login"].Burst {
+    t.Errorf("Expected the login limit to be changed by its settings only, but got %+v", login)
+  }
+  if rules["page"] != rateLimits["page"] || rules["availability"] != rateLimits["availability"] {
+    t.Errorf("Expected the default limits for the other groups, but got %+v", rules)
+  }
+  if rateLimits["login"].Requests == 10 {
+    t.Error("Expected the default limits to be left unchanged")
+  }
+
+  env["RATE_LIMIT_PAGE"] = "burst=0"
+  if _, err := loadRateLimits(func(name string) string { return env[name] }); err == nil {
+    t.Error("Expected an error for a burst of zero, but got none")
+  }
+}
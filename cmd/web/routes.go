@@ -12,41 +12,82 @@ import (
 func routes(app *config.AppConfig) http.Handler {
   mux := chi.NewRouter()
 
+  mux.Use(RequestID)
+  mux.Use(AccessLog)
+  mux.Use(Instrument)
   mux.Use(middleware.Recoverer)
+  mux.Use(SecureHeaders)
+  mux.Use(SecureCookies)
+  mux.Use(RateLimit(app.RateLimits["page"]))
   mux.Use(NoSurf)
   mux.Use(SessionLoad)
 
   mux.Get("/", handlers.Repo.Home)
   mux.Get("/about", handlers.Repo.About)
   mux.Get("/contact", handlers.Repo.Contact)
+  mux.With(SpamGuard("contact")).Post("/contact", handlers.Repo.PostContact)
   mux.Get("/eremite", handlers.Repo.Eremite)
   mux.Get("/couple", handlers.Repo.Couple)
   mux.Get("/family", handlers.Repo.Family)
   mux.Get("/reservation", handlers.Repo.Reservation)
-  mux.Post("/reservation", handlers.Repo.PostReservation)
-  mux.Post("/reservation-json", handlers.Repo.ReservationJSON)
+  mux.Group(func(mux chi.Router){
+    mux.Use(RateLimit(app.RateLimits["availability"]))
+    mux.With(SpamGuard("reservation")).Post("/reservation", handlers.Repo.PostReservation)
+    mux.With(SpamGuard("reservation-json")).Post("/reservation-json", handlers.Repo.ReservationJSON)
+  })
   mux.Get("/choose-bungalow/{id}", handlers.Repo.ChooseBungalow)
   mux.Get("/book-bungalow", handlers.Repo.BookBungalow)
   mux.Get("/make-reservation", handlers.Repo.MakeReservation)
-  mux.Post("/make-reservation", handlers.Repo.PostMakeReservation)
+  mux.With(SpamGuard("make-reservation")).Post("/make-reservation", handlers.Repo.PostMakeReservation)
   mux.Get("/reservation-overview", handlers.Repo.ReservationOverview)
   mux.Get("/user/login", handlers.Repo.ShowLogin)
-  mux.Post("/user/login", handlers.Repo.PostShowLogin)
+  mux.With(RateLimit(app.RateLimits["login"])).Post("/user/login", handlers.Repo.PostShowLogin)
   mux.Get("/user/logout", handlers.Repo.Logout)
 
   mux.Route("/admin", func(mux chi.Router){
     mux.Use(Auth)
     mux.Get("/dashboard", handlers.Repo.AdminDashboard)
+    mux.Get("/dashboard/data", handlers.Repo.AdminDashboardJSON)
     mux.Get("/reservations-new", handlers.Repo.AdminNewReservations)
     mux.Get("/reservations-all", handlers.Repo.AdminAllReservations)
+    mux.Get("/reservations-trash", handlers.Repo.AdminTrashReservations)
     mux.Get("/reservations-calendar", handlers.Repo.AdminReservationsCalendar)
     mux.Post("/reservations-calendar", handlers.Repo.AdminPostReservationsCalendar)
+    mux.Post("/blocks", handlers.Repo.AdminPostBlock)
+    mux.Get("/delete-block/{id}/do", handlers.Repo.AdminDeleteBlock)
+    mux.Get("/audit", handlers.Repo.AdminAudit)
+    mux.Get("/inquiries", handlers.Repo.AdminInquiries)
+    mux.Get("/inquiries/{id}/show", handlers.Repo.AdminShowInquiry)
+    mux.Get("/answer-inquiry/{id}/do", handlers.Repo.AdminAnswerInquiry)
+    mux.Get("/restrictions", handlers.Repo.AdminRestrictions)
+    mux.Post("/restrictions", handlers.Repo.AdminPostRestriction)
+    mux.Get("/restrictions/{id}/show", handlers.Repo.AdminShowRestriction)
+    mux.Post("/restrictions/{id}", handlers.Repo.AdminPostShowRestriction)
+    mux.Get("/delete-restriction/{id}/do", handlers.Repo.AdminDeleteRestriction)
+    mux.Get("/stay-rules", handlers.Repo.AdminStayRules)
+    mux.Post("/stay-rules", handlers.Repo.AdminPostStayRule)
+    mux.Get("/stay-rules/{id}/show", handlers.Repo.AdminShowStayRule)
+    mux.Post("/stay-rules/{id}", handlers.Repo.AdminPostShowStayRule)
+    mux.Get("/delete-stay-rule/{id}/do", handlers.Repo.AdminDeleteStayRule)
+    mux.Get("/reservations/new", handlers.Repo.AdminCreateReservation)
+    mux.Post("/reservations/new", handlers.Repo.AdminPostCreateReservation)
+    mux.Get("/reservations/export", handlers.Repo.AdminExportReservations)
+    mux.Get("/reservations/import", handlers.Repo.AdminImportReservations)
+    mux.Post("/reservations/import", handlers.Repo.AdminPostImportReservations)
     mux.Get("/reservations/{src}/{id}/show", handlers.Repo.AdminShowReservation)
     mux.Post("/reservations/{src}/{id}", handlers.Repo.AdminPostShowReservation)
+    mux.Post("/reservations/{src}/{id}/notes", handlers.Repo.AdminPostReservationNote)
+    mux.Post("/reservations/{src}/{id}/messages", handlers.Repo.AdminPostReservationMessage)
     mux.Get("/process-reservation/{src}/{id}/do", handlers.Repo.AdminProcessReservation)
     mux.Get("/delete-reservation/{src}/{id}/do", handlers.Repo.AdminDeleteReservation)
+    mux.Get("/restore-reservation/{id}/do", handlers.Repo.AdminRestoreReservation)
   })
 
+  // without an address of their own the metrics are served by the site, but only behind a token
+  if app.MetricsAddr == "" && app.MetricsToken != "" {
+    mux.Method(http.MethodGet, metricsPath, metricsHandler(app.MetricsToken))
+  }
+
   fileServer := http.FileServer(http.Dir("./static/"))
   mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
 
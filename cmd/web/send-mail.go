@@ -1,26 +1,53 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
+	"github.com/amartin3659/VacationHomeRental/internal/metrics"
 	"github.com/amartin3659/VacationHomeRental/internal/models"
+	"github.com/amartin3659/VacationHomeRental/internal/repository"
 	mail "github.com/xhit/go-simple-mail/v2"
 )
 
-func listenForMail(testFn func(models.MailData)) {
+// mailQueueSize is how many mails can wait to be sent before the handlers sending them block,
+// messages to guests are recorded as failed instead of waiting
+const mailQueueSize = 100
+
+// listenForMail sends the queued mails and records the delivery status of the messages to guests in db
+func listenForMail(db repository.DatabaseRepo, testFn func(models.MailData)) {
   go func() {
     for {
       msg := <-app.MailChan
-      sendMSG(msg)
+      err := sendMSG(msg)
+      if msg.MessageID > 0 {
+        updateMessageStatus(db, msg, err)
+      }
       testFn(msg)
     }
   }()
 }
 
-func sendMSG(m models.MailData, host ...string) {
+// updateMessageStatus records whether the mail of a message to a guest was handed to the mail server
+func updateMessageStatus(db repository.DatabaseRepo, m models.MailData, sendErr error) {
+  status := models.MessageSent
+  if sendErr != nil {
+    status = models.MessageFailed
+  }
+
+  ctx := logging.WithRequestID(context.Background(), m.RequestID)
+  err := db.UpdateReservationMessageStatus(ctx, m.MessageID, status)
+  if err != nil {
+    app.Logger.ErrorContext(ctx, "Could not update status of message", "message_id", m.MessageID, "error", err)
+  }
+}
+
+// sendMSG hands a mail to the mail server and returns the error if it could not
+func sendMSG(m models.MailData, host ...string) error {
   var hostString string
   if len(host) > 0 {
     hostString = host[0] 
@@ -34,10 +61,14 @@ func sendMSG(m models.MailData, host ...string) {
   server.ConnectTimeout = 10 * time.Second
   server.SendTimeout = 10 * time.Second
 
+  // the records carry the id of the request which sent the mail
+  ctx := logging.WithRequestID(context.Background(), m.RequestID)
+
   client, err := server.Connect()
   if err != nil {
-    errorLog.Println("Did not connect", err)
-    return
+    app.Logger.ErrorContext(ctx, "Did not connect", "error", err)
+    app.Metrics.MailsSent.Inc(metrics.MailFailed)
+    return err
   }
 
   email := mail.NewMSG()
@@ -47,7 +78,7 @@ func sendMSG(m models.MailData, host ...string) {
   } else {
     data, err := os.ReadFile(fmt.Sprintf("./../../static/email/templates/%s.html", m.Template))
     if err != nil {
-      errorLog.Println("Error reading file", err) 
+      app.Logger.ErrorContext(ctx, "Error reading file", "error", err)
     }
     mailTemplate := string(data)
     msgToSend := strings.Replace(mailTemplate, "[%E-MAIL-CONTENT%]", m.Content, 1)
@@ -56,8 +87,12 @@ func sendMSG(m models.MailData, host ...string) {
 
   err = email.Send(client)
   if err != nil {
-    errorLog.Println("Could not send email", err)
-  } else {
-    infoLog.Println("email sent out!")
+    app.Logger.ErrorContext(ctx, "Could not send email", "error", err)
+    app.Metrics.MailsSent.Inc(metrics.MailFailed)
+    return err
   }
+
+  app.Logger.InfoContext(ctx, "email sent out!", "to", m.To)
+  app.Metrics.MailsSent.Inc(metrics.MailSent)
+  return nil
 }
@@ -2,15 +2,31 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"log/slog"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
 	"github.com/amartin3659/VacationHomeRental/internal/models"
+	"github.com/amartin3659/VacationHomeRental/internal/repository"
 )
 
+type messagesRepo struct {
+  repository.DatabaseRepo
+  id int
+  status int
+  err error
+}
+
+func (m *messagesRepo) UpdateReservationMessageStatus(ctx context.Context, id int, status int) error {
+  m.id, m.status = id, status
+  return m.err
+}
+
 func TestListenForMail(t *testing.T) {
   var processedData models.MailData
 	mailChan := make(chan models.MailData)
@@ -25,7 +41,7 @@ func TestListenForMail(t *testing.T) {
     processedData = d 
   }
 
-  listenForMail(didRecieve)
+  listenForMail(&messagesRepo{}, didRecieve)
   mailChan <- testData
 
   time.Sleep(time.Millisecond * 10)
@@ -43,18 +59,14 @@ func TestSendMail(t *testing.T) {
 		Content: "",
 	}
   var logBuf bytes.Buffer
-  var errBuf bytes.Buffer
-  app.ErrorLog.SetOutput(&errBuf) 
-  app.InfoLog.SetOutput(&logBuf)
+  oldLogger := app.Logger
+  app.Logger = logging.New(&logBuf, slog.LevelInfo, false)
   defer func() {
-    app.ErrorLog.SetOutput(os.Stdout)
-    app.InfoLog.SetOutput(os.Stdout)
+    app.Logger = oldLogger
   }()
   sendMSG(m)
   logOutput := logBuf.String()
-  errOutput := errBuf.String()
   fmt.Println("Log Output:", logOutput)
-  fmt.Println("Error Output:", errOutput)
 
   if !strings.Contains(logOutput, "email sent out!") {
     t.Error("Error occured")
@@ -69,20 +81,16 @@ func TestNoServerConnect(t *testing.T) {
 		Content: "",
 	}
   var logBuf bytes.Buffer
-  var errBuf bytes.Buffer
-  app.ErrorLog.SetOutput(&errBuf) 
-  app.InfoLog.SetOutput(&logBuf)
+  oldLogger := app.Logger
+  app.Logger = logging.New(&logBuf, slog.LevelInfo, false)
   defer func() {
-    app.ErrorLog.SetOutput(os.Stdout)
-    app.InfoLog.SetOutput(os.Stdout)
+    app.Logger = oldLogger
   }()
   sendMSG(m, "not localhost")
   logOutput := logBuf.String()
-  errOutput := errBuf.String()
   fmt.Println("Log Output:", logOutput)
-  fmt.Println("Error Output:", errOutput)
 
-  if !strings.Contains(errOutput, "Did not connect") {
+  if !strings.Contains(logOutput, "Did not connect") {
     t.Error("Error occured")
   }
 }
@@ -96,20 +104,16 @@ func TestReadTemplate(t *testing.T) {
     Template: "basic",
 	}
   var logBuf bytes.Buffer
-  var errBuf bytes.Buffer
-  app.ErrorLog.SetOutput(&errBuf) 
-  app.InfoLog.SetOutput(&logBuf)
+  oldLogger := app.Logger
+  app.Logger = logging.New(&logBuf, slog.LevelInfo, false)
   defer func() {
-    app.ErrorLog.SetOutput(os.Stdout)
-    app.InfoLog.SetOutput(os.Stdout)
+    app.Logger = oldLogger
   }()
   sendMSG(m)
   logOutput := logBuf.String()
-  errOutput := errBuf.String()
   fmt.Println("Log Output:", logOutput)
-  fmt.Println("Error Output:", errOutput)
 
-  if strings.Contains(errOutput, "Error reading file") {
+  if strings.Contains(logOutput, "Error reading file") {
     t.Error("Error occured")
   }
 }
@@ -123,20 +127,16 @@ func TestNoTemplate(t *testing.T) {
     Template: "no-template",
 	}
   var logBuf bytes.Buffer
-  var errBuf bytes.Buffer
-  app.ErrorLog.SetOutput(&errBuf) 
-  app.InfoLog.SetOutput(&logBuf)
+  oldLogger := app.Logger
+  app.Logger = logging.New(&logBuf, slog.LevelInfo, false)
   defer func() {
-    app.ErrorLog.SetOutput(os.Stdout)
-    app.InfoLog.SetOutput(os.Stdout)
+    app.Logger = oldLogger
   }()
   sendMSG(m)
   logOutput := logBuf.String()
-  errOutput := errBuf.String()
   fmt.Println("Log Output:", logOutput)
-  fmt.Println("Error Output:", errOutput)
 
-  if !strings.Contains(errOutput, "Error reading file") {
+  if !strings.Contains(logOutput, "Error reading file") {
     t.Error("Error occured")
   }
 }
@@ -149,20 +149,42 @@ func TestFailedToSend(t *testing.T) {
 		Content: "",
 	}
   var logBuf bytes.Buffer
-  var errBuf bytes.Buffer
-  app.ErrorLog.SetOutput(&errBuf) 
-  app.InfoLog.SetOutput(&logBuf)
+  oldLogger := app.Logger
+  app.Logger = logging.New(&logBuf, slog.LevelInfo, false)
   defer func() {
-    app.ErrorLog.SetOutput(os.Stdout)
-    app.InfoLog.SetOutput(os.Stdout)
+    app.Logger = oldLogger
   }()
   sendMSG(m)
   logOutput := logBuf.String()
-  errOutput := errBuf.String()
   fmt.Println("Log Output:", logOutput)
-  fmt.Println("Error Output:", errOutput)
 
-  if !strings.Contains(errOutput, "Could not send email") {
+  if !strings.Contains(logOutput, "Could not send email") {
     t.Error("Error occured")
   }
 }
+
+func TestUpdateMessageStatus(t *testing.T) {
+  var logBuf bytes.Buffer
+  oldLogger := app.Logger
+  app.Logger = logging.New(&logBuf, slog.LevelInfo, false)
+  defer func() {
+    app.Logger = oldLogger
+  }()
+
+  repo := &messagesRepo{}
+  updateMessageStatus(repo, models.MailData{MessageID: 4}, nil)
+  if repo.id != 4 || repo.status != models.MessageSent {
+    t.Errorf("Expected message 4 to be sent, but got message %d with status %d", repo.id, repo.status)
+  }
+
+  updateMessageStatus(repo, models.MailData{MessageID: 5}, errors.New("no connection"))
+  if repo.id != 5 || repo.status != models.MessageFailed {
+    t.Errorf("Expected message 5 to have failed, but got message %d with status %d", repo.id, repo.status)
+  }
+
+  repo.err = errors.New("some error")
+  updateMessageStatus(repo, models.MailData{MessageID: 6}, nil)
+  if !strings.Contains(logBuf.String(), "Could not update status of message") {
+    t.Error("Expected error to be logged, but it was not")
+  }
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amartin3659/VacationHomeRental/internal/spam"
+)
+
+// spamKeyEnv names the environment variable holding the key the form tokens are signed with, so tokens
+// stay valid across restarts and instances. Only in development a random key is used when it is not set
+const spamKeyEnv = "SPAM_KEY"
+
+// minSpamKeyLength is the minimum length of the key, as long as the signature
+const minSpamKeyLength = 32
+
+// spamPolicyEnvPrefix prefixes the environment variables changing the policy of a form, e.g.
+// SPAM_POLICY_CONTACT="challenge=true,max_per_ip=3" or SPAM_POLICY_RESERVATION_JSON="min_fill_time=2s"
+const spamPolicyEnvPrefix = "SPAM_POLICY_"
+
+// spamKey returns the key given, or a random key in development if none is given
+func spamKey(key string, inProduction bool) ([]byte, error) {
+  if key == "" {
+    if inProduction {
+      return nil, errors.New(spamKeyEnv + " must be set in production")
+    }
+    return spam.NewKey()
+  }
+  if len(key) < minSpamKeyLength {
+    return nil, fmt.Errorf("%s must be at least %d characters long", spamKeyEnv, minSpamKeyLength)
+  }
+  return []byte(key), nil
+}
+
+// spamPolicyEnv returns the name of the environment variable changing the policy of form
+func spamPolicyEnv(form string) string {
+  return spamPolicyEnvPrefix + strings.ToUpper(strings.ReplaceAll(form, "-", "_"))
+}
+
+// loadSpamPolicies returns the default policies changed by the settings getenv returns for each form
+func loadSpamPolicies(getenv func(string) string) (map[string]spam.Policy, error) {
+  policies := make(map[string]spam.Policy, len(spamPolicies))
+  for form, p := range spamPolicies {
+    p, err := spam.ParsePolicy(getenv(spamPolicyEnv(form)), p)
+    if err != nil {
+      return nil, fmt.Errorf("%s: %w", spamPolicyEnv(form), err)
+    }
+    policies[form] = p
+  }
+  return policies, nil
+}
+
+// spamPolicies are the default bot defenses of the public forms, the challenge question is off by default
+// as the honeypot and fill time catch most bots without bothering guests
+var spamPolicies = map[string]spam.Policy{
+  "reservation": {
+    Honeypot:    true,
+    MinFillTime: 2 * time.Second,
+    MaxPerIP:    30,
+    Window:      10 * time.Minute,
+    Redirect:    "/reservation",
+  },
+  "reservation-json": {
+    MinFillTime: time.Second,
+    MaxPerIP:    30,
+    Window:      10 * time.Minute,
+  },
+  "make-reservation": {
+    Honeypot:    true,
+    MinFillTime: 5 * time.Second,
+    MaxPerIP:    10,
+    Window:      time.Hour,
+    Redirect:    "/make-reservation",
+  },
+  "contact": {
+    Honeypot:    true,
+    MinFillTime: 5 * time.Second,
+    MaxPerIP:    5,
+    Window:      time.Hour,
+    Redirect:    "/contact",
+  },
+}
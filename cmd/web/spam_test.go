@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpamKey(t *testing.T) {
+  var keyTests = []struct {
+    name         string
+    key          string
+    inProduction bool
+    expectError  bool
+  }{
+    {"given", "0123456789abcdef0123456789abcdef", true, false},
+    {"too-short", "secret", false, true},
+    {"random-in-development", "", false, false},
+    {"missing-in-production", "", true, true},
+  }
+
+  for _, e := range keyTests {
+    key, err := spamKey(e.key, e.inProduction)
+    if e.expectError {
+      if err == nil {
+        t.Errorf("for %s expected an error, but got none", e.name)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("for %s expected no error, but got %s", e.name, err)
+    }
+    if len(key) < minSpamKeyLength || (e.key != "" && string(key) != e.key) {
+      t.Errorf("for %s expected a key of at least %d bytes, but got %q", e.name, minSpamKeyLength, key)
+    }
+  }
+}
+
+func TestLoadSpamPolicies(t *testing.T) {
+  env := map[string]string{
+    "SPAM_POLICY_CONTACT":          "challenge=true,max_per_ip=3",
+    "SPAM_POLICY_RESERVATION_JSON": "min_fill_time=2s",
+  }
+  policies, err := loadSpamPolicies(func(name string) string { return env[name] })
+  if err != nil {
+    t.Fatalf("Expected no error, but got %s", err)
+  }
+
+  contact := policies["contact"]
+  if !contact.Challenge || contact.MaxPerIP != 3 || !contact.Honeypot || contact.Redirect != "/contact" {
+    t.Errorf("Expected the contact policy to be changed by its settings only, but got %+v", contact)
+  }
+  if p := policies["reservation-json"]; p.MinFillTime != 2*time.Second {
+    t.Errorf("Expected a fill time of 2s for reservation-json, but got %s", p.MinFillTime)
+  }
+  if policies["make-reservation"] != spamPolicies["make-reservation"] {
+    t.Errorf("Expected the default policy for make-reservation, but got %+v", policies["make-reservation"])
+  }
+  if spamPolicies["contact"].Challenge {
+    t.Error("Expected the default policies to be left unchanged")
+  }
+
+  env["SPAM_POLICY_CONTACT"] = "captcha=true"
+  if _, err := loadSpamPolicies(func(name string) string { return env[name] }); err == nil {
+    t.Error("Expected an error for an unknown setting, but got none")
+  }
+}
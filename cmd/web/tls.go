@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amartin3659/VacationHomeRental/internal/ratelimit"
+)
+
+const httpsPortNumber = ":8443"
+
+// httpsPublicPortEnv names the environment variable holding the port clients reach the https server on, e.g.
+// HTTPS_PUBLIC_PORT=443 when a firewall forwards it to httpsPortNumber. Plain http requests are redirected to it
+const httpsPublicPortEnv = "HTTPS_PUBLIC_PORT"
+
+// https is served when both files are given in the environment, they are reloaded when they change
+const (
+  tlsCertFileEnv = "TLS_CERT_FILE"
+  tlsKeyFileEnv  = "TLS_KEY_FILE"
+)
+
+// certReloadInterval is how often the certificate files are checked for changes
+const certReloadInterval = 10 * time.Second
+
+// certReloader serves the certificate of the key pair files, reloading it when the files change
+type certReloader struct {
+  certFile string
+  keyFile  string
+
+  mu      sync.RWMutex
+  cert    *tls.Certificate
+  modTime time.Time
+}
+
+// newCertReloader loads the certificate of the key pair files
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+  c := &certReloader{certFile: certFile, keyFile: keyFile}
+  _, err := c.reload()
+  if err != nil {
+    return nil, err
+  }
+  return c, nil
+}
+
+// reload loads the key pair again if one of its files changed since the last load and reports whether it did
+func (c *certReloader) reload() (bool, error) {
+  modTime, err := c.lastModified()
+  if err != nil {
+    return false, err
+  }
+
+  c.mu.RLock()
+  unchanged := c.cert != nil && modTime.Equal(c.modTime)
+  c.mu.RUnlock()
+  if unchanged {
+    return false, nil
+  }
+
+  cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+  if err != nil {
+    return false, err
+  }
+
+  c.mu.Lock()
+  c.cert = &cert
+  c.modTime = modTime
+  c.mu.Unlock()
+  return true, nil
+}
+
+// lastModified returns the later modification time of the two files
+func (c *certReloader) lastModified() (time.Time, error) {
+  var latest time.Time
+  for _, file := range []string{c.certFile, c.keyFile} {
+    info, err := os.Stat(file)
+    if err != nil {
+      return latest, err
+    }
+    if info.ModTime().After(latest) {
+      latest = info.ModTime()
+    }
+  }
+  return latest, nil
+}
+
+// watch reloads the certificate in the background every interval, a broken key pair keeps the old one in use
+func (c *certReloader) watch(interval time.Duration) {
+  go func() {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for range ticker.C {
+      reloaded, err := c.reload()
+      if err != nil {
+        app.Logger.Error("Could not reload TLS certificate", "error", err)
+        continue
+      }
+      if reloaded {
+        app.Logger.Info("Reloaded TLS certificate", "file", c.certFile)
+      }
+    }
+  }()
+}
+
+// GetCertificate returns the current certificate for tls.Config
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+  c.mu.RLock()
+  defer c.mu.RUnlock()
+  return c.cert, nil
+}
+
+// tlsConfig returns a configuration accepting TLS 1.2 and newer with forward secret AEAD ciphers only
+func tlsConfig(certs *certReloader) *tls.Config {
+  return &tls.Config{
+    MinVersion:       tls.VersionTLS12,
+    CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+    CipherSuites: []uint16{
+      tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+      tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+      tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+      tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+      tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+      tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+    },
+    GetCertificate: certs.GetCertificate,
+  }
+}
+
+// parsePublicPort returns the port clients reach the https server on as address, e.g. ":443",
+// or httpsPortNumber if port is empty
+func parsePublicPort(port string) (string, error) {
+  if port == "" {
+    return httpsPortNumber, nil
+  }
+  n, err := strconv.Atoi(port)
+  if err != nil || n < 1 || n > 65535 {
+    return "", fmt.Errorf("%s must be a port number, got %q", httpsPublicPortEnv, port)
+  }
+  return ":" + port, nil
+}
+
+// redirectToHTTPS redirects every request to the same url on the https port
+func redirectToHTTPS(httpsPort string) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request){
+    host, _, err := net.SplitHostPort(r.Host)
+    if err != nil {
+      host = r.Host
+    }
+    if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+      host = "[" + host + "]"
+    }
+    if httpsPort != ":443" {
+      host += httpsPort
+    }
+    target := "https://" + host + r.URL.RequestURI()
+    http.Redirect(w, r, target, http.StatusPermanentRedirect)
+  })
+}
+
+// isHTTPS reports whether a request was sent over https, directly or to a trusted reverse proxy
+func isHTTPS(r *http.Request) bool {
+  if r.TLS != nil {
+    return true
+  }
+  return ratelimit.FromTrustedProxy(r, app.TrustedProxies) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self-signed certificate for name and its key to the files
+func writeKeyPair(t *testing.T, certFile, keyFile, name string) {
+  key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+  if err != nil {
+    t.Fatal(err)
+  }
+  template := x509.Certificate{
+    SerialNumber: big.NewInt(1),
+    Subject:      pkix.Name{CommonName: name},
+    NotBefore:    time.Now(),
+    NotAfter:     time.Now().Add(time.Hour),
+  }
+  der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+  if err != nil {
+    t.Fatal(err)
+  }
+  keyDER, err := x509.MarshalECPrivateKey(key)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
+// commonName returns the name of the certificate served by the reloader
+func commonName(t *testing.T, c *certReloader) string {
+  cert, err := c.GetCertificate(&tls.ClientHelloInfo{})
+  if err != nil {
+    t.Fatal(err)
+  }
+  parsed, err := x509.ParseCertificate(cert.Certificate[0])
+  if err != nil {
+    t.Fatal(err)
+  }
+  return parsed.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+  dir := t.TempDir()
+  certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+  writeKeyPair(t, certFile, keyFile, "first")
+
+  c, err := newCertReloader(certFile, keyFile)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if name := commonName(t, c); name != "first" {
+    t.Errorf("Expected the first certificate, but got %q", name)
+  }
+
+  reloaded, err := c.reload()
+  if err != nil || reloaded {
+    t.Errorf("Expected unchanged files not to be reloaded, but got %v and %v", reloaded, err)
+  }
+
+  writeKeyPair(t, certFile, keyFile, "second")
+  later := time.Now().Add(time.Minute)
+  os.Chtimes(certFile, later, later)
+  reloaded, err = c.reload()
+  if err != nil || !reloaded {
+    t.Errorf("Expected changed files to be reloaded, but got %v and %v", reloaded, err)
+  }
+  if name := commonName(t, c); name != "second" {
+    t.Errorf("Expected the second certificate, but got %q", name)
+  }
+
+  // a broken key pair keeps the last certificate in use
+  os.WriteFile(keyFile, []byte("broken"), 0600)
+  later = later.Add(time.Minute)
+  os.Chtimes(keyFile, later, later)
+  _, err = c.reload()
+  if err == nil {
+    t.Error("Expected an error for a broken key pair, but got none")
+  }
+  if name := commonName(t, c); name != "second" {
+    t.Errorf("Expected the second certificate to stay in use, but got %q", name)
+  }
+
+  _, err = newCertReloader(filepath.Join(dir, "missing.pem"), keyFile)
+  if err == nil {
+    t.Error("Expected an error for a missing certificate, but got none")
+  }
+}
+
+func TestTLSConfig(t *testing.T) {
+  config := tlsConfig(&certReloader{})
+  if config.MinVersion != tls.VersionTLS12 {
+    t.Errorf("Expected TLS 1.2 as minimum version, but got %x", config.MinVersion)
+  }
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+  var redirectTests = []struct {
+    name      string
+    host      string
+    httpsPort string
+    expected  string
+  }{
+    {"with-port", "example.com:8080", ":8443", "https://example.com:8443/reservation?s=1"},
+    {"default-port", "example.com", ":443", "https://example.com/reservation?s=1"},
+    {"ipv6", "[::1]:8080", ":8443", "https://[::1]:8443/reservation?s=1"},
+  }
+
+  for _, e := range redirectTests {
+    req := httptest.NewRequest("GET", "/reservation?s=1", nil)
+    req.Host = e.host
+    rr := httptest.NewRecorder()
+    redirectToHTTPS(e.httpsPort).ServeHTTP(rr, req)
+    if rr.Code != http.StatusPermanentRedirect || rr.Header().Get("Location") != e.expected {
+      t.Errorf("for %s expected redirect to %s, but got %d to %s", e.name, e.expected, rr.Code, rr.Header().Get("Location"))
+    }
+  }
+}
+
+func TestParsePublicPort(t *testing.T) {
+  var portTests = []struct {
+    port        string
+    expected    string
+    expectError bool
+  }{
+    {"", httpsPortNumber, false},
+    {"443", ":443", false},
+    {"0", "", true},
+    {"65536", "", true},
+    {":443", "", true},
+  }
+
+  for _, e := range portTests {
+    port, err := parsePublicPort(e.port)
+    if e.expectError {
+      if err == nil {
+        t.Errorf("Expected an error for %q, but got none", e.port)
+      }
+      continue
+    }
+    if err != nil || port != e.expected {
+      t.Errorf("Expected %s for %q, but got %s and %v", e.expected, e.port, port, err)
+    }
+  }
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/amartin3659/VacationHomeRental/internal/repository"
+)
+
+// reservationRetentionEnv names the environment variable holding the days a deleted reservation
+// stays in the trash before it is purged, e.g. RESERVATION_RETENTION_DAYS=90
+const reservationRetentionEnv = "RESERVATION_RETENTION_DAYS"
+
+// defaultReservationRetention is the retention used when reservationRetentionEnv is not set
+const defaultReservationRetention = 30 * 24 * time.Hour
+
+// parseRetention returns the retention of the days given, or the default retention if days is empty
+func parseRetention(days string) (time.Duration, error) {
+  if days == "" {
+    return defaultReservationRetention, nil
+  }
+  n, err := strconv.Atoi(days)
+  if err != nil || n < 1 {
+    return 0, fmt.Errorf("%s must be a positive number of days, got %q", reservationRetentionEnv, days)
+  }
+  return time.Duration(n) * 24 * time.Hour, nil
+}
+
+// purgeTrash purges the reservations kept in the trash longer than retention in the background every interval
+func purgeTrash(db repository.DatabaseRepo, interval, retention time.Duration) {
+  go func() {
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for range ticker.C {
+      purgeDeletedReservations(db, retention)
+    }
+  }()
+}
+
+// purgeDeletedReservations deletes the reservations moved to the trash more than retention ago for good
+func purgeDeletedReservations(db repository.DatabaseRepo, retention time.Duration) {
+  n, err := db.PurgeDeletedReservations(context.Background(), time.Now().Add(-retention))
+  if err != nil {
+    app.Logger.Error("Could not purge deleted reservations", "error", err)
+    return
+  }
+  if n > 0 {
+    app.Logger.Info("Purged deleted reservations", "count", n)
+  }
+}
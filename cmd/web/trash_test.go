@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
+	"github.com/amartin3659/VacationHomeRental/internal/repository"
+)
+
+type trashRepo struct {
+  repository.DatabaseRepo
+  purged int64
+  err error
+  before time.Time
+}
+
+func (p *trashRepo) PurgeDeletedReservations(ctx context.Context, before time.Time) (int64, error) {
+  p.before = before
+  return p.purged, p.err
+}
+
+func TestPurgeDeletedReservations(t *testing.T) {
+  var logBuf bytes.Buffer
+  oldLogger := app.Logger
+  app.Logger = logging.New(&logBuf, slog.LevelInfo, false)
+  defer func() {
+    app.Logger = oldLogger
+  }()
+
+  repo := &trashRepo{purged: 3}
+  purgeDeletedReservations(repo, 24*time.Hour)
+  if !strings.Contains(logBuf.String(), "msg=\"Purged deleted reservations\" count=3") {
+    t.Error("Expected purged reservations to be logged, but they were not")
+  }
+  if d := time.Since(repo.before); d < 24*time.Hour || d > 24*time.Hour+time.Minute {
+    t.Errorf("Expected reservations deleted a day ago to be purged, but got %s", repo.before)
+  }
+
+  purgeDeletedReservations(&trashRepo{err: errors.New("some error")}, 24*time.Hour)
+  if !strings.Contains(logBuf.String(), "Could not purge deleted reservations") {
+    t.Error("Expected error to be logged, but it was not")
+  }
+}
+
+func TestParseRetention(t *testing.T) {
+  retentionTests := []struct {
+    days string
+    expected time.Duration
+    expectError bool
+  }{
+    {"", 30 * 24 * time.Hour, false},
+    {"90", 90 * 24 * time.Hour, false},
+    {"0", 0, true},
+    {"-1", 0, true},
+    {"a month", 0, true},
+  }
+
+  for _, e := range retentionTests {
+    retention, err := parseRetention(e.days)
+    if e.expectError {
+      if err == nil {
+        t.Errorf("Expected an error for %q, but got none", e.days)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("Expected no error for %q, but got %s", e.days, err)
+    }
+    if retention != e.expected {
+      t.Errorf("Expected retention %s for %q, but got %s", e.expected, e.days, retention)
+    }
+  }
+}
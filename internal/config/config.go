@@ -2,19 +2,31 @@ package config
 
 import (
 	"html/template"
-	"log"
+	"log/slog"
+	"net"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/amartin3659/VacationHomeRental/internal/metrics"
 	"github.com/amartin3659/VacationHomeRental/internal/models"
+	"github.com/amartin3659/VacationHomeRental/internal/ratelimit"
+	"github.com/amartin3659/VacationHomeRental/internal/spam"
 )
 
 // AppConfig is a struct holding this application's configuration
 type AppConfig struct {
-	TemplateCache map[string]*template.Template
-	UseCache      bool
-	InfoLog       *log.Logger
-	ErrorLog      *log.Logger
-	InProduction  bool
-	Session       *scs.SessionManager
-	MailChan      chan models.MailData
+	TemplateCache        map[string]*template.Template
+	UseCache             bool
+	Logger               *slog.Logger
+	InProduction         bool
+	Session              *scs.SessionManager
+	MailChan             chan models.MailData
+	HoldDuration         time.Duration
+	ReservationRetention time.Duration
+	Spam                 *spam.Guard
+	TrustedProxies       []*net.IPNet
+	RateLimits           map[string]ratelimit.Rule
+	Metrics              *metrics.Metrics
+	MetricsAddr          string
+	MetricsToken         string
 }
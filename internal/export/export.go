@@ -0,0 +1,154 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Writer writes the rows of a spreadsheet as they come, Close has to be called after the last row
+type Writer interface {
+	Write(row []string) error
+	Close() error
+}
+
+// formulaPrefixes start the cells a spreadsheet would evaluate as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// numberPattern matches plain numbers and phone numbers like +44 20 7946 0958, which start like a formula
+// but cannot call a function
+var numberPattern = regexp.MustCompile(`^[+-]?[0-9][0-9 ()./-]*$`)
+
+// Escape prefixes a cell starting like a formula with a quote, so a guest named =HYPERLINK(...)
+// is shown as text instead of being evaluated when the export is opened. Numbers are left as they are
+func Escape(cell string) string {
+	if cell != "" && strings.ContainsRune(formulaPrefixes, rune(cell[0])) && !numberPattern.MatchString(cell) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// Unescape removes the quote added by Escape, so exported files can be imported again
+func Unescape(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// escapeRow returns a copy of row with every cell escaped
+func escapeRow(row []string) []string {
+	escaped := make([]string, len(row))
+	for i, cell := range row {
+		escaped[i] = Escape(cell)
+	}
+	return escaped
+}
+
+// NewCSV returns a writer for a csv file, cells starting like a formula are escaped
+func NewCSV(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(row []string) error {
+	return c.w.Write(escapeRow(row))
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// the parts of an xlsx workbook with a single sheet, written before the sheet
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// NewXLSX returns a writer for an xlsx workbook with a single sheet, all cells are written as text
+// which is never evaluated, so they need no escaping
+func NewXLSX(w io.Writer) (Writer, error) {
+	z := zip.NewWriter(w)
+
+	for _, part := range xlsxParts {
+		f, err := z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.WriteString(f, part.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	x := &xlsxWriter{z: z, sheet: bufio.NewWriter(sheet)}
+	_, err = x.sheet.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+
+	return x, nil
+}
+
+type xlsxWriter struct {
+	z     *zip.Writer
+	sheet *bufio.Writer
+	rows  int
+}
+
+func (x *xlsxWriter) Write(row []string) error {
+	x.rows++
+	x.sheet.WriteString(`<row r="` + strconv.Itoa(x.rows) + `">`)
+	for _, cell := range row {
+		x.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		err := xml.EscapeText(x.sheet, []byte(cell))
+		if err != nil {
+			return err
+		}
+		x.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	_, err := x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err != nil {
+		return err
+	}
+	err = x.sheet.Flush()
+	if err != nil {
+		return err
+	}
+	return x.z.Close()
+}
@@ -0,0 +1,163 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+)
+
+var rows = [][]string{
+	{"ID", "Full Name"},
+	{"1", "Peter Griffin"},
+	{"2", `Glenn "Quagmire" <Jr.>, & Co`},
+}
+
+func TestNewCSV(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSV(&buf)
+	for _, row := range rows {
+		err := w.Write(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "ID,Full Name\n1,Peter Griffin\n2,\"Glenn \"\"Quagmire\"\" <Jr.>, & Co\"\n"
+	if buf.String() != expected {
+		t.Errorf("expected csv %q, but got %q", expected, buf.String())
+	}
+}
+
+func TestNewXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSX(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		err := w.Write(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal("expected a zip file", err)
+	}
+
+	var sheet []byte
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(r)
+		r.Close()
+
+		// every part has to be well formed
+		err = xml.Unmarshal(content, new(interface{}))
+		if err != nil {
+			t.Errorf("expected %s to be well formed xml, but got %s", f.Name, err)
+		}
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			sheet = content
+		}
+	}
+
+	var worksheet struct {
+		Rows []struct {
+			Cells []string `xml:"c>is>t"`
+		} `xml:"sheetData>row"`
+	}
+	err = xml.Unmarshal(sheet, &worksheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(worksheet.Rows) != len(rows) {
+		t.Fatalf("expected %d rows, but got %d", len(rows), len(worksheet.Rows))
+	}
+	for i, row := range worksheet.Rows {
+		for j, cell := range row.Cells {
+			if cell != rows[i][j] {
+				t.Errorf("expected cell %q, but got %q", rows[i][j], cell)
+			}
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	var escapeTests = []struct {
+		cell     string
+		expected string
+	}{
+		{"Peter Griffin", "Peter Griffin"},
+		{"", ""},
+		{"=HYPERLINK(\"http://evil.example\")", "'=HYPERLINK(\"http://evil.example\")"},
+		{"+cmd|' /C calc'!A0", "'+cmd|' /C calc'!A0"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"peter@griffin.family", "peter@griffin.family"},
+		{"+44 20 7946 0958", "+44 20 7946 0958"},
+		{"+1 (555) 010-0100", "+1 (555) 010-0100"},
+		{"-12.5", "-12.5"},
+		{"+44=1", "'+44=1"},
+	}
+
+	for _, e := range escapeTests {
+		if escaped := Escape(e.cell); escaped != e.expected {
+			t.Errorf("expected %q to be escaped as %q, but got %q", e.cell, e.expected, escaped)
+		}
+		if unescaped := Unescape(Escape(e.cell)); unescaped != e.cell {
+			t.Errorf("expected %q to be unescaped again, but got %q", e.cell, unescaped)
+		}
+	}
+}
+
+func TestWritersEscapeFormulasInCSVOnly(t *testing.T) {
+	row := []string{"1", "=HYPERLINK(\"http://evil.example\")"}
+
+	var csvBuf bytes.Buffer
+	c := NewCSV(&csvBuf)
+	c.Write(row)
+	c.Close()
+	if !bytes.Contains(csvBuf.Bytes(), []byte(`"'=HYPERLINK(""http://evil.example"")"`)) {
+		t.Errorf("expected the csv cell to be escaped, but got %q", csvBuf.String())
+	}
+
+	var xlsxBuf bytes.Buffer
+	x, err := NewXLSX(&xlsxBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.Write(row)
+	x.Close()
+
+	z, err := zip.NewReader(bytes.NewReader(xlsxBuf.Bytes()), int64(xlsxBuf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range z.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		r, _ := f.Open()
+		content, _ := io.ReadAll(r)
+		r.Close()
+		if !bytes.Contains(content, []byte(`>=HYPERLINK(&#34;http://evil.example&#34;)<`)) {
+			t.Errorf("expected the xlsx cell to be written as text unchanged, but got %q", content)
+		}
+	}
+}
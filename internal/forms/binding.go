@@ -0,0 +1,223 @@
+package forms
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bind copies the values of data into the fields of the struct pointed to by dst which carry
+// a form tag, validates them by their validate tags and returns a form holding data and the errors.
+//
+// The validate tag is a comma separated list of rules:
+// required, min=N and max=N (length), email, phone, date, range=MIN:MAX (numeric),
+// oneof=A B C and regex=PATTERN, which has to be the last rule as the pattern may contain commas.
+// Fields which are not required are only validated if they are not empty, embedded structs are bound as well.
+func Bind(data url.Values, dst interface{}) (*Form, error) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("forms: Bind needs a pointer to a struct, got %T", dst)
+	}
+
+	form := New(data)
+
+	err := form.bindStruct(v.Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	return form, nil
+}
+
+// bindStruct binds the form values to the tagged fields of a struct and of its embedded structs
+func (f *Form) bindStruct(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		field := sf.Tag.Get("form")
+		if field == "" && sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			err := f.bindStruct(v.Field(i))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if field == "" || field == "-" {
+			continue
+		}
+
+		msg, err := setField(v.Field(i), f.Get(field))
+		if err != nil {
+			return fmt.Errorf("forms: field %s: %w", sf.Name, err)
+		}
+		if msg != "" {
+			f.Errors.Add(field, msg)
+			continue
+		}
+
+		err = f.validate(field, sf.Tag.Get("validate"))
+		if err != nil {
+			return fmt.Errorf("forms: field %s: %w", sf.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// BindJSON decodes a JSON object from body into form values keyed like the form tags of dst
+// and binds them to dst like Bind
+func BindJSON(body io.Reader, dst interface{}) (*Form, error) {
+	var object map[string]interface{}
+	err := json.NewDecoder(body).Decode(&object)
+	if err != nil {
+		return nil, err
+	}
+
+	data := url.Values{}
+	for key, value := range object {
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, x := range values {
+			switch x := x.(type) {
+			case nil:
+			case string:
+				data.Add(key, x)
+			case float64:
+				data.Add(key, strconv.FormatFloat(x, 'f', -1, 64))
+			case bool:
+				data.Add(key, strconv.FormatBool(x))
+			default:
+				b, _ := json.Marshal(x)
+				data.Add(key, string(b))
+			}
+		}
+	}
+
+	return Bind(data, dst)
+}
+
+// setField sets a struct field from a form value, it returns a message for the user if the value
+// cannot be converted and an error if the type of the field is not supported
+func setField(v reflect.Value, value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	if v.Type() == reflect.TypeOf(time.Time{}) {
+		if value == "" {
+			v.Set(reflect.ValueOf(time.Time{}))
+			return "", nil
+		}
+		t, err := time.Parse(DateLayout, value)
+		if err != nil {
+			return "Requires a valid date (YYYY-MM-DD).", nil
+		}
+		v.Set(reflect.ValueOf(t))
+		return "", nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value == "" {
+			v.SetInt(0)
+			return "", nil
+		}
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return "Requires a whole number.", nil
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		if value == "" {
+			v.SetFloat(0)
+			return "", nil
+		}
+		n, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return "Requires a number.", nil
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		// checkboxes post "on" or nothing at all
+		v.SetBool(value == "on" || value == "true" || value == "1")
+	default:
+		return "", fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return "", nil
+}
+
+// validate applies the rules of a validate tag to a field, it stops at the first failing rule
+func (f *Form) validate(field, tag string) error {
+	if tag == "" {
+		return nil
+	}
+
+	rules := strings.Split(tag, ",")
+	for i := 0; i < len(rules); i++ {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rules[i]), "=")
+		if rule == "regex" {
+			param = strings.Join(append([]string{param}, rules[i+1:]...), ",")
+			i = len(rules)
+		}
+
+		if rule != "required" && !f.Has(field) {
+			continue
+		}
+
+		errorCount := len(f.Errors[field])
+
+		switch rule {
+		case "required":
+			f.Required(field)
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				return fmt.Errorf("invalid rule %q", rules[i])
+			}
+			if rule == "min" {
+				f.MinLength(field, n)
+			} else {
+				f.MaxLength(field, n)
+			}
+		case "email":
+			f.IsEmail(field)
+		case "phone":
+			f.IsPhone(field)
+		case "date":
+			f.IsDate(field)
+		case "range":
+			lo, hi, ok := strings.Cut(param, ":")
+			low, err1 := strconv.ParseFloat(lo, 64)
+			high, err2 := strconv.ParseFloat(hi, 64)
+			if !ok || err1 != nil || err2 != nil {
+				return fmt.Errorf("invalid rule %q", rules[i])
+			}
+			f.InRange(field, low, high)
+		case "oneof":
+			f.OneOf(field, strings.Fields(param)...)
+		case "regex":
+			re, err := regexp.Compile(param)
+			if err != nil {
+				return fmt.Errorf("invalid rule %q: %w", rule, err)
+			}
+			f.Matches(field, re)
+		default:
+			return fmt.Errorf("unknown rule %q", rule)
+		}
+
+		if len(f.Errors[field]) > errorCount {
+			return nil
+		}
+	}
+
+	return nil
+}
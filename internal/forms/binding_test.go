@@ -0,0 +1,147 @@
+package forms
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testBooking struct {
+	FullName   string    `form:"full_name" validate:"required,min=2,max=10"`
+	Email      string    `form:"email" validate:"required,email"`
+	Phone      string    `form:"phone" validate:"phone"`
+	Adults     int       `form:"adults" validate:"required,range=1:6"`
+	Arrival    time.Time `form:"arrival" validate:"required"`
+	Kind       string    `form:"kind" validate:"oneof=owner maintenance"`
+	Code       string    `form:"code" validate:"regex=^[A-Z]{2,3}$"`
+	Newsletter bool      `form:"newsletter"`
+	Internal   string
+}
+
+func TestBind(t *testing.T) {
+	postedData := url.Values{}
+	postedData.Add("full_name", "Peter")
+	postedData.Add("email", "peter@griffin.family")
+	postedData.Add("phone", "+1 (234) 567-890")
+	postedData.Add("adults", "2")
+	postedData.Add("arrival", "2036-01-01")
+	postedData.Add("kind", "owner")
+	postedData.Add("code", "AB")
+	postedData.Add("newsletter", "on")
+	postedData.Add("Internal", "not bound")
+
+	var b testBooking
+	form, err := Bind(postedData, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !form.Valid() {
+		t.Errorf("expected valid form, got errors %v", form.Errors)
+	}
+	if b.FullName != "Peter" || b.Adults != 2 || b.Arrival.Format(DateLayout) != "2036-01-01" || !b.Newsletter {
+		t.Errorf("values not bound as expected: %+v", b)
+	}
+	if b.Internal != "" {
+		t.Error("bound a field without form tag")
+	}
+
+	postedData = url.Values{}
+	postedData.Add("full_name", "P")
+	postedData.Add("email", "x")
+	postedData.Add("phone", "call me")
+	postedData.Add("adults", "two")
+	postedData.Add("arrival", "tomorrow")
+	postedData.Add("kind", "guest")
+	postedData.Add("code", "abc")
+
+	b = testBooking{}
+	form, err = Bind(postedData, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"full_name", "email", "phone", "adults", "arrival", "kind", "code"} {
+		if form.Errors.Get(field) == "" {
+			t.Errorf("expected an error for field %s", field)
+		}
+	}
+
+	postedData = url.Values{}
+	postedData.Add("full_name", "Peter Griffin Junior")
+	postedData.Add("email", "peter@griffin.family")
+	postedData.Add("adults", "7")
+	postedData.Add("arrival", "2036-01-01")
+
+	form, _ = Bind(postedData, &b)
+	if form.Errors.Get("full_name") == "" || form.Errors.Get("adults") == "" {
+		t.Error("expected errors for too long name and too many adults")
+	}
+	if form.Errors.Get("phone") != "" || form.Errors.Get("kind") != "" {
+		t.Error("validated empty optional fields")
+	}
+}
+
+func TestBind_InvalidTarget(t *testing.T) {
+	var s string
+	_, err := Bind(url.Values{}, &s)
+	if err == nil {
+		t.Error("expected an error binding to a non struct")
+	}
+
+	_, err = Bind(url.Values{}, testBooking{})
+	if err == nil {
+		t.Error("expected an error binding to a struct value")
+	}
+
+	var unknown struct {
+		Name string `form:"name" validate:"nickname"`
+	}
+	_, err = Bind(url.Values{"name": {"x"}}, &unknown)
+	if err == nil {
+		t.Error("expected an error for an unknown rule")
+	}
+}
+
+func TestBindJSON(t *testing.T) {
+	body := `{"full_name": "Peter", "email": "peter@griffin.family", "adults": 3, "arrival": "2036-01-01", "newsletter": true, "phone": null}`
+
+	var b testBooking
+	form, err := BindJSON(strings.NewReader(body), &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !form.Valid() {
+		t.Errorf("expected valid form, got errors %v", form.Errors)
+	}
+	if b.Adults != 3 || !b.Newsletter || form.Get("adults") != "3" {
+		t.Errorf("values not bound as expected: %+v", b)
+	}
+
+	_, err = BindJSON(strings.NewReader("not json"), &b)
+	if err == nil {
+		t.Error("expected an error for an invalid body")
+	}
+}
+
+func TestBind_Embedded(t *testing.T) {
+	type party struct {
+		Adults int `form:"adults" validate:"required,range=1:20"`
+	}
+	var booking struct {
+		FullName string `form:"full_name"`
+		party
+	}
+
+	form, err := Bind(url.Values{"full_name": {"Peter"}, "adults": {"2"}}, &booking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !form.Valid() || booking.Adults != 2 {
+		t.Errorf("embedded struct not bound: %+v", booking)
+	}
+
+	form, _ = Bind(url.Values{"full_name": {"Peter"}}, &booking)
+	if form.Errors.Get("adults") == "" {
+		t.Error("embedded struct not validated")
+	}
+}
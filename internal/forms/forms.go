@@ -3,7 +3,10 @@ package forms
 import (
 	"fmt"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
   "github.com/asaskevich/govalidator"
 )
@@ -59,3 +62,129 @@ func (f *Form) IsEmail(field string) {
     f.Errors.Add(field, fmt.Sprintf("Requires a vaild email address"))
   }
 }
+
+// DateLayout is the layout of dates posted in forms
+const DateLayout = "2006-01-02"
+
+// location is the time zone in which the current date is determined
+var location = time.Local
+
+// SetLocation sets the time zone in which the current date is determined
+func SetLocation(loc *time.Location) {
+  location = loc
+}
+
+// Today returns the current date in the configured time zone as midnight UTC,
+// which makes it comparable to dates parsed from forms
+func Today() time.Time {
+  now := time.Now().In(location)
+  return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Date returns the value of a field parsed as date, or the zero time if it is not a valid date
+func (f *Form) Date(field string) time.Time {
+  t, err := time.Parse(DateLayout, f.Get(field))
+  if err != nil {
+    return time.Time{}
+  }
+  return t
+}
+
+// IsDate checks if the values of fields are valid dates
+func (f *Form) IsDate(fields ...string) {
+  for _, field := range fields {
+    if _, err := time.Parse(DateLayout, f.Get(field)); err != nil {
+      f.Errors.Add(field, "Requires a valid date (YYYY-MM-DD).")
+    }
+  }
+}
+
+// NotInPast checks if the date of a field is today or later
+func (f *Form) NotInPast(field string) {
+  d := f.Date(field)
+  if d.IsZero() {
+    return
+  }
+  if d.Before(Today()) {
+    f.Errors.Add(field, "This date cannot be in the past.")
+  }
+}
+
+// DateAfter checks if the date of endField is after the date of startField
+func (f *Form) DateAfter(startField, endField string) {
+  start := f.Date(startField)
+  end := f.Date(endField)
+  if start.IsZero() || end.IsZero() {
+    return
+  }
+  if !end.After(start) {
+    f.Errors.Add(endField, fmt.Sprintf("This date must be after %s.", start.Format(DateLayout)))
+  }
+}
+
+// DateRange checks if the number of nights between startField and endField is within
+// minNights and maxNights, a limit of 0 is not checked
+func (f *Form) DateRange(startField, endField string, minNights, maxNights int) {
+  start := f.Date(startField)
+  end := f.Date(endField)
+  if start.IsZero() || end.IsZero() {
+    return
+  }
+  nights := int(end.Sub(start).Hours() / 24)
+  if minNights > 0 && nights < minNights {
+    f.Errors.Add(endField, fmt.Sprintf("The stay must be at least %d nights.", minNights))
+  }
+  if maxNights > 0 && nights > maxNights {
+    f.Errors.Add(endField, fmt.Sprintf("The stay can be at most %d nights.", maxNights))
+  }
+}
+
+// MaxLength checks if the field value is not longer than a given length
+func (f *Form) MaxLength(field string, length int) {
+  if len(strings.TrimSpace(f.Get(field))) > length {
+    f.Errors.Add(field, fmt.Sprintf("This field can have at most %d characters.", length))
+  }
+}
+
+// phoneChars matches the characters allowed in a phone number
+var phoneChars = regexp.MustCompile(`^\+?[0-9 ()./-]+$`)
+
+// IsPhone checks if the value of a field is a phone number with 6 to 15 digits
+func (f *Form) IsPhone(field string) {
+  value := strings.TrimSpace(f.Get(field))
+  digits := 0
+  for _, c := range value {
+    if c >= '0' && c <= '9' {
+      digits++
+    }
+  }
+  if !phoneChars.MatchString(value) || digits < 6 || digits > 15 {
+    f.Errors.Add(field, "Requires a valid phone number.")
+  }
+}
+
+// InRange checks if the value of a field is a number between min and max
+func (f *Form) InRange(field string, min, max float64) {
+  n, err := strconv.ParseFloat(strings.TrimSpace(f.Get(field)), 64)
+  if err != nil || n < min || n > max {
+    f.Errors.Add(field, fmt.Sprintf("This field must be a number between %g and %g.", min, max))
+  }
+}
+
+// OneOf checks if the value of a field is one of the given options
+func (f *Form) OneOf(field string, options ...string) {
+  value := f.Get(field)
+  for _, o := range options {
+    if value == o {
+      return
+    }
+  }
+  f.Errors.Add(field, fmt.Sprintf("This field must be one of: %s.", strings.Join(options, ", ")))
+}
+
+// Matches checks if the value of a field matches a regular expression
+func (f *Form) Matches(field string, re *regexp.Regexp) {
+  if !re.MatchString(f.Get(field)) {
+    f.Errors.Add(field, "This field has an invalid format.")
+  }
+}
@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestForm_Valid(t *testing.T) {
@@ -126,3 +127,126 @@ func TestForm_IsEmail(t *testing.T) {
 		t.Error("got valid for invalid email address")
 	}
 }
+
+func TestForm_IsDate(t *testing.T) {
+  postedValues := url.Values{}
+  postedValues.Add("start", "2036-01-01")
+  postedValues.Add("end", "01/02/2036")
+  form := New(postedValues)
+
+  form.IsDate("start")
+	if !form.Valid() {
+		t.Error("got an invalid date when we should not have")
+	}
+
+  form.IsDate("end", "x")
+	if form.Errors.Get("end") == "" || form.Errors.Get("x") == "" {
+		t.Error("got valid for invalid or non-existent dates")
+	}
+}
+
+func TestForm_NotInPast(t *testing.T) {
+  postedValues := url.Values{}
+  postedValues.Add("today", Today().Format(DateLayout))
+  postedValues.Add("past", Today().AddDate(0, 0, -1).Format(DateLayout))
+  form := New(postedValues)
+
+  form.NotInPast("today")
+	if !form.Valid() {
+		t.Error("got today as date in the past")
+	}
+
+  form.NotInPast("past")
+	if form.Valid() {
+		t.Error("got valid for a date in the past")
+	}
+}
+
+func TestForm_DateAfter(t *testing.T) {
+  postedValues := url.Values{}
+  postedValues.Add("start", "2036-01-02")
+  postedValues.Add("end", "2036-01-03")
+  postedValues.Add("same", "2036-01-02")
+  form := New(postedValues)
+
+  form.DateAfter("start", "end")
+	if !form.Valid() {
+		t.Error("got end before start when it is after")
+	}
+
+  form.DateAfter("start", "same")
+	if form.Errors.Get("same") == "" {
+		t.Error("got valid for end on the same day as start")
+	}
+}
+
+func TestForm_DateRange(t *testing.T) {
+  postedValues := url.Values{}
+  postedValues.Add("start", "2036-01-01")
+  postedValues.Add("end", "2036-01-08")
+  form := New(postedValues)
+
+  form.DateRange("start", "end", 7, 14)
+	if !form.Valid() {
+		t.Error("got a stay of 7 nights outside of 7 to 14 nights")
+	}
+
+  form.DateRange("start", "end", 8, 0)
+	if form.Valid() {
+		t.Error("got valid for a stay of 7 nights with a minimum of 8 nights")
+	}
+
+  form = New(postedValues)
+  form.DateRange("start", "end", 0, 6)
+	if form.Valid() {
+		t.Error("got valid for a stay of 7 nights with a maximum of 6 nights")
+	}
+}
+
+func TestToday(t *testing.T) {
+  defer SetLocation(time.Local)
+
+  loc := time.FixedZone("UTC+14", 14*60*60)
+  SetLocation(loc)
+
+  now := time.Now().In(loc)
+  today := Today()
+	if today.Day() != now.Day() || today.Location() != time.UTC || today.Hour() != 0 {
+		t.Errorf("expected %s at midnight UTC, got %s", now.Format(DateLayout), today)
+	}
+}
+
+func TestForm_MaxLength(t *testing.T) {
+  postedValues := url.Values{}
+  postedValues.Add("a", "value a")
+  form := New(postedValues)
+
+  form.MaxLength("a", 7)
+	if !form.Valid() {
+		t.Error("shows maximum length of 7 is exceeded when it is not")
+	}
+
+  form.MaxLength("a", 6)
+	if form.Valid() {
+		t.Error("shows maximum length of 6 met but is longer")
+	}
+}
+
+func TestForm_IsPhone(t *testing.T) {
+  postedValues := url.Values{}
+  postedValues.Add("phone", "+49 (0)30 / 123-456")
+  postedValues.Add("short", "12345")
+  postedValues.Add("text", "123456 ext")
+  form := New(postedValues)
+
+  form.IsPhone("phone")
+	if !form.Valid() {
+		t.Error("got an invalid phone number when we should not have")
+	}
+
+  form.IsPhone("short")
+  form.IsPhone("text")
+	if form.Errors.Get("short") == "" || form.Errors.Get("text") == "" {
+		t.Error("got valid for invalid phone numbers")
+	}
+}
@@ -1,19 +1,30 @@
 package handlers
 
 import (
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"html/template"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/amartin3659/VacationHomeRental/internal/config"
 	"github.com/amartin3659/VacationHomeRental/internal/driver"
+	"github.com/amartin3659/VacationHomeRental/internal/export"
 	"github.com/amartin3659/VacationHomeRental/internal/forms"
 	"github.com/amartin3659/VacationHomeRental/internal/helpers"
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
+	"github.com/amartin3659/VacationHomeRental/internal/metrics"
 	"github.com/amartin3659/VacationHomeRental/internal/models"
+	"github.com/amartin3659/VacationHomeRental/internal/ratelimit"
 	"github.com/amartin3659/VacationHomeRental/internal/render"
 	"github.com/amartin3659/VacationHomeRental/internal/repository"
 	"github.com/amartin3659/VacationHomeRental/internal/repository/dbrepo"
@@ -62,7 +73,110 @@ func (m *Repository) About(w http.ResponseWriter, r *http.Request) {
 
 // Contact is the handler for the caontact page
 func (m *Repository) Contact(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "contact-page.html", &models.TemplateData{})
+	bungalows, err := m.DB.AllBungalows(r.Context())
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "can't get data from database")
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["bungalows"] = bungalows
+
+	render.Template(w, r, "contact-page.html", &models.TemplateData{
+		Data: data,
+		Form: forms.New(nil),
+	})
+}
+
+// PostContact stores a message sent with the contact form and notifies the owner
+func (m *Repository) PostContact(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "can't parse form")
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	bungalows, err := m.DB.AllBungalows(r.Context())
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "can't get data from database")
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	var inquiry models.Inquiry
+	form, err := forms.Bind(r.PostForm, &inquiry)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "can't parse form")
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// the stay is optional, but has to be complete if given
+	if form.Has("start_date") || form.Has("end_date") {
+		validateStayDates(form, "start_date", "end_date")
+		inquiry.StartDate = form.Date("start_date")
+		inquiry.EndDate = form.Date("end_date")
+	}
+
+	if form.Has("bungalow_id") {
+		inquiry.BungalowID, _ = strconv.Atoi(form.Get("bungalow_id"))
+		for _, b := range bungalows {
+			if b.ID == inquiry.BungalowID {
+				inquiry.Bungalow = b
+			}
+		}
+		if inquiry.Bungalow.ID == 0 {
+			form.Errors.Add("bungalow_id", "There is no such bungalow.")
+		}
+	}
+
+	if !form.Valid() {
+		data := make(map[string]interface{})
+		data["bungalows"] = bungalows
+
+		render.Template(w, r, "contact-page.html", &models.TemplateData{
+			Data: data,
+			Form: form,
+		})
+		return
+	}
+
+	_, err = m.DB.InsertInquiry(r.Context(), inquiry)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "can't write message to database")
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	// sending an e-mail to the owner
+	stay := "no particular stay"
+	if !inquiry.StartDate.IsZero() {
+		stay = fmt.Sprintf("a stay from %s to %s", inquiry.StartDate.Format("2006-01-02"), inquiry.EndDate.Format("2006-01-02"))
+	}
+	if inquiry.Bungalow.BungalowName != "" {
+		stay += fmt.Sprintf(" in \"%s\"", inquiry.Bungalow.BungalowName)
+	}
+
+	htmlMessage := fmt.Sprintf(`
+		<strong>New Inquiry: %s</strong><br>
+		%s (%s) asks about %s:<br><br>
+		%s
+		`, template.HTMLEscapeString(inquiry.Subject), template.HTMLEscapeString(inquiry.FullName), template.HTMLEscapeString(inquiry.Email),
+		template.HTMLEscapeString(stay), strings.ReplaceAll(template.HTMLEscapeString(inquiry.Message), "\n", "<br>"))
+
+	msg := models.MailData{
+		To:        "whoever@is-in-charge.com",
+		From:      "noreply@bungalow-bliss.com",
+		Subject:   "New Inquiry",
+		Content:   htmlMessage,
+		RequestID: logging.RequestID(r.Context()),
+	}
+	m.App.MailChan <- msg
+
+	m.App.Session.Put(r.Context(), "success", "Thank you for your message, we will get back to you soon.")
+	http.Redirect(w, r, "/contact", http.StatusSeeOther)
 }
 
 // Eremite is the handler for the eremite page
@@ -94,52 +208,78 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sd := r.Form.Get("start")
-	ed := r.Form.Get("end")
-
-	layout := "2006-01-02"
-fmt.Println(sd)
-	startDate, err := time.Parse(layout, sd)
-	if err != nil {
-    fmt.Println("error parse start")
-		m.App.Session.Put(r.Context(), "error", "can't get data from form")
+	form := forms.New(r.Form)
+	validateStayDates(form, "start", "end")
+	if !form.Valid() {
+		m.App.Session.Put(r.Context(), "error", stayDatesError(form, "start", "end"))
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	endDate, err := time.Parse(layout, ed)
-	if err != nil {
-    fmt.Println("error parse end")
-		m.App.Session.Put(r.Context(), "error", "can't get data from form")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
-		return
+	startDate := form.Date("start")
+	endDate := form.Date("end")
+
+	// the party size is optional and only shows bungalows which can host the party
+	var party models.Party
+	if form.Has("adults") {
+		partyForm, err := forms.Bind(r.Form, &party)
+		if err != nil || !partyForm.Valid() {
+			m.App.Session.Put(r.Context(), "error", "Please enter a valid number of guests.")
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			return
+		}
 	}
 
-	bungalows, err := m.DB.SearchAvailabilityByDatesForAllBungalows(startDate, endDate)
+	bungalows, err := m.DB.SearchAvailabilityByDatesForAllBungalows(r.Context(), startDate, endDate, party.Guests())
 	if err != nil {
-    fmt.Println("error db")
 		m.App.Session.Put(r.Context(), "error", "can't get data from database")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
+	m.App.Metrics.AvailabilitySearches.Inc("all")
 
 	if len(bungalows) == 0 {
-    fmt.Println("error len = 0")
+		m.App.Metrics.EmptySearches.Inc("all")
 		m.App.Session.Put(r.Context(), "error", ":( No holiday home is available at that time.")
 		http.Redirect(w, r, "/reservation", http.StatusSeeOther)
 		return
 	}
 
+	// only offer the bungalows whose stay rules allow the requested dates
+	var allowed []models.Bungalow
+	var ruleMessage string
+	for _, b := range bungalows {
+		msg, err := m.checkStayRules(r.Context(), b.ID, startDate, endDate)
+		if err != nil {
+			m.App.Session.Put(r.Context(), "error", "can't get data from database")
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			return
+		}
+		if msg != "" {
+			ruleMessage = msg
+			continue
+		}
+		allowed = append(allowed, b)
+	}
+
+	if len(allowed) == 0 {
+		m.App.Metrics.EmptySearches.Inc("all")
+		m.App.Session.Put(r.Context(), "error", ruleMessage)
+		http.Redirect(w, r, "/reservation", http.StatusSeeOther)
+		return
+	}
+	bungalows = allowed
+
 	data := make(map[string]interface{})
 	data["bungalows"] = bungalows
 
 	res := models.Reservation{
+		Party:     party,
 		StartDate: startDate,
 		EndDate:   endDate,
 	}
 
 	m.App.Session.Put(r.Context(), "reservation", res)
-  fmt.Println("here")
 	render.Template(w, r, "choose-bungalow-page.html", &models.TemplateData{
 		Data: data,
 	})
@@ -182,26 +322,27 @@ func (m *Repository) ReservationJSON(w http.ResponseWriter, r *http.Request) {
 	sd := r.Form.Get("start")
 	ed := r.Form.Get("end")
 
-	layout := "2006-01-02"
+	form := forms.New(r.Form)
+	validateStayDates(form, "start", "end")
+	if !form.Valid() {
+		resp := jsonResponse{
+			OK:      false,
+			Message: stayDatesError(form, "start", "end"),
+		}
 
-	startDate, err := time.Parse(layout, sd)
-	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't get data from form")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		output, _ := json.MarshalIndent(resp, "", "    ")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(output)
 		return
 	}
 
-	endDate, err := time.Parse(layout, ed)
-	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't get data from form")
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
-		return
-	}
+	startDate := form.Date("start")
+	endDate := form.Date("end")
 
-	available, err := m.DB.SearchAvailabilityByDatesByBungalowID(startDate, endDate, bungalowID)
+	available, err := m.DB.SearchAvailabilityByDatesByBungalowID(r.Context(), startDate, endDate, bungalowID)
 	if err != nil {
 		// needs to be removed that the test work
-		// helpers.ServerError(w, err)
+		// helpers.ServerError(w, r, err)
 		resp := jsonResponse{
 			OK:      false,
 			Message: "Error querying database",
@@ -213,9 +354,37 @@ func (m *Repository) ReservationJSON(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	m.App.Metrics.AvailabilitySearches.Inc("bungalow")
+
+	message := "Available"
+	if !available {
+		message = ":( This holiday home is not available at this time."
+	} else {
+		msg, err := m.checkStayRules(r.Context(), bungalowID, startDate, endDate)
+		if err != nil {
+			resp := jsonResponse{
+				OK:      false,
+				Message: "Error querying database",
+			}
+
+			output, _ := json.MarshalIndent(resp, "", "     ")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(output)
+			return
+		}
+		if msg != "" {
+			available = false
+			message = msg
+		}
+	}
+
+	if !available {
+		m.App.Metrics.EmptySearches.Inc("bungalow")
+	}
+
 	resp := jsonResponse{
 		OK:         available,
-		Message:    "Available",
+		Message:    message,
 		StartDate:  sd,
 		EndDate:    ed,
 		BungalowID: strconv.Itoa(bungalowID),
@@ -224,7 +393,7 @@ func (m *Repository) ReservationJSON(w http.ResponseWriter, r *http.Request) {
 	output, _ := json.MarshalIndent(resp, "", "    ")
 	// needs to be removed that the test work
 	// if err != nil {
-	// 	helpers.ServerError(w, err)
+	// 	helpers.ServerError(w, r, err)
 	// }
 
 	w.Header().Set("Content-Type", "application/json")
@@ -236,14 +405,14 @@ func (m *Repository) MakeReservation(w http.ResponseWriter, r *http.Request) {
 
 	res, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
-		// helpers.ServerError(w, errors.New("cannot get reservation back from session"))
+		// helpers.ServerError(w, r, errors.New("cannot get reservation back from session"))
 		// return
 		m.App.Session.Put(r.Context(), "error", "Cannot get reservation back from session")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	bungalow, err := m.DB.GetBungalowByID(res.BungalowID)
+	bungalow, err := m.DB.GetBungalowByID(r.Context(), res.BungalowID)
 	if err != nil {
 		m.App.Session.Put(r.Context(), "error", "can't find bungalow!")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
@@ -251,6 +420,7 @@ func (m *Repository) MakeReservation(w http.ResponseWriter, r *http.Request) {
 	}
 
 	res.Bungalow.BungalowName = bungalow.BungalowName
+	res.Bungalow.MaxOccupancy = bungalow.MaxOccupancy
 
 	m.App.Session.Put(r.Context(), "reservation", res)
 
@@ -289,23 +459,27 @@ func (m *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Request)
 	}
 
 	reservation := models.Reservation{
-		FullName:   r.Form.Get("full_name"),
-		Email:      r.Form.Get("email"),
-		Phone:      r.Form.Get("phone"),
 		StartDate:  res.StartDate,
 		EndDate:    res.EndDate,
 		BungalowID: res.BungalowID,
 		Bungalow: models.Bungalow{
 			BungalowName: res.Bungalow.BungalowName,
+			MaxOccupancy: res.Bungalow.MaxOccupancy,
 		},
 	}
 
-	//validate form data
-	form := forms.New(r.PostForm)
+	// bind and validate form data
+	form, err := forms.Bind(r.PostForm, &reservation)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "can't parse form")
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
 
-	form.Required("full_name", "email")
-	form.MinLength("full_name", 2)
-	form.IsEmail("email")
+	maxOccupancy := res.Bungalow.MaxOccupancy
+	if maxOccupancy > 0 && reservation.Guests() > maxOccupancy {
+		form.Errors.Add("adults", fmt.Sprintf("This holiday home can host at most %d guests, infants not counted.", maxOccupancy))
+	}
 
 	if !form.Valid() {
 		data := make(map[string]interface{})
@@ -332,27 +506,42 @@ func (m *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	newReservationID, err := m.DB.InsertReservation(reservation)
+	ruleMessage, err := m.checkStayRules(r.Context(), res.BungalowID, res.StartDate, res.EndDate)
 	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't write reservation to database")
+		m.App.Session.Put(r.Context(), "error", "can't get data from database")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
-
-	restriction := models.BungalowRestriction{
-		StartDate:     res.StartDate,
-		EndDate:       res.EndDate,
-		BungalowID:    res.BungalowID,
-		ReservationID: newReservationID,
-		RestrictionID: 1,
+	if ruleMessage != "" {
+		m.App.Session.Put(r.Context(), "error", ruleMessage)
+		http.Redirect(w, r, "/reservation", http.StatusSeeOther)
+		return
 	}
 
-	err = m.DB.InsertBungalowRestriction(restriction)
-	if err != nil {
-		m.App.Session.Put(r.Context(), "error", "can't reserve bungalow in database")
+	// turn the hold placed when the bungalow was chosen into the reservation
+	holdID, _ := m.App.Session.Get(r.Context(), "hold_id").(int)
+	_, err = m.DB.ConvertHoldToReservation(r.Context(), holdID, reservation)
+	if errors.Is(err, repository.ErrHoldExpired) {
+		// the hold has expired, so the bungalow can only be reserved if nobody else took it meanwhile
+		_, err = m.DB.InsertReservationIfAvailable(r.Context(), reservation)
+		if errors.Is(err, repository.ErrNotAvailable) {
+			m.App.Session.Remove(r.Context(), "hold_id")
+			m.App.Session.Put(r.Context(), "error", "Sorry, this holiday home has been booked by someone else in the meantime.")
+			http.Redirect(w, r, "/reservation", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			m.App.Session.Put(r.Context(), "error", "can't write reservation to database")
+			http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+			return
+		}
+	} else if err != nil {
+		m.App.Session.Put(r.Context(), "error", "can't write reservation to database")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
+	m.App.Session.Remove(r.Context(), "hold_id")
+	m.App.Metrics.ReservationsCreated.Inc("booking")
 
 	// sending an e-mail to the user
 	htmlMessage := fmt.Sprintf(`
@@ -362,10 +551,11 @@ func (m *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Request)
 	`, reservation.FullName, res.Bungalow.BungalowName, reservation.StartDate.Format("2006-01-02"), reservation.EndDate.Format("2006-01-02"))
 
 	msg := models.MailData{
-		To:      reservation.Email,
-		From:    "noreply@bungalow-bliss.com",
-		Subject: "Receipt of a request for a reservation",
-		Content: htmlMessage,
+		To:        reservation.Email,
+		From:      "noreply@bungalow-bliss.com",
+		Subject:   "Receipt of a request for a reservation",
+		Content:   htmlMessage,
+		RequestID: logging.RequestID(r.Context()),
 	}
 	m.App.MailChan <- msg
 
@@ -376,10 +566,11 @@ func (m *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Request)
 		`, res.Bungalow.BungalowName, reservation.StartDate.Format("2006-01-02"), reservation.EndDate.Format("2006-01-02"))
 
 	msg = models.MailData{
-		To:      "whoever@is-in-charge.com",
-		From:    "noreply@bungalow-bliss.com",
-		Subject: "New Reservation Request",
-		Content: htmlMessage,
+		To:        "whoever@is-in-charge.com",
+		From:      "noreply@bungalow-bliss.com",
+		Subject:   "New Reservation Request",
+		Content:   htmlMessage,
+		RequestID: logging.RequestID(r.Context()),
 	}
 	m.App.MailChan <- msg
 
@@ -387,6 +578,41 @@ func (m *Repository) PostMakeReservation(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, "/reservation-overview", http.StatusSeeOther)
 }
 
+// validateStayDates validates the arrival and departure fields of a booking form
+func validateStayDates(form *forms.Form, startField, endField string) {
+	form.Required(startField, endField)
+	form.IsDate(startField, endField)
+	form.NotInPast(startField)
+	form.DateAfter(startField, endField)
+}
+
+// stayDatesError returns the first error message of the arrival and departure fields of a booking form
+func stayDatesError(form *forms.Form, startField, endField string) string {
+	if msg := form.Errors.Get(startField); msg != "" {
+		return fmt.Sprintf("Arrival: %s", msg)
+	}
+	return fmt.Sprintf("Departure: %s", form.Errors.Get(endField))
+}
+
+// checkStayRules returns a guest-facing message if a stay in a bungalow breaks one of its stay rules,
+// every rule applying to the stay is checked and the first broken one is reported
+func (m *Repository) checkStayRules(ctx context.Context, bungalowID int, start, end time.Time) (string, error) {
+	rules, err := m.DB.GetStayRulesForBungalowByDate(ctx, bungalowID, start)
+	if err != nil {
+		return "", err
+	}
+
+	today := forms.Today()
+
+	for _, rule := range rules {
+		if msg := rule.Check(start, end, today); msg != "" {
+			return msg, nil
+		}
+	}
+
+	return "", nil
+}
+
 // ReservationOverview displays the reservation summary page
 func (m *Repository) ReservationOverview(w http.ResponseWriter, r *http.Request) {
 
@@ -399,7 +625,7 @@ func (m *Repository) ReservationOverview(w http.ResponseWriter, r *http.Request)
 
 	m.App.Session.Remove(r.Context(), "reservation")
 
-	bungalow, err := m.DB.GetBungalowByID(res.BungalowID)
+	bungalow, err := m.DB.GetBungalowByID(r.Context(), res.BungalowID)
 	if err != nil {
 		m.App.Session.Put(r.Context(), "error", "can't find bungalow!")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
@@ -444,6 +670,18 @@ func (m *Repository) ChooseBungalow(w http.ResponseWriter, r *http.Request) {
 
 	res.BungalowID = bungalowID
 
+	held, err := m.holdBungalow(r, res)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "can't reserve bungalow in database")
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+	if !held {
+		m.App.Session.Put(r.Context(), "error", "Sorry, this holiday home has just been booked by someone else.")
+		http.Redirect(w, r, "/reservation", http.StatusSeeOther)
+		return
+	}
+
 	m.App.Session.Put(r.Context(), "reservation", res)
 
 	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
@@ -455,32 +693,91 @@ func (m *Repository) BookBungalow(w http.ResponseWriter, r *http.Request) {
 
 	bungalowID, _ := strconv.Atoi(r.URL.Query().Get("id"))
 
-	sd := r.URL.Query().Get("s")
-	ed := r.URL.Query().Get("e")
+	form := forms.New(r.URL.Query())
+	validateStayDates(form, "s", "e")
+	if !form.Valid() {
+		m.App.Session.Put(r.Context(), "error", stayDatesError(form, "s", "e"))
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
 
-	layout := "2006-01-02"
-	startDate, _ := time.Parse(layout, sd)
-	endDate, _ := time.Parse(layout, ed)
+	startDate := form.Date("s")
+	endDate := form.Date("e")
 
 	var res models.Reservation
 
-	bungalow, err := m.DB.GetBungalowByID(bungalowID)
+	bungalow, err := m.DB.GetBungalowByID(r.Context(), bungalowID)
 	if err != nil {
 		m.App.Session.Put(r.Context(), "error", "Cannot find bungalow!")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
+	msg, err := m.checkStayRules(r.Context(), bungalowID, startDate, endDate)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "can't get data from database")
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+	if msg != "" {
+		m.App.Session.Put(r.Context(), "error", msg)
+		http.Redirect(w, r, "/reservation", http.StatusSeeOther)
+		return
+	}
+
 	res.Bungalow.BungalowName = bungalow.BungalowName
+	res.Bungalow.MaxOccupancy = bungalow.MaxOccupancy
 	res.BungalowID = bungalowID
 	res.StartDate = startDate
 	res.EndDate = endDate
 
+	held, err := m.holdBungalow(r, res)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", "can't reserve bungalow in database")
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+	if !held {
+		m.App.Session.Put(r.Context(), "error", "Sorry, this holiday home has just been booked by someone else.")
+		http.Redirect(w, r, "/reservation", http.StatusSeeOther)
+		return
+	}
+
 	m.App.Session.Put(r.Context(), "reservation", res)
 
 	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
 }
 
+// holdBungalow holds the bungalow of a reservation for the guest while the reservation form is filled in,
+// releasing a hold the guest placed before; it returns false if the dates have been taken meanwhile
+func (m *Repository) holdBungalow(r *http.Request, res models.Reservation) (bool, error) {
+	if previousID, ok := m.App.Session.Get(r.Context(), "hold_id").(int); ok {
+		err := m.DB.DeleteHold(r.Context(), previousID)
+		if err != nil {
+			return false, err
+		}
+		m.App.Session.Remove(r.Context(), "hold_id")
+	}
+
+	hold := models.BungalowRestriction{
+		StartDate:  res.StartDate,
+		EndDate:    res.EndDate,
+		BungalowID: res.BungalowID,
+		ExpiresAt:  time.Now().Add(m.App.HoldDuration),
+	}
+
+	holdID, err := m.DB.InsertHold(r.Context(), hold)
+	if err != nil {
+		return false, err
+	}
+	if holdID == 0 {
+		return false, nil
+	}
+
+	m.App.Session.Put(r.Context(), "hold_id", holdID)
+	return true, nil
+}
+
 // ShowLogin shows the login page
 func (m *Repository) ShowLogin(w http.ResponseWriter, r *http.Request) {
 	render.Template(w, r, "login-page.html", &models.TemplateData{
@@ -515,7 +812,7 @@ func (m *Repository) PostShowLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, _, err := m.DB.Authenticate(email, password)
+	id, _, err := m.DB.Authenticate(r.Context(), email, password)
 	if err != nil {
 		m.App.Session.Put(r.Context(), "error", "Invalid login credentials")
 		http.Redirect(w, r, "/user/login", http.StatusTemporaryRedirect)
@@ -536,330 +833,2082 @@ func (m *Repository) Logout(w http.ResponseWriter, r *http.Request) {
 
 }
 
-// AdminDashboard shows an admin dashboard
+// AdminDashboard shows an admin dashboard with the reservation stats of a year and today's arrivals and departures,
+// the charts load their data from AdminDashboardJSON
 func (m *Repository) AdminDashboard(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "admin-dashboard-page.html", &models.TemplateData{})
-}
-
-// AdminNewReservations displays new reservations only in admin area
-func (m *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
+	first := dashboardYear(r)
 
-	reservations, err := m.DB.AllNewReservations()
+	stats, err := m.DB.GetReservationStats(r.Context(), first, first.AddDate(1, 0, 0))
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	data := make(map[string]interface{})
-	data["reservations"] = reservations
-
-	render.Template(w, r, "admin-new-reservations-page.html", &models.TemplateData{
-		Data: data,
-	})
-}
+	// arrivals and departures are those of the current date where the bungalows are
+	today := forms.Today()
 
-// AdminAllReservations displays all reservations in admin area
-func (m *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
+	reservations, err := m.DB.GetArrivalsAndDepartures(r.Context(), today)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
-	reservations, err := m.DB.AllReservations()
+	rejections, err := m.DB.GetSpamRejections(r.Context(), first, first.AddDate(1, 0, 0))
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
+	var arrivals, departures []models.Reservation
+	for _, res := range reservations {
+		if res.StartDate.Equal(today) {
+			arrivals = append(arrivals, res)
+		} else {
+			departures = append(departures, res)
+		}
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["year"] = first.Format("2006")
+	stringMap["previous_year"] = first.AddDate(-1, 0, 0).Format("2006")
+	stringMap["next_year"] = first.AddDate(1, 0, 0).Format("2006")
+	stringMap["average_stay"] = fmt.Sprintf("%.1f", stats.AverageStay)
+	stringMap["average_lead_time"] = fmt.Sprintf("%.0f", stats.AverageLeadTime)
+
 	data := make(map[string]interface{})
-	data["reservations"] = reservations
+	data["stats"] = stats
+	data["arrivals"] = arrivals
+	data["departures"] = departures
+	data["spam"] = rejections
 
-	render.Template(w, r, "admin-all-reservations-page.html", &models.TemplateData{
-		Data: data,
+	render.Template(w, r, "admin-dashboard-page.html", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
 	})
 }
 
-// AdminReservationsCalendar display a calendar with registrations
-func (m *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
-	now := time.Now()
+type dashboardJSON struct {
+	Year      int             `json:"year"`
+	Months    []string        `json:"months"`
+	Occupancy []occupancyJSON `json:"occupancy"`
+	Stats     statsJSON       `json:"stats"`
+}
 
-	if r.URL.Query().Get("y") != "" {
-		year, _ := strconv.Atoi(r.URL.Query().Get("y"))
-		month, _ := strconv.Atoi(r.URL.Query().Get("m"))
-		now = time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+type occupancyJSON struct {
+	Bungalow      string    `json:"bungalow"`
+	NightsBooked  []int     `json:"nights_booked"`
+	OccupancyRate []float64 `json:"occupancy_rate"`
+}
+
+type statsJSON struct {
+	Reservations    int     `json:"reservations"`
+	NightsBooked    int     `json:"nights_booked"`
+	AverageStay     float64 `json:"average_stay"`
+	AverageLeadTime float64 `json:"average_lead_time"`
+	New             int     `json:"new"`
+	Processed       int     `json:"processed"`
+	Confirmed       int     `json:"confirmed"`
+}
+
+// AdminDashboardJSON returns the occupancy per bungalow and month and the reservation stats of a year as JSON
+func (m *Repository) AdminDashboardJSON(w http.ResponseWriter, r *http.Request) {
+	first := dashboardYear(r)
+	last := first.AddDate(1, 0, 0)
+
+	occupancy, err := m.DB.GetOccupancyByMonth(r.Context(), first, last)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
 	}
 
-	data := make(map[string]interface{})
-	data["now"] = now
+	stats, err := m.DB.GetReservationStats(r.Context(), first, last)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
-	next := now.AddDate(0, 1, 0)
-	last := now.AddDate(0, -1, 0)
+	resp := dashboardJSON{
+		Year:      first.Year(),
+		Occupancy: occupancySeries(occupancy),
+		Stats:     statsJSON(stats),
+	}
+	for month := first; month.Before(last); month = month.AddDate(0, 1, 0) {
+		resp.Months = append(resp.Months, month.Format("Jan"))
+	}
 
-	nextMonth := next.Format("01")
-	nextMonthYear := next.Format("2006")
+	output, _ := json.MarshalIndent(resp, "", "    ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(output)
+}
 
-	lastMonth := last.Format("01")
-	lastMonthYear := last.Format("2006")
+// dashboardYear returns the first day of the year requested by y, or of the current year
+func dashboardYear(r *http.Request) time.Time {
+	year, err := strconv.Atoi(r.URL.Query().Get("y"))
+	if err != nil {
+		year = forms.Today().Year()
+	}
+	return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+}
 
-	stringMap := make(map[string]string)
+// occupancySeries groups the monthly occupancy, ordered by bungalow and month, into one series per bungalow
+func occupancySeries(occupancy []models.Occupancy) []occupancyJSON {
+	var series []occupancyJSON
+	var bungalowID int
+	for _, o := range occupancy {
+		if len(series) == 0 || o.Bungalow.ID != bungalowID {
+			series = append(series, occupancyJSON{Bungalow: o.Bungalow.BungalowName})
+			bungalowID = o.Bungalow.ID
+		}
+		s := &series[len(series)-1]
+		s.NightsBooked = append(s.NightsBooked, o.NightsBooked)
+		s.OccupancyRate = append(s.OccupancyRate, math.Round(o.Rate()*10)/10)
+	}
+	return series
+}
 
-	stringMap["next_month"] = nextMonth
-	stringMap["next_month_year"] = nextMonthYear
+// AdminNewReservations displays new reservations only in admin area
+func (m *Repository) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
+	q := reservationQuery(r.URL.Query())
+	q.Status = 0
 
-	stringMap["last_month"] = lastMonth
-	stringMap["last_month_year"] = lastMonthYear
+	m.reservationList(w, r, "new", q)
+}
 
-	stringMap["this_month"] = now.Format("01")
-	stringMap["this_month_year"] = now.Format("2006")
+// AdminAllReservations displays all reservations in admin area
+func (m *Repository) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
+	m.reservationList(w, r, "all", reservationQuery(r.URL.Query()))
+}
 
-	// determinate the first and last days of the month to be displayed
-	currentYear, currentMonth, _ := now.Date()
-	currentLocation := now.Location()
-	firstOfMonth := time.Date(currentYear, currentMonth, 1, 0, 0, 0, 0, currentLocation)
-	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
+// AdminTrashReservations displays the deleted reservations in admin area, they can be restored until they are purged
+func (m *Repository) AdminTrashReservations(w http.ResponseWriter, r *http.Request) {
+	q := reservationQuery(r.URL.Query())
+	q.Deleted = true
 
-	intMap := make(map[string]int)
-	intMap["days_in_month"] = lastOfMonth.Day()
+	m.reservationList(w, r, "trash", q)
+}
 
-	bungalows, err := m.DB.AllBungalows()
+// reservationList renders a page of the reservations selected by a query, src is the list shown
+func (m *Repository) reservationList(w http.ResponseWriter, r *http.Request, src string, q models.ReservationQuery) {
+	page, err := m.DB.GetReservations(r.Context(), q)
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	data["bungalows"] = bungalows
-
-	for _, x := range bungalows {
-		// create maps (one for reservations, one for blocked days)
-		reservationMap := make(map[string]int)
-		blockMap := make(map[string]int)
-
-		// iterate over all days with for-loop over dates and fill the maps
-		for d := firstOfMonth; d.After(lastOfMonth) == false; d = d.AddDate(0, 0, 1) {
-			reservationMap[d.Format("2006-01-2")] = 0
-			blockMap[d.Format("2006-01-2")] = 0
+	bungalows, err := m.DB.AllBungalows(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	params := listParams(q)
+
+	stringMap := make(map[string]string)
+	stringMap["src"] = src
+	stringMap["list_url"] = listURL(src, params)
+	stringMap["retention_days"] = strconv.Itoa(int(m.App.ReservationRetention.Hours() / 24))
+
+	// the links to the reservations carry the list parameters so the list can be returned to
+	data := make(map[string]interface{})
+	data["page"] = page
+	data["reservations"] = page.Reservations
+	data["bungalows"] = bungalows
+	data["list_query"] = template.URL(params.Encode())
+
+	// sorting by a column restarts at the first page, sorting by the current column again reverses the order
+	sortURLs := make(map[string]string)
+	for _, column := range models.ReservationSortColumns {
+		sorted := q
+		sorted.Sort = column
+		sorted.Desc = column == q.Sort && !q.Desc
+		sorted.Page = 1
+		sortURLs[column] = listURL(src, listParams(sorted))
+	}
+	data["sort_urls"] = sortURLs
+
+	if page.HasPrevious() {
+		previous := q
+		previous.Page--
+		stringMap["previous_url"] = listURL(src, listParams(previous))
+	}
+	if page.HasNext() {
+		next := q
+		next.Page++
+		stringMap["next_url"] = listURL(src, listParams(next))
+	}
+
+	render.Template(w, r, fmt.Sprintf("admin-%s-reservations-page.html", src), &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// AdminExportReservations streams the reservations selected by the filters of the reservation list as a csv
+// or xlsx file, depending on the format parameter
+func (m *Repository) AdminExportReservations(w http.ResponseWriter, r *http.Request) {
+	q := reservationQuery(r.URL.Query())
+	q.Page = 1
+	q.PerPage = 0
+
+	format := r.URL.Query().Get("format")
+	if format != "xlsx" {
+		format = "csv"
+	}
+	filename := fmt.Sprintf("reservations-%s.%s", time.Now().Format("2006-01-02"), format)
+
+	if format == "xlsx" {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	}
+
+	// the file is only started with the first row, until then a failing query can still be reported
+	var writer export.Writer
+	open := func() error {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if format == "xlsx" {
+			var err error
+			writer, err = export.NewXLSX(w)
+			if err != nil {
+				return err
+			}
+		} else {
+			writer = export.NewCSV(w)
+		}
+		return writer.Write(exportHeader)
+	}
+
+	err := m.DB.ExportReservations(r.Context(), q, func(res models.Reservation) error {
+		if writer == nil {
+			err := open()
+			if err != nil {
+				return err
+			}
+		}
+		return writer.Write(exportRow(res))
+	})
+	if err != nil && writer == nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	// an export without reservations still has a header
+	if err == nil && writer == nil {
+		err = open()
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "export of reservations aborted", "error", err)
+	}
+}
+
+// maxImportSize is the largest csv file accepted by the reservation import
+const maxImportSize = 1 << 20
+
+// AdminImportReservations shows the form to upload a csv file of reservations
+func (m *Repository) AdminImportReservations(w http.ResponseWriter, r *http.Request) {
+	render.Template(w, r, "admin-reservations-import-page.html", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// AdminPostImportReservations previews the reservations of an uploaded csv file with the errors of each row, once
+// confirmed it imports all valid rows at once and reports what was imported and what was skipped
+func (m *Repository) AdminPostImportReservations(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseMultipartForm(maxImportSize)
+	if err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	// the file is uploaded for the preview and posted back as text to be imported
+	content := r.Form.Get("csv")
+	file, header, err := r.FormFile("file")
+	if err == nil {
+		defer file.Close()
+		if header.Size > maxImportSize {
+			m.App.Session.Put(r.Context(), "error", "The file is too large.")
+			http.Redirect(w, r, "/admin/reservations/import", http.StatusSeeOther)
+			return
+		}
+		b, err := io.ReadAll(file)
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+		content = string(b)
+	}
+
+	records, err := parseImportFile(content)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", fmt.Sprintf("The file can't be imported: %s.", err))
+		http.Redirect(w, r, "/admin/reservations/import", http.StatusSeeOther)
+		return
+	}
+
+	rows, err := m.validateImportRows(r.Context(), records)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	var valid []models.Reservation
+	var skipped []models.ImportRow
+	for _, row := range rows {
+		if row.Valid() {
+			valid = append(valid, row.Reservation)
+		} else {
+			skipped = append(skipped, row)
+		}
+	}
+
+	stringMap := make(map[string]string)
+	stringMap["csv"] = content
+
+	data := make(map[string]interface{})
+	data["rows"] = rows
+	data["skipped"] = skipped
+
+	intMap := make(map[string]int)
+	intMap["valid"] = len(valid)
+	intMap["skipped"] = len(skipped)
+
+	if r.Form.Get("confirm") == "" || len(valid) == 0 {
+		render.Template(w, r, "admin-reservations-import-page.html", &models.TemplateData{
+			StringMap: stringMap,
+			IntMap:    intMap,
+			Data:      data,
+			Form:      forms.New(nil),
+		})
+		return
+	}
+
+	ids, err := m.DB.ImportReservations(r.Context(), valid, m.actor(r))
+	var conflict *repository.ImportConflictError
+	if errors.As(err, &conflict) {
+		res := valid[conflict.Index]
+		m.App.Session.Put(r.Context(), "error", fmt.Sprintf("Nothing was imported, %s is not available from %s to %s anymore.",
+			res.Bungalow.BungalowName, res.StartDate.Format("2006-01-02"), res.EndDate.Format("2006-01-02")))
+		http.Redirect(w, r, "/admin/reservations/import", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	m.App.Metrics.ReservationsCreated.Add(float64(len(ids)), "import")
+
+	stringMap["report"] = "1"
+	intMap["imported"] = len(ids)
+
+	render.Template(w, r, "admin-reservations-import-page.html", &models.TemplateData{
+		StringMap: stringMap,
+		IntMap:    intMap,
+		Data:      data,
+		Form:      forms.New(nil),
+	})
+}
+
+// importColumns maps the columns of an imported csv file to the fields of the reservation form,
+// the columns are named like in the export
+var importColumns = []struct {
+	column   string
+	field    string
+	required bool
+}{
+	{"full name", "full_name", true},
+	{"email", "email", true},
+	{"phone", "phone", false},
+	{"bungalow", "bungalow", true},
+	{"arrival", "start_date", true},
+	{"departure", "end_date", true},
+	{"adults", "adults", true},
+	{"children", "children", false},
+	{"infants", "infants", false},
+}
+
+// parseImportFile reads the rows of a csv file of reservations as form values, other columns are ignored
+func parseImportFile(content string) ([]url.Values, error) {
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(content, "\ufeff")))
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, errors.New("there are no reservations in the file")
+	}
+
+	positions := make(map[string]int)
+	for i, column := range records[0] {
+		positions[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, c := range importColumns {
+		if _, ok := positions[c.column]; !ok && c.required {
+			return nil, fmt.Errorf("the column %q is missing", c.column)
+		}
+	}
+
+	var rows []url.Values
+	for _, record := range records[1:] {
+		values := url.Values{}
+		for _, c := range importColumns {
+			if i, ok := positions[c.column]; ok {
+				values.Set(c.field, strings.TrimSpace(export.Unescape(record[i])))
+			}
+		}
+		rows = append(rows, values)
+	}
+
+	return rows, nil
+}
+
+// validateImportRows checks the reservations of an imported file like PostMakeReservation does, and
+// that their bungalows are available and don't overlap each other
+func (m *Repository) validateImportRows(ctx context.Context, records []url.Values) ([]models.ImportRow, error) {
+	bungalows, err := m.DB.AllBungalows(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []models.ImportRow
+
+	for i, values := range records {
+		// the header is line 1
+		row := models.ImportRow{Line: i + 2}
+
+		var res models.Reservation
+		form, err := forms.Bind(values, &res)
+		if err != nil {
+			return nil, err
+		}
+		form.Required("bungalow", "start_date", "end_date")
+		form.IsDate("start_date", "end_date")
+		form.DateAfter("start_date", "end_date")
+		res.StartDate = form.Date("start_date")
+		res.EndDate = form.Date("end_date")
+
+		// bungalows are given by name like in the export, or by id
+		for _, b := range bungalows {
+			if strings.EqualFold(b.BungalowName, values.Get("bungalow")) || strconv.Itoa(b.ID) == values.Get("bungalow") {
+				res.BungalowID = b.ID
+				res.Bungalow = b
+			}
+		}
+		if res.BungalowID == 0 && form.Has("bungalow") {
+			form.Errors.Add("bungalow", "There is no such bungalow.")
+		}
+		if res.Bungalow.MaxOccupancy > 0 && res.Guests() > res.Bungalow.MaxOccupancy {
+			form.Errors.Add("adults", fmt.Sprintf("This holiday home can host at most %d guests, infants not counted.", res.Bungalow.MaxOccupancy))
+		}
+
+		row.Errors = importFormErrors(form)
+
+		if form.Valid() {
+			ruleMessage, err := m.checkStayRules(ctx, res.BungalowID, res.StartDate, res.EndDate)
+			if err != nil {
+				return nil, err
+			}
+			if ruleMessage != "" {
+				row.Errors = append(row.Errors, ruleMessage)
+			}
+
+			available, err := m.DB.SearchAvailabilityByDatesByBungalowID(ctx, res.StartDate, res.EndDate, res.BungalowID)
+			if err != nil {
+				return nil, err
+			}
+			if !available {
+				row.Errors = append(row.Errors, "The bungalow is already reserved or blocked on these days.")
+			}
+
+			for _, other := range rows {
+				o := other.Reservation
+				if other.Valid() && o.BungalowID == res.BungalowID && !res.StartDate.After(o.EndDate) && !res.EndDate.Before(o.StartDate) {
+					row.Errors = append(row.Errors, fmt.Sprintf("The stay overlaps the reservation in line %d.", other.Line))
+				}
+			}
+		}
+
+		row.Reservation = res
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// importFormErrors returns the error messages of an imported row labeled with their columns
+func importFormErrors(form *forms.Form) []string {
+	labels := []struct{ field, label string }{
+		{"full_name", "Full Name"},
+		{"email", "Email"},
+		{"phone", "Phone"},
+		{"bungalow", "Bungalow"},
+		{"start_date", "Arrival"},
+		{"end_date", "Departure"},
+		{"adults", "Adults"},
+		{"children", "Children"},
+		{"infants", "Infants"},
+	}
+	var messages []string
+	for _, l := range labels {
+		for _, msg := range form.Errors[l.field] {
+			messages = append(messages, fmt.Sprintf("%s: %s", l.label, msg))
+		}
+	}
+	return messages
+}
+
+// exportHeader is the header row of exported reservations
+var exportHeader = []string{"ID", "Full Name", "Email", "Phone", "Bungalow", "Arrival", "Departure", "Nights",
+	"Adults", "Children", "Infants", "Status", "Booked"}
+
+// exportRow returns the row of an exported reservation
+func exportRow(res models.Reservation) []string {
+	return []string{
+		strconv.Itoa(res.ID),
+		res.FullName,
+		res.Email,
+		res.Phone,
+		res.Bungalow.BungalowName,
+		res.StartDate.Format("2006-01-02"),
+		res.EndDate.Format("2006-01-02"),
+		strconv.Itoa(int(res.EndDate.Sub(res.StartDate).Hours() / 24)),
+		strconv.Itoa(res.Adults),
+		strconv.Itoa(res.Children),
+		strconv.Itoa(res.Infants),
+		res.StatusName(),
+		res.CreatedAt.Format("2006-01-02 15:04"),
+	}
+}
+
+// reservationQuery reads the query of a reservation list from its url parameters, invalid parameters are ignored
+func reservationQuery(values url.Values) models.ReservationQuery {
+	q := models.ReservationQuery{
+		Search:  strings.TrimSpace(values.Get("search")),
+		Status:  models.ReservationStatusAny,
+		Sort:    "start_date",
+		Page:    1,
+		PerPage: 25,
+	}
+
+	q.BungalowID, _ = strconv.Atoi(values.Get("bungalow"))
+	if status, err := strconv.Atoi(values.Get("status")); err == nil {
+		q.Status = status
+	}
+	q.From, _ = time.Parse("2006-01-02", values.Get("from"))
+	q.To, _ = time.Parse("2006-01-02", values.Get("to"))
+
+	for _, column := range models.ReservationSortColumns {
+		if values.Get("sort") == column {
+			q.Sort = column
+		}
+	}
+	q.Desc = values.Get("order") == "desc"
+
+	if page, err := strconv.Atoi(values.Get("page")); err == nil && page > 1 {
+		q.Page = page
+	}
+	if perPage, err := strconv.Atoi(values.Get("per_page")); err == nil && perPage > 0 && perPage <= 100 {
+		q.PerPage = perPage
+	}
+
+	return q
+}
+
+// listParams returns the url parameters of a reservation list query, defaults are left out
+func listParams(q models.ReservationQuery) url.Values {
+	params := url.Values{}
+	if q.Search != "" {
+		params.Set("search", q.Search)
+	}
+	if q.BungalowID > 0 {
+		params.Set("bungalow", strconv.Itoa(q.BungalowID))
+	}
+	if q.Status != models.ReservationStatusAny {
+		params.Set("status", strconv.Itoa(q.Status))
+	}
+	if !q.From.IsZero() {
+		params.Set("from", q.From.Format("2006-01-02"))
+	}
+	if !q.To.IsZero() {
+		params.Set("to", q.To.Format("2006-01-02"))
+	}
+	if q.Sort != "start_date" {
+		params.Set("sort", q.Sort)
+	}
+	if q.Desc {
+		params.Set("order", "desc")
+	}
+	if q.Page > 1 {
+		params.Set("page", strconv.Itoa(q.Page))
+	}
+	if q.PerPage != 25 {
+		params.Set("per_page", strconv.Itoa(q.PerPage))
+	}
+	return params
+}
+
+// listURL returns the url of a reservation list with its parameters
+func listURL(src string, params url.Values) string {
+	if len(params) == 0 {
+		return fmt.Sprintf("/admin/reservations-%s", src)
+	}
+	return fmt.Sprintf("/admin/reservations-%s?%s", src, params.Encode())
+}
+
+// AdminReservationsCalendar displays a timeline of the reservations and blocks of all bungalows
+// for a week, a month or a quarter
+func (m *Repository) AdminReservationsCalendar(w http.ResponseWriter, r *http.Request) {
+	view := r.URL.Query().Get("view")
+	if view != "week" && view != "quarter" {
+		view = "month"
+	}
+
+	day := forms.Today()
+
+	if r.URL.Query().Get("y") != "" {
+		year, _ := strconv.Atoi(r.URL.Query().Get("y"))
+		month, _ := strconv.Atoi(r.URL.Query().Get("m"))
+		d, _ := strconv.Atoi(r.URL.Query().Get("d"))
+		if d == 0 {
+			d = 1
+		}
+		day = time.Date(year, time.Month(month), d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// determinate the first and last days to be displayed and the first days of the views before and after
+	var first, last, previous, next time.Time
+	stringMap := make(map[string]string)
+
+	switch view {
+	case "week":
+		first = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		last = first.AddDate(0, 0, 6)
+		previous = first.AddDate(0, 0, -7)
+		next = first.AddDate(0, 0, 7)
+		stringMap["title"] = fmt.Sprintf("Week of %s", first.Format("2 January 2006"))
+	case "quarter":
+		first = time.Date(day.Year(), day.Month()-(day.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+		last = first.AddDate(0, 3, -1)
+		previous = first.AddDate(0, -3, 0)
+		next = first.AddDate(0, 3, 0)
+		stringMap["title"] = fmt.Sprintf("Q%d %d", (first.Month()-1)/3+1, first.Year())
+	default:
+		first = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		last = first.AddDate(0, 1, -1)
+		previous = first.AddDate(0, -1, 0)
+		next = first.AddDate(0, 1, 0)
+		stringMap["title"] = first.Format("January 2006")
+	}
+
+	stringMap["view"] = view
+	stringMap["this_day"] = first.Format("2")
+	stringMap["this_month"] = first.Format("01")
+	stringMap["this_month_year"] = first.Format("2006")
+	stringMap["previous_url"] = calendarURL(view, previous.Format("2006"), previous.Format("01"), previous.Format("2"))
+	stringMap["next_url"] = calendarURL(view, next.Format("2006"), next.Format("01"), next.Format("2"))
+
+	bungalows, err := m.DB.AllBungalows(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	restrictionTypes, err := m.DB.AllRestrictions(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	var blockTypes []models.Restriction
+	for _, t := range restrictionTypes {
+		if t.IsBlock() {
+			blockTypes = append(blockTypes, t)
+		}
+	}
+
+	// read in the restrictions of all bungalows at once
+	restrictions, err := m.DB.GetRestrictionsByDate(r.Context(), first, last)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	rows := timelineRows(bungalows, restrictions, first, last)
+
+	var days []time.Time
+	for d := first; d.After(last) == false; d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+
+	data := make(map[string]interface{})
+	data["bungalows"] = bungalows
+	data["rows"] = rows
+	data["days"] = days
+	data["restriction_types"] = restrictionTypes
+	data["block_types"] = blockTypes
+
+	intMap := make(map[string]int)
+	intMap["days"] = len(days)
+
+	render.Template(w, r, "admin-reservations-calendar-page.html", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+		IntMap:    intMap,
+	})
+}
+
+// timelineRows arranges the restrictions between the first and the last day as one timeline row per bungalow,
+// reservations are labelled with guest and status and blocks with their type and note
+func timelineRows(bungalows []models.Bungalow, restrictions []models.BungalowRestriction, first, last time.Time) []models.TimelineRow {
+	var rows []models.TimelineRow
+
+	for _, b := range bungalows {
+		row := models.TimelineRow{Bungalow: b}
+		for d := first; d.After(last) == false; d = d.AddDate(0, 0, 1) {
+			row.Days = append(row.Days, models.TimelineDay{Date: d})
+		}
+
+		for _, y := range restrictions {
+			if y.BungalowID != b.ID {
+				continue
+			}
+
+			// cut the restriction to the days displayed
+			start, end := y.StartDate, y.EndDate
+			if start.Before(first) {
+				start = first
+			}
+			if end.After(last) {
+				end = last
+			}
+			if end.Before(start) {
+				continue
+			}
+
+			column := int(start.Sub(first).Hours()/24) + 1
+			span := int(end.Sub(start).Hours()/24) + 1
+
+			bar := models.TimelineBar{
+				Restriction: y,
+				Column:      column,
+				Span:        span,
+			}
+
+			if y.IsReservation() {
+				bar.Label = fmt.Sprintf("%s (%s)", y.Reservation.FullName, y.Reservation.StatusName())
+			} else {
+				bar.Label = y.Restriction.RestrictionName
+				if y.Note != "" {
+					bar.Label = fmt.Sprintf("%s: %s", bar.Label, y.Note)
+				}
+			}
+
+			for i := column - 1; i < column-1+span; i++ {
+				if y.IsReservation() {
+					row.Days[i].Reserved = true
+				} else if y.Restriction.BlocksAvailability {
+					row.Days[i].BlockID = y.ID
+				}
+			}
+
+			row.Bars = append(row.Bars, bar)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// AdminCreateReservation displays the form for staff to enter a reservation taken over the phone,
+// the fields can be prefilled by url parameters
+func (m *Repository) AdminCreateReservation(w http.ResponseWriter, r *http.Request) {
+	bungalows, err := m.DB.AllBungalows(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["bungalows"] = bungalows
+
+	stringMap := make(map[string]string)
+	stringMap["send_email"] = "1"
+
+	render.Template(w, r, "admin-reservations-create-page.html", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+		Form:      forms.New(r.URL.Query()),
+	})
+}
+
+// AdminPostCreateReservation creates a confirmed reservation entered by staff, conflicts with other reservations
+// and blocks are shown on the form
+func (m *Repository) AdminPostCreateReservation(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	bungalows, err := m.DB.AllBungalows(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	var res models.Reservation
+	form, err := forms.Bind(r.PostForm, &res)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	form.Required("bungalow_id")
+	validateStayDates(form, "start_date", "end_date")
+	res.StartDate = form.Date("start_date")
+	res.EndDate = form.Date("end_date")
+	res.Status = models.ReservationConfirmed
+
+	res.BungalowID, _ = strconv.Atoi(form.Get("bungalow_id"))
+	for _, b := range bungalows {
+		if b.ID == res.BungalowID {
+			res.Bungalow = b
+		}
+	}
+	if res.Bungalow.ID == 0 && form.Has("bungalow_id") {
+		form.Errors.Add("bungalow_id", "There is no such bungalow.")
+	}
+	if res.Bungalow.MaxOccupancy > 0 && res.Guests() > res.Bungalow.MaxOccupancy {
+		form.Errors.Add("adults", fmt.Sprintf("This holiday home can host at most %d guests, infants not counted.", res.Bungalow.MaxOccupancy))
+	}
+
+	var conflicts []string
+	if form.Valid() {
+		ruleMessage, err := m.checkStayRules(r.Context(), res.BungalowID, res.StartDate, res.EndDate)
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+		if ruleMessage != "" {
+			form.Errors.Add("start_date", ruleMessage)
+		}
+
+		conflicts, err = m.stayConflicts(r.Context(), res.BungalowID, res.StartDate, res.EndDate)
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+		if len(conflicts) > 0 {
+			form.Errors.Add("start_date", "The bungalow is already reserved or blocked on these days.")
+		}
+	}
+
+	// a reservation made from an inquiry answers it
+	inquiryID, _ := strconv.Atoi(form.Get("inquiry_id"))
+
+	var newID int
+	if form.Valid() {
+		newID, err = m.DB.CreateReservation(r.Context(), res, strings.TrimSpace(form.Get("notes")), inquiryID, m.actor(r))
+		if errors.Is(err, repository.ErrNotAvailable) {
+			form.Errors.Add("start_date", "The bungalow has been reserved or blocked on these days in the meantime.")
+		} else if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		data := make(map[string]interface{})
+		data["bungalows"] = bungalows
+		data["conflicts"] = conflicts
+
+		stringMap := make(map[string]string)
+		stringMap["send_email"] = form.Get("send_email")
+
+		render.Template(w, r, "admin-reservations-create-page.html", &models.TemplateData{
+			StringMap: stringMap,
+			Data:      data,
+			Form:      form,
+		})
+		return
+	}
+	m.App.Metrics.ReservationsCreated.Inc("admin")
+
+	if form.Has("send_email") {
+		res.ID = newID
+		body := fmt.Sprintf("Dear %s,\n\nwe confirm your reservation of our bungalow \"%s\" from %s to %s.",
+			res.FullName, res.Bungalow.BungalowName, res.StartDate.Format("2006-01-02"), res.EndDate.Format("2006-01-02"))
+
+		err = m.mailGuest(r, res, "Confirmation of your reservation", body)
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+	}
+
+	m.App.Session.Put(r.Context(), "success", "Reservation successfully created")
+	http.Redirect(w, r, fmt.Sprintf("/admin/reservations/all/%d/show", newID), http.StatusSeeOther)
+}
+
+// stayConflicts describes the reservations and blocks of a bungalow overlapping a stay, it is empty if the bungalow is available
+func (m *Repository) stayConflicts(ctx context.Context, bungalowID int, start, end time.Time) ([]string, error) {
+	available, err := m.DB.SearchAvailabilityByDatesByBungalowID(ctx, start, end, bungalowID)
+	if err != nil || available {
+		return nil, err
+	}
+
+	restrictions, err := m.DB.GetRestrictionsByDate(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for _, br := range restrictions {
+		if br.BungalowID != bungalowID || !br.Restriction.BlocksAvailability {
+			continue
+		}
+		label := br.Restriction.RestrictionName
+		if br.IsReservation() {
+			label = fmt.Sprintf("Reservation of %s", br.Reservation.FullName)
+		} else if br.Note != "" {
+			label += ": " + br.Note
+		}
+		conflicts = append(conflicts, fmt.Sprintf("%s from %s to %s", label, br.StartDate.Format("2006-01-02"), br.EndDate.Format("2006-01-02")))
+	}
+
+	// restrictions hidden from the calendar like holds block the days too
+	if len(conflicts) == 0 {
+		conflicts = append(conflicts, "Another guest is booking these days right now")
+	}
+
+	return conflicts, nil
+}
+
+// AdminShowReservation shows a reservation in the admin area
+func (m *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Request) {
+
+	exploded := strings.Split(r.RequestURI, "/")
+
+	id, err := strconv.Atoi(exploded[4])
+	if err != nil {
+		helpers.ServerError(w, r, err)
+    http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	res, err := m.DB.GetReservationByID(r.Context(), id)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+    http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		return
+	}
+
+	history, err := m.DB.GetAuditEvents(r.Context(), models.AuditQuery{ReservationID: id})
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	bungalows, err := m.DB.AllBungalows(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	notes, err := m.DB.GetReservationNotes(r.Context(), id)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	messages, err := m.DB.GetReservationMessages(r.Context(), id)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["reservation"] = res
+	data["history"] = history.Events
+	data["bungalows"] = bungalows
+	data["notes"] = notes
+	data["messages"] = messages
+
+	src := exploded[3]
+
+	stringMap := make(map[string]string)
+	stringMap["src"] = src
+	stringMap["start_date"] = res.StartDate.Format("2006-01-02")
+	stringMap["end_date"] = res.EndDate.Format("2006-01-02")
+
+	year := r.URL.Query().Get("y")
+	month := r.URL.Query().Get("m")
+
+	stringMap["month"] = month
+	stringMap["year"] = year
+
+	params := listParams(reservationQuery(r.URL.Query()))
+	stringMap["list_url"] = listURL(src, params)
+	stringMap["list_query"] = params.Encode()
+
+	// after restoring, the trash list the reservation was opened from is shown again
+	if res.Deleted() {
+		stringMap["restore_url"] = fmt.Sprintf("/admin/restore-reservation/%d/do", id)
+		if len(params) > 0 {
+			stringMap["restore_url"] += "?" + params.Encode()
+		}
+	}
+
+	render.Template(w, r, "admin-reservations-show-page.html", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+		Form:      forms.New(nil),
+	})
+}
+
+// AdminPostShowReservation handles a post request to update a reservation, moving the stay to other dates
+// or another bungalow if they are still free
+func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	exploded := strings.Split(r.RequestURI, "/")
+
+	id, err := strconv.Atoi(exploded[4])
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	src := exploded[3]
+
+	res, err := m.DB.GetReservationByID(r.Context(), id)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	bungalows, err := m.DB.AllBungalows(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	month := r.Form.Get("month")
+	year := r.Form.Get("year")
+	listValues, _ := url.ParseQuery(r.Form.Get("list_query"))
+	params := listParams(reservationQuery(listValues))
+
+	previous := res
+
+	form, err := forms.Bind(r.PostForm, &res)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	// past stays can be edited too, so the arrival is not checked against today
+	form.Required("start_date", "end_date", "bungalow_id")
+	form.IsDate("start_date", "end_date")
+	form.DateAfter("start_date", "end_date")
+	res.StartDate = form.Date("start_date")
+	res.EndDate = form.Date("end_date")
+
+	res.BungalowID, _ = strconv.Atoi(form.Get("bungalow_id"))
+	res.Bungalow = models.Bungalow{}
+	for _, b := range bungalows {
+		if b.ID == res.BungalowID {
+			res.Bungalow = b
+		}
+	}
+	if res.Bungalow.ID == 0 && form.Has("bungalow_id") {
+		form.Errors.Add("bungalow_id", "There is no such bungalow.")
+	}
+	if res.Bungalow.MaxOccupancy > 0 && res.Guests() > res.Bungalow.MaxOccupancy {
+		form.Errors.Add("adults", fmt.Sprintf("This holiday home can host at most %d guests, infants not counted.", res.Bungalow.MaxOccupancy))
+	}
+
+	if form.Valid() {
+		available, err := m.DB.UpdateReservation(r.Context(), res, m.actor(r))
+		if errors.Is(err, repository.ErrNotFound) {
+			m.App.Session.Put(r.Context(), "error", "The reservation has been deleted meanwhile, no changes were saved.")
+			http.Redirect(w, r, listURL(src, params), http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+		if !available {
+			form.Errors.Add("start_date", "The bungalow is already reserved or blocked on these days.")
+		}
+	}
+
+	if !form.Valid() {
+		data := make(map[string]interface{})
+		data["reservation"] = res
+		data["bungalows"] = bungalows
+
+		stringMap := make(map[string]string)
+		stringMap["src"] = src
+		stringMap["month"] = month
+		stringMap["year"] = year
+		stringMap["list_url"] = listURL(src, params)
+		stringMap["list_query"] = params.Encode()
+		stringMap["start_date"] = form.Get("start_date")
+		stringMap["end_date"] = form.Get("end_date")
+
+		render.Template(w, r, "admin-reservations-show-page.html", &models.TemplateData{
+			StringMap: stringMap,
+			Data:      data,
+			Form:      form,
+		})
+		return
+	}
+
+	if form.Has("notify_guest") {
+		err = m.sendReservationChangedMail(r, previous, res)
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+	}
+
+	m.App.Session.Put(r.Context(), "success", "Changes successfully saved")
+
+	if year == "" {
+		http.Redirect(w, r, listURL(src, params), http.StatusSeeOther)
+	} else {
+		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	}
+}
+
+// sendReservationChangedMail tells the guest about a change of their reservation, naming the previous stay if it was moved
+func (m *Repository) sendReservationChangedMail(r *http.Request, previous, res models.Reservation) error {
+	body := fmt.Sprintf("Dear %s,\n\nyour reservation of our bungalow \"%s\" is now from %s to %s.",
+		res.FullName, res.Bungalow.BungalowName, res.StartDate.Format("2006-01-02"), res.EndDate.Format("2006-01-02"))
+
+	if previous.BungalowID != res.BungalowID || !previous.StartDate.Equal(res.StartDate) || !previous.EndDate.Equal(res.EndDate) {
+		body += fmt.Sprintf("\nIt replaces your stay in \"%s\" from %s to %s.",
+			previous.Bungalow.BungalowName, previous.StartDate.Format("2006-01-02"), previous.EndDate.Format("2006-01-02"))
+	}
+
+	return m.mailGuest(r, res, "Your reservation has been changed", body)
+}
+
+// mailGuest queues a plain text message to the guest of a reservation and records it on the reservation,
+// the mail listener updates its delivery status
+func (m *Repository) mailGuest(r *http.Request, res models.Reservation, subject, body string) error {
+	actor := m.actor(r)
+	messageID, err := m.DB.InsertReservationMessage(r.Context(), models.ReservationMessage{
+		ReservationID: res.ID,
+		UserID:        actor.UserID,
+		To:            res.Email,
+		Subject:       subject,
+		Body:          body,
+	}, actor)
+	if err != nil {
+		return err
+	}
+
+	htmlMessage := fmt.Sprintf("<strong>%s</strong><br><br>%s",
+		template.HTMLEscapeString(subject), strings.ReplaceAll(template.HTMLEscapeString(body), "\n", "<br>"))
+
+	msg := models.MailData{
+		To:        res.Email,
+		From:      "noreply@bungalow-bliss.com",
+		Subject:   subject,
+		Content:   htmlMessage,
+		RequestID: logging.RequestID(r.Context()),
+		MessageID: messageID,
+	}
+
+	// the request does not wait for a full mail queue, the message is recorded as failed instead
+	select {
+	case m.App.MailChan <- msg:
+	default:
+		m.App.Logger.WarnContext(r.Context(), "mail queue full, message to the guest not sent", "message_id", messageID)
+		m.App.Metrics.MailsSent.Inc(metrics.MailFailed)
+		return m.DB.UpdateReservationMessageStatus(r.Context(), messageID, models.MessageFailed)
+	}
+
+	return nil
+}
+
+// showURL returns the url of a reservation opened from a list or the calendar, carrying what is needed to return there
+func showURL(src string, id int, year, month string, params url.Values) string {
+	if year != "" {
+		return fmt.Sprintf("/admin/reservations/%s/%d/show?y=%s&m=%s", src, id, year, month)
+	}
+	if len(params) == 0 {
+		return fmt.Sprintf("/admin/reservations/%s/%d/show", src, id)
+	}
+	return fmt.Sprintf("/admin/reservations/%s/%d/show?%s", src, id, params.Encode())
+}
+
+// AdminPostReservationNote adds an internal note to a reservation
+func (m *Repository) AdminPostReservationNote(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	src := chi.URLParam(r, "src")
+	listValues, _ := url.ParseQuery(r.Form.Get("list_query"))
+	returnURL := showURL(src, id, r.Form.Get("year"), r.Form.Get("month"), listParams(reservationQuery(listValues)))
+
+	note := models.ReservationNote{ReservationID: id, UserID: m.actor(r).UserID}
+	form, err := forms.Bind(r.PostForm, &note)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		m.App.Session.Put(r.Context(), "error", fmt.Sprintf("Note: %s", form.Errors.Get("body")))
+		http.Redirect(w, r, returnURL, http.StatusSeeOther)
+		return
+	}
+
+	err = m.DB.InsertReservationNote(r.Context(), note, m.actor(r))
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "success", "Note added")
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+}
+
+// AdminPostReservationMessage emails a free-form message to the guest of a reservation
+func (m *Repository) AdminPostReservationMessage(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	src := chi.URLParam(r, "src")
+	listValues, _ := url.ParseQuery(r.Form.Get("list_query"))
+	returnURL := showURL(src, id, r.Form.Get("year"), r.Form.Get("month"), listParams(reservationQuery(listValues)))
+
+	res, err := m.DB.GetReservationByID(r.Context(), id)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	var msg models.ReservationMessage
+	form, err := forms.Bind(r.PostForm, &msg)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		for _, field := range []string{"subject", "body"} {
+			if e := form.Errors.Get(field); e != "" {
+				m.App.Session.Put(r.Context(), "error", fmt.Sprintf("Message %s: %s", field, e))
+				break
+			}
+		}
+		http.Redirect(w, r, returnURL, http.StatusSeeOther)
+		return
+	}
+
+	err = m.mailGuest(r, res, msg.Subject, msg.Body)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "success", fmt.Sprintf("Message to %s queued for sending", res.Email))
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+}
+
+// AdminProcessReservation changes the status of a reservation to processed
+func (m *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.Request) {
+
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	src := chi.URLParam(r, "src")
+
+	year := r.URL.Query().Get("y")
+	month := r.URL.Query().Get("m")
+
+	err := m.DB.UpdateStatusOfReservation(r.Context(), id, models.ReservationProcessed, m.actor(r))
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "could not process reservation", "id", id, "error", err)
+		m.App.Session.Put(r.Context(), "error", "The reservation could not be marked as processed")
+	} else {
+		m.App.Session.Put(r.Context(), "success", "Reservation successfully marked as processed")
+	}
+
+	if year == "" {
+		http.Redirect(w, r, listURL(src, listParams(reservationQuery(r.URL.Query()))), http.StatusSeeOther)
+	} else {
+		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	}
+}
+
+// AdminDeleteReservation moves a reservation to the trash
+func (m *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Request) {
+
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	src := chi.URLParam(r, "src")
+
+	err := m.DB.DeleteReservation(r.Context(), id, m.actor(r))
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	year := r.URL.Query().Get("y")
+	month := r.URL.Query().Get("m")
+
+	m.App.Session.Put(r.Context(), "success", "Reservation moved to the trash")
+
+	if year == "" {
+		http.Redirect(w, r, listURL(src, listParams(reservationQuery(r.URL.Query()))), http.StatusSeeOther)
+	} else {
+		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	}
+}
+
+// AdminRestoreReservation takes a reservation out of the trash, unless its dates have been taken in the meantime
+func (m *Repository) AdminRestoreReservation(w http.ResponseWriter, r *http.Request) {
+
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	restored, err := m.DB.RestoreReservation(r.Context(), id, m.actor(r))
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	if restored {
+		m.App.Session.Put(r.Context(), "success", "Reservation successfully restored")
+	} else {
+		m.App.Session.Put(r.Context(), "error", "The reservation can't be restored, its dates are not available anymore")
+	}
+
+	http.Redirect(w, r, listURL("trash", listParams(reservationQuery(r.URL.Query()))), http.StatusSeeOther)
+}
+
+// AdminPostReservationsCalendar applies the block changes posted from the reservation calendar, blocks to remove
+// are posted by id and days to block with their bungalow, so blocks removed or days taken meanwhile by someone else
+// are detected as conflicts instead of being overwritten
+func (m *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	returnURL := calendarURL(r.Form.Get("view"), r.Form.Get("y"), r.Form.Get("m"), r.Form.Get("d"))
+
+	// removing blocks, posted by id
+	var conflicts []string
+	for _, op := range r.PostForm["remove_block"] {
+		id, err := strconv.Atoi(op)
+		if err != nil {
+			helpers.ClientError(w, r, http.StatusBadRequest)
+			return
+		}
+
+		deleted, err := m.DB.DeleteBlockByID(r.Context(), id, m.actor(r))
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+		if !deleted {
+			conflicts = append(conflicts, "a block has been removed meanwhile")
+		}
+	}
+
+	// adding blocks, posted as "bungalow id:day"
+	blocks, err := blockRanges(r.PostForm["add_block"])
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	for _, block := range blocks {
+		inserted, err := m.DB.InsertBlockForBungalow(r.Context(), block, m.actor(r))
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+		if !inserted {
+			conflicts = append(conflicts, fmt.Sprintf("%s to %s has been reserved or blocked meanwhile",
+				block.StartDate.Format("2006-01-02"), block.EndDate.Format("2006-01-02")))
+		}
+	}
+
+	if len(conflicts) > 0 {
+		m.App.Session.Put(r.Context(), "warning", fmt.Sprintf("Some changes could not be saved: %s.", strings.Join(conflicts, ", ")))
+	} else {
+		m.App.Session.Put(r.Context(), "success", "Changes successfully saved")
+	}
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+}
+
+// blockRanges turns the days to block posted as "bungalow id:day" into owner blocks,
+// consecutive days of a bungalow become one block
+func blockRanges(ops []string) ([]models.BungalowRestriction, error) {
+	type day struct {
+		bungalowID int
+		date       time.Time
+	}
+
+	var days []day
+	for _, op := range ops {
+		exploded := strings.SplitN(op, ":", 2)
+		if len(exploded) != 2 {
+			return nil, fmt.Errorf("invalid day to block %q", op)
+		}
+		bungalowID, err := strconv.Atoi(exploded[0])
+		if err != nil {
+			return nil, err
+		}
+		date, err := time.Parse("2006-01-02", exploded[1])
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, day{bungalowID, date})
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		if days[i].bungalowID != days[j].bungalowID {
+			return days[i].bungalowID < days[j].bungalowID
+		}
+		return days[i].date.Before(days[j].date)
+	})
+
+	var blocks []models.BungalowRestriction
+	for _, d := range days {
+		if n := len(blocks); n > 0 && blocks[n-1].BungalowID == d.bungalowID && !d.date.After(blocks[n-1].EndDate.AddDate(0, 0, 1)) {
+			if d.date.After(blocks[n-1].EndDate) {
+				blocks[n-1].EndDate = d.date
+			}
+			continue
+		}
+		blocks = append(blocks, models.BungalowRestriction{
+			StartDate:     d.date,
+			EndDate:       d.date,
+			BungalowID:    d.bungalowID,
+			RestrictionID: models.RestrictionOwnerStay,
+		})
+	}
+
+	return blocks, nil
+}
+
+// AdminPostBlock creates an owner block over a range of days from the block form of the reservation calendar
+func (m *Repository) AdminPostBlock(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	returnURL := calendarURL(r.Form.Get("view"), r.Form.Get("y"), r.Form.Get("m"), r.Form.Get("d"))
+
+	var block models.BungalowRestriction
+	form, err := forms.Bind(r.PostForm, &block)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	if !block.StartDate.IsZero() && block.EndDate.Before(block.StartDate) {
+		form.Errors.Add("end_date", "The last day cannot be before the first day.")
+	}
+
+	restrictionTypes, err := m.DB.AllRestrictions(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	for _, t := range restrictionTypes {
+		if t.ID == block.RestrictionID && t.IsBlock() {
+			block.Restriction = t
+		}
+	}
+	if block.Restriction.ID == 0 && form.Errors.Get("restriction_id") == "" {
+		form.Errors.Add("restriction_id", "Please choose a valid type.")
+	}
+
+	if !form.Valid() {
+		m.App.Session.Put(r.Context(), "error", blockFormError(form))
+		http.Redirect(w, r, returnURL, http.StatusSeeOther)
+		return
+	}
+
+	// blocks of types blocking availability are only inserted while the days are free
+	inserted, err := m.DB.InsertBlockForBungalow(r.Context(), block, m.actor(r))
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	if !inserted {
+		m.App.Session.Put(r.Context(), "error", "Some of these days are already reserved or blocked.")
+		http.Redirect(w, r, returnURL, http.StatusSeeOther)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "success", "Block successfully saved")
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+}
+
+// AdminDeleteBlock deletes a whole owner block from the database, unless it was removed since it was shown
+func (m *Repository) AdminDeleteBlock(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	q := r.URL.Query()
+
+	deleted, err := m.DB.DeleteBlockByID(r.Context(), id, m.actor(r))
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	if deleted {
+		m.App.Session.Put(r.Context(), "success", "Block successfully deleted")
+	} else {
+		m.App.Session.Put(r.Context(), "warning", "The block has been removed meanwhile.")
+	}
+	http.Redirect(w, r, calendarURL(q.Get("view"), q.Get("y"), q.Get("m"), q.Get("d")), http.StatusSeeOther)
+}
+
+// calendarURL returns the url of a view of the reservation calendar, the month view is the default
+func calendarURL(view, year, month, day string) string {
+	if view == "" || view == "month" {
+		return fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month)
+	}
+	return fmt.Sprintf("/admin/reservations-calendar?view=%s&y=%s&m=%s&d=%s", view, year, month, day)
+}
+
+// blockFormError returns the first error message of the block form
+func blockFormError(form *forms.Form) string {
+	labels := []struct{ field, label string }{
+		{"bungalow_id", "Bungalow"},
+		{"start_date", "First day"},
+		{"end_date", "Last day"},
+		{"restriction_id", "Type"},
+		{"note", "Reason"},
+	}
+	for _, l := range labels {
+		if msg := form.Errors.Get(l.field); msg != "" {
+			return fmt.Sprintf("%s: %s", l.label, msg)
 		}
+	}
+	return ""
+}
+
+// AdminRestrictions lists the restriction types with a form for a new custom type
+func (m *Repository) AdminRestrictions(w http.ResponseWriter, r *http.Request) {
+	restrictions, err := m.DB.AllRestrictions(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["restrictions"] = restrictions
+	data["restriction"] = models.Restriction{Color: "#6c757d", BlocksAvailability: true}
+
+	render.Template(w, r, "admin-restrictions-page.html", &models.TemplateData{
+		Data: data,
+		Form: forms.New(nil),
+	})
+}
+
+// AdminPostRestriction creates a custom restriction type
+func (m *Repository) AdminPostRestriction(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	var restriction models.Restriction
+	form, err := forms.Bind(r.PostForm, &restriction)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
-		// read in all the restrictions for the bungalow for the current month
-		restrictions, err := m.DB.GetRestrictionsForBungalowByDate(x.ID, firstOfMonth, lastOfMonth)
+	if !form.Valid() {
+		restrictions, err := m.DB.AllRestrictions(r.Context())
 		if err != nil {
-			helpers.ServerError(w, err)
+			helpers.ServerError(w, r, err)
 			return
 		}
 
-		for _, y := range restrictions {
-			if y.ReservationID > 0 {
-				// if it is a reservation
-				for d := y.StartDate; d.After(y.EndDate) == false; d = d.AddDate(0, 0, 1) {
-					reservationMap[d.Format("2006-01-2")] = y.ReservationID
-				}
-			} else {
-				// if it is a block
-				blockMap[y.StartDate.Format("2006-01-2")] = y.ID
-			}
-		}
+		data := make(map[string]interface{})
+		data["restrictions"] = restrictions
+		data["restriction"] = restriction
+
+		render.Template(w, r, "admin-restrictions-page.html", &models.TemplateData{
+			Data: data,
+			Form: form,
+		})
+		return
+	}
+
+	err = m.DB.InsertRestriction(r.Context(), restriction, m.actor(r))
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
-		data[fmt.Sprintf("reservation_map_%d", x.ID)] = reservationMap
-		data[fmt.Sprintf("block_map_%d", x.ID)] = blockMap
+	m.App.Session.Put(r.Context(), "success", "Restriction type successfully saved")
+	http.Redirect(w, r, "/admin/restrictions", http.StatusSeeOther)
+}
 
-		m.App.Session.Put(r.Context(), fmt.Sprintf("block_map_%d", x.ID), blockMap)
+// AdminShowRestriction shows a restriction type for editing
+func (m *Repository) AdminShowRestriction(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
 
+	restriction, err := m.DB.GetRestrictionByID(r.Context(), id)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
 	}
 
-	render.Template(w, r, "admin-reservations-calendar-page.html", &models.TemplateData{
-		StringMap: stringMap,
-		Data:      data,
-		IntMap:    intMap,
+	data := make(map[string]interface{})
+	data["restriction"] = restriction
+
+	render.Template(w, r, "admin-restrictions-show-page.html", &models.TemplateData{
+		Data: data,
+		Form: forms.New(nil),
 	})
 }
 
-// AdminShowReservation shows a reservation in the admin area
-func (m *Repository) AdminShowReservation(w http.ResponseWriter, r *http.Request) {
+// AdminPostShowRestriction updates a restriction type
+func (m *Repository) AdminPostShowRestriction(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
-	exploded := strings.Split(r.RequestURI, "/")
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
 
-	id, err := strconv.Atoi(exploded[4])
+	restriction, err := m.DB.GetRestrictionByID(r.Context(), id)
 	if err != nil {
-		helpers.ServerError(w, err)
-    http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	res, err := m.DB.GetReservationByID(id)
+	// unchecked checkboxes are not posted at all
+	restriction.BlocksAvailability = false
+
+	form, err := forms.Bind(r.PostForm, &restriction)
 	if err != nil {
-		helpers.ServerError(w, err)
-    http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	data := make(map[string]interface{})
-	data["reservation"] = res
+	if !restriction.IsBlock() && !restriction.BlocksAvailability {
+		form.Errors.Add("blocks_availability", "Reservations and holds always block availability.")
+	}
 
-	src := exploded[3]
+	if !form.Valid() {
+		data := make(map[string]interface{})
+		data["restriction"] = restriction
 
-	stringMap := make(map[string]string)
-	stringMap["src"] = src
+		render.Template(w, r, "admin-restrictions-show-page.html", &models.TemplateData{
+			Data: data,
+			Form: form,
+		})
+		return
+	}
 
-	year := r.URL.Query().Get("y")
-	month := r.URL.Query().Get("m")
+	err = m.DB.UpdateRestriction(r.Context(), restriction, m.actor(r))
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
-	stringMap["month"] = month
-	stringMap["year"] = year
+	m.App.Session.Put(r.Context(), "success", "Restriction type successfully saved")
+	http.Redirect(w, r, "/admin/restrictions", http.StatusSeeOther)
+}
 
-	render.Template(w, r, "admin-reservations-show-page.html", &models.TemplateData{
-		StringMap: stringMap,
-		Data:      data,
-		Form:      forms.New(nil),
+// AdminDeleteRestriction deletes a custom restriction type which is not in use
+func (m *Repository) AdminDeleteRestriction(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if (models.Restriction{ID: id}).IsBuiltIn() {
+		m.App.Session.Put(r.Context(), "error", "Built-in restriction types cannot be deleted.")
+		http.Redirect(w, r, "/admin/restrictions", http.StatusSeeOther)
+		return
+	}
+
+	deleted, err := m.DB.DeleteRestriction(r.Context(), id, m.actor(r))
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+	if !deleted {
+		m.App.Session.Put(r.Context(), "error", "This restriction type is still in use.")
+		http.Redirect(w, r, "/admin/restrictions", http.StatusSeeOther)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "success", "Restriction type successfully deleted")
+	http.Redirect(w, r, "/admin/restrictions", http.StatusSeeOther)
+}
+
+// the pages of the stay rules, the list with the form for a new rule and the form of a rule
+const (
+	stayRulesPage    = "admin-stay-rules-page.html"
+	stayRuleShowPage = "admin-stay-rules-show-page.html"
+)
+
+// arrivalWeekdays are the weekdays offered for the arrival of a stay rule, in the order of a week starting on monday
+var arrivalWeekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// renderStayRule renders a page with the form of a stay rule, the list page shows all rules above it
+func (m *Repository) renderStayRule(w http.ResponseWriter, r *http.Request, page string, rule models.StayRule, form *forms.Form) {
+	bungalows, err := m.DB.AllBungalows(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["rule"] = rule
+	data["bungalows"] = bungalows
+	data["weekdays"] = arrivalWeekdays
+
+	if page == stayRulesPage {
+		rules, err := m.DB.AllStayRules(r.Context())
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+		data["rules"] = rules
+	}
+
+	render.Template(w, r, page, &models.TemplateData{
+		Data: data,
+		Form: form,
 	})
 }
 
-// AdminPostShowReservation handles a post request to update a reservation
-func (m *Repository) AdminPostShowReservation(w http.ResponseWriter, r *http.Request) {
+// bindStayRule binds the posted form to a stay rule and checks that its settings fit together
+func bindStayRule(r *http.Request, rule *models.StayRule) (*forms.Form, error) {
+	form, err := forms.Bind(r.PostForm, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	// the arrival weekdays are posted as one checkbox per day, none checked allows every day
+	rule.ArrivalWeekdays, err = models.ParseWeekdays(strings.Join(r.PostForm["arrival_weekdays"], ","))
+	if err != nil {
+		form.Errors.Add("arrival_weekdays", "Choose the arrival days from the days of the week.")
+	}
+
+	if !rule.StartDate.IsZero() && !rule.EndDate.IsZero() && rule.EndDate.Before(rule.StartDate) {
+		form.Errors.Add("end_date", "The last day cannot be before the first day.")
+	}
+	if rule.MinNights > 0 && rule.MaxNights > 0 && rule.MaxNights < rule.MinNights {
+		form.Errors.Add("max_nights", "The maximum stay cannot be shorter than the minimum stay.")
+	}
+	if rule.MinLeadDays > 0 && rule.MaxHorizonDays > 0 && rule.MaxHorizonDays < rule.MinLeadDays {
+		form.Errors.Add("max_horizon_days", "The booking horizon cannot be shorter than the lead time.")
+	}
+
+	return form, nil
+}
+
+// AdminStayRules lists the stay rules with a form for a new rule
+func (m *Repository) AdminStayRules(w http.ResponseWriter, r *http.Request) {
+	m.renderStayRule(w, r, stayRulesPage, models.StayRule{}, forms.New(nil))
+}
+
+// AdminPostStayRule creates a stay rule
+func (m *Repository) AdminPostStayRule(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	exploded := strings.Split(r.RequestURI, "/")
+	var rule models.StayRule
+	form, err := bindStayRule(r, &rule)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
-	id, err := strconv.Atoi(exploded[4])
+	if !form.Valid() {
+		m.renderStayRule(w, r, stayRulesPage, rule, form)
+		return
+	}
+
+	err = m.DB.InsertStayRule(r.Context(), rule, m.actor(r))
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	src := exploded[3]
+	m.App.Session.Put(r.Context(), "success", "Stay rule successfully saved")
+	http.Redirect(w, r, "/admin/stay-rules", http.StatusSeeOther)
+}
+
+// AdminShowStayRule shows a stay rule for editing
+func (m *Repository) AdminShowStayRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
 
-	res, err := m.DB.GetReservationByID(id)
+	rule, err := m.DB.GetStayRuleByID(r.Context(), id)
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	res.FullName = r.Form.Get("full_name")
-	res.Email = r.Form.Get("email")
-	res.Phone = r.Form.Get("phone")
+	m.renderStayRule(w, r, stayRuleShowPage, rule, forms.New(nil))
+}
 
-	err = m.DB.UpdateReservation(res)
+// AdminPostShowStayRule updates a stay rule
+func (m *Repository) AdminPostShowStayRule(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	month := r.Form.Get("month")
-	year := r.Form.Get("year")
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
 
-	m.App.Session.Put(r.Context(), "success", "Changes successfully saved")
+	rule, err := m.DB.GetStayRuleByID(r.Context(), id)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
-	if year == "" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	form, err := bindStayRule(r, &rule)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
 	}
-}
 
-// AdminProcessReservation changes the status of a reservation to processed
-func (m *Repository) AdminProcessReservation(w http.ResponseWriter, r *http.Request) {
+	if !form.Valid() {
+		m.renderStayRule(w, r, stayRuleShowPage, rule, form)
+		return
+	}
+
+	err = m.DB.UpdateStayRule(r.Context(), rule, m.actor(r))
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
+	m.App.Session.Put(r.Context(), "success", "Stay rule successfully saved")
+	http.Redirect(w, r, "/admin/stay-rules", http.StatusSeeOther)
+}
+
+// AdminDeleteStayRule deletes a stay rule
+func (m *Repository) AdminDeleteStayRule(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
-	src := chi.URLParam(r, "src")
 
-	err := m.DB.UpdateStatusOfReservation(id, 1)
+	err := m.DB.DeleteStayRule(r.Context(), id, m.actor(r))
 	if err != nil {
-		log.Println(err)
+		helpers.ServerError(w, r, err)
+		return
 	}
 
-	year := r.URL.Query().Get("y")
-	month := r.URL.Query().Get("m")
+	m.App.Session.Put(r.Context(), "success", "Stay rule successfully deleted")
+	http.Redirect(w, r, "/admin/stay-rules", http.StatusSeeOther)
+}
+
+// actor returns the logged in user making a request and the address it came from, for the audit log
+func (m *Repository) actor(r *http.Request) models.Actor {
+	return models.Actor{
+		UserID: m.App.Session.GetInt(r.Context(), "user_id"),
+		IP:     ratelimit.ClientIP(r, m.App.TrustedProxies),
+	}
+}
 
-	m.App.Session.Put(r.Context(), "success", "Reservation successfully marked as processed")
+// AdminInquiries lists the open inquiries sent with the contact form, or the answered ones
+func (m *Repository) AdminInquiries(w http.ResponseWriter, r *http.Request) {
+	answered := r.URL.Query().Get("show") == "answered"
 
-	if year == "" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+	inquiries, err := m.DB.GetInquiries(r.Context(), answered)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["inquiries"] = inquiries
+
+	stringMap := make(map[string]string)
+	if answered {
+		stringMap["show"] = "answered"
 	}
+
+	render.Template(w, r, "admin-inquiries-page.html", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
 }
 
-// AdminDeleteReservation deletes a reservation from the database
-func (m *Repository) AdminDeleteReservation(w http.ResponseWriter, r *http.Request) {
+// AdminShowInquiry shows an inquiry with a link turning it into a reservation
+func (m *Repository) AdminShowInquiry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
 
-	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
-	src := chi.URLParam(r, "src")
+	inquiry, err := m.DB.GetInquiryByID(r.Context(), id)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
-	_ = m.DB.DeleteReservation(id)
+	data := make(map[string]interface{})
+	data["inquiry"] = inquiry
 
-	year := r.URL.Query().Get("y")
-	month := r.URL.Query().Get("m")
+	stringMap := make(map[string]string)
+	stringMap["convert_url"] = convertInquiryURL(inquiry)
 
-	m.App.Session.Put(r.Context(), "success", "Reservation successfully deleted")
+	render.Template(w, r, "admin-inquiries-show-page.html", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
 
-	if year == "" {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-%s", src), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%s&m=%s", year, month), http.StatusSeeOther)
+// convertInquiryURL returns the url of the reservation form prefilled with the data of an inquiry
+func convertInquiryURL(inquiry models.Inquiry) string {
+	params := url.Values{}
+	params.Set("inquiry_id", strconv.Itoa(inquiry.ID))
+	params.Set("full_name", inquiry.FullName)
+	params.Set("email", inquiry.Email)
+	if inquiry.BungalowID > 0 {
+		params.Set("bungalow_id", strconv.Itoa(inquiry.BungalowID))
 	}
+	if !inquiry.StartDate.IsZero() {
+		params.Set("start_date", inquiry.StartDate.Format("2006-01-02"))
+		params.Set("end_date", inquiry.EndDate.Format("2006-01-02"))
+	}
+	params.Set("notes", fmt.Sprintf("From inquiry %q: %s", inquiry.Subject, inquiry.Message))
+	return "/admin/reservations/new?" + params.Encode()
 }
 
-// AdminPostReservationsCalendar is the handler for post requests to the reservation calendar
-func (m *Repository) AdminPostReservationsCalendar(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
+// AdminAnswerInquiry marks an inquiry as answered, or opens it again if answered is 0
+func (m *Repository) AdminAnswerInquiry(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	answered := r.URL.Query().Get("answered") != "0"
+
+	err := m.DB.UpdateInquiryAnswered(r.Context(), id, answered, m.actor(r))
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	year, _ := strconv.Atoi(r.Form.Get("y"))
-	month, _ := strconv.Atoi(r.Form.Get("m"))
+	if answered {
+		m.App.Session.Put(r.Context(), "success", "Inquiry marked as answered")
+		http.Redirect(w, r, "/admin/inquiries", http.StatusSeeOther)
+	} else {
+		m.App.Session.Put(r.Context(), "success", "Inquiry opened again")
+		http.Redirect(w, r, "/admin/inquiries?show=answered", http.StatusSeeOther)
+	}
+}
+
+// AdminAudit lists the changes recorded in the audit log, filtered by user, action, entity and date
+func (m *Repository) AdminAudit(w http.ResponseWriter, r *http.Request) {
+	q := auditQuery(r.URL.Query())
 
-	// processing existing blocks
-	bungalows, err := m.DB.AllBungalows()
+	page, err := m.DB.GetAuditEvents(r.Context(), q)
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	form := forms.New(r.PostForm)
-
-	for _, x := range bungalows {
-		// Get the block map from the session
-		curMap := m.App.Session.Get(r.Context(), fmt.Sprintf("block_map_%d", x.ID)).(map[string]int)
-
-		// Loop through the block map from before editing
-		// if there is an entry in the map
-		// that does not exist in our posted data and if the restriction id is greater than zero,
-		// then that is a day the block needs to be removed from
-
-		for name, value := range curMap {
-			// ok will be false if value is not in map
-			if val, ok := curMap[name]; ok {
-				// only checking values > 0, and not in posted form
-				// the remainders are just placeholders for non-blocked days
-				if val > 0 {
-					if !form.Has(fmt.Sprintf("remove_block_%d_%s", x.ID, name)) {
-						// delete the bungalow_restriction by id
-						err := m.DB.DeleteBlockByID(value)
-						if err != nil {
-							log.Println(err)
-						}
-					}
-				}
-			}
-		}
+	stringMap := make(map[string]string)
+	stringMap["from"] = r.URL.Query().Get("from")
+	stringMap["to"] = r.URL.Query().Get("to")
+	if page.HasPrevious() {
+		previous := q
+		previous.Page--
+		stringMap["previous_url"] = auditURL(previous)
+	}
+	if page.HasNext() {
+		next := q
+		next.Page++
+		stringMap["next_url"] = auditURL(next)
 	}
 
-	// handling new blocks
-	for name, _ := range r.PostForm {
-		if strings.HasPrefix(name, "add_block") {
-			exploded := strings.Split(name, "_")
-			bungalowID, _ := strconv.Atoi(exploded[2])
-			t, _ := time.Parse("2006-01-2", exploded[3])
+	data := make(map[string]interface{})
+	data["page"] = page
+	data["actions"] = []string{models.AuditCreate, models.AuditUpdate, models.AuditDelete, models.AuditRestore, models.AuditPurge}
+	data["entities"] = []string{models.AuditReservation, models.AuditNote, models.AuditMessage, models.AuditBlock, models.AuditRestriction,
+		models.AuditStayRule, models.AuditInquiry}
 
-			// insert the bungalow_restriction by id
-			err := m.DB.InsertBlockForBungalow(bungalowID, t)
-			if err != nil {
-				log.Println(err)
-			}
-		}
+	render.Template(w, r, "admin-audit-page.html", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// auditQuery reads the query of the audit log from its url parameters, invalid parameters are ignored
+func auditQuery(values url.Values) models.AuditQuery {
+	q := models.AuditQuery{
+		Action:  values.Get("action"),
+		Entity:  values.Get("entity"),
+		Page:    1,
+		PerPage: 50,
+	}
+	q.UserID, _ = strconv.Atoi(values.Get("user"))
+	q.EntityID, _ = strconv.Atoi(values.Get("entity_id"))
+	q.From, _ = time.Parse("2006-01-02", values.Get("from"))
+	q.To, _ = time.Parse("2006-01-02", values.Get("to"))
+	if page, err := strconv.Atoi(values.Get("page")); err == nil && page > 1 {
+		q.Page = page
 	}
+	return q
+}
 
-	m.App.Session.Put(r.Context(), "success", "Changes successfully saved")
-	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calendar?y=%d&m=%d", year, month), http.StatusSeeOther)
+// auditURL returns the url of the audit log showing the events selected by a query
+func auditURL(q models.AuditQuery) string {
+	params := url.Values{}
+	if q.UserID > 0 {
+		params.Set("user", strconv.Itoa(q.UserID))
+	}
+	if q.Action != "" {
+		params.Set("action", q.Action)
+	}
+	if q.Entity != "" {
+		params.Set("entity", q.Entity)
+	}
+	if q.EntityID > 0 {
+		params.Set("entity_id", strconv.Itoa(q.EntityID))
+	}
+	if !q.From.IsZero() {
+		params.Set("from", q.From.Format("2006-01-02"))
+	}
+	if !q.To.IsZero() {
+		params.Set("to", q.To.Format("2006-01-02"))
+	}
+	if q.Page > 1 {
+		params.Set("page", strconv.Itoa(q.Page))
+	}
+	if len(params) == 0 {
+		return "/admin/audit"
+	}
+	return "/admin/audit?" + params.Encode()
 }
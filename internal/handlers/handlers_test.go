@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"log/slog"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"os"
 	"reflect"
 	"strconv"
 	"strings"
@@ -16,7 +19,10 @@ import (
 	"time"
 
 	"github.com/amartin3659/VacationHomeRental/internal/driver"
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
 	"github.com/amartin3659/VacationHomeRental/internal/models"
+	"github.com/amartin3659/VacationHomeRental/internal/ratelimit"
+	"github.com/go-chi/chi/v5"
 )
 
 type postData struct {
@@ -73,6 +79,55 @@ func TestAllTheHandlers(t *testing.T) {
 	}
 }
 
+// PostContact
+func TestRepository_PostContact(t *testing.T) {
+
+	var contactTests = []struct {
+		name               string
+		fullName           string
+		email              string
+		bungalowID         string
+		startDate          string
+		endDate            string
+		expectedStatusCode int
+		expectedLocation   string
+		expectedError      string
+	}{
+		{"sent", "Peter Griffin", "peter@griffin.family", "", "", "", http.StatusSeeOther, "/contact", ""},
+		{"sent-with-stay", "Peter Griffin", "peter@griffin.family", "1", "2036-02-01", "2036-02-08", http.StatusSeeOther, "/contact", ""},
+		{"invalid-email", "Peter Griffin", "peter", "", "", "", http.StatusOK, "", "is-invalid"},
+		{"departure-missing", "Peter Griffin", "peter@griffin.family", "", "2036-02-01", "", http.StatusOK, "", "This field cannot be empty."},
+		{"no-such-bungalow", "Peter Griffin", "peter@griffin.family", "7", "", "", http.StatusOK, "", "There is no such bungalow."},
+		{"insert-fails", "fail", "peter@griffin.family", "", "", "", http.StatusTemporaryRedirect, "/", ""},
+	}
+
+	for _, e := range contactTests {
+		postData := url.Values{}
+		postData.Add("full_name", e.fullName)
+		postData.Add("email", e.email)
+		postData.Add("subject", "Family holiday")
+		postData.Add("message", "Is the shack free in February?")
+		postData.Add("bungalow_id", e.bungalowID)
+		postData.Add("start_date", e.startDate)
+		postData.Add("end_date", e.endDate)
+
+		req := httptest.NewRequest("POST", "/contact", strings.NewReader(postData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.PostContact).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode || rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("for %s expected %d to %q, but got %d to %q", e.name, e.expectedStatusCode, e.expectedLocation, rr.Code, rr.Header().Get("Location"))
+		}
+		if !strings.Contains(rr.Body.String(), e.expectedError) {
+			t.Errorf("for %s expected %q on the form", e.name, e.expectedError)
+		}
+	}
+}
+
 // TestRepository_MakeReservation tests the MakeReservation get-request handle
 func TestRepository_MakeReservation(t *testing.T) {
 
@@ -138,11 +193,12 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	postedData.Add("full_name", "Peter Griffin")
 	postedData.Add("email", "peter@griffin.family")
 	postedData.Add("phone", "1234567890")
+	postedData.Add("adults", "2")
 
 	// data to put in session
 	layout := "2006-01-02"
-	sd, _ := time.Parse(layout, "2037-01-01")
-	ed, _ := time.Parse(layout, "2037-01-02")
+	sd, _ := time.Parse(layout, "2036-01-01")
+	ed, _ := time.Parse(layout, "2036-01-02")
 	bungalowId, _ := strconv.Atoi("1")
 
 	reservation := models.Reservation{
@@ -167,10 +223,14 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 
 	handler := http.HandlerFunc(Repo.PostMakeReservation)
 
+	created := app.Metrics.ReservationsCreated.Value("booking")
 	handler.ServeHTTP(rr, req)
 
-	if rr.Code != http.StatusSeeOther {
-		t.Errorf("PostMakeReservation handler returned wrong response code: got %d, wanted %d", rr.Code, http.StatusSeeOther)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/reservation-overview" {
+		t.Errorf("PostMakeReservation handler returned wrong response: got %d to %q, wanted %d to %q", rr.Code, rr.Header().Get("Location"), http.StatusSeeOther, "/reservation-overview")
+	}
+	if app.Metrics.ReservationsCreated.Value("booking") != created+1 {
+		t.Error("Expected the reservation to be counted, but it was not")
 	}
 
 	// case #2: missing post body
@@ -200,11 +260,12 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	postedData.Add("full_name", "Peter Griffin")
 	postedData.Add("email", "peter@griffin.family")
 	postedData.Add("phone", "1234567890")
+	postedData.Add("adults", "2")
 
 	// data to put in session
 	layout = "2006-01-02"
-	sd, _ = time.Parse(layout, "2037-01-01")
-	ed, _ = time.Parse(layout, "2037-01-02")
+	sd, _ = time.Parse(layout, "2036-01-01")
+	ed, _ = time.Parse(layout, "2036-01-02")
 	bungalowId, _ = strconv.Atoi("1")
 
 	reservation = models.Reservation{
@@ -239,11 +300,12 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	postedData.Add("full_name", "P")
 	postedData.Add("email", "peter@griffin.family")
 	postedData.Add("phone", "1234567890")
+	postedData.Add("adults", "2")
 
 	// data to put in session
 	layout = "2006-01-02"
-	sd, _ = time.Parse(layout, "2037-01-01")
-	ed, _ = time.Parse(layout, "2037-01-02")
+	sd, _ = time.Parse(layout, "2036-01-01")
+	ed, _ = time.Parse(layout, "2036-01-02")
 	bungalowId, _ = strconv.Atoi("1")
 
 	reservation = models.Reservation{
@@ -282,11 +344,12 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	postedData.Add("full_name", "Peter Griffin")
 	postedData.Add("email", "peter@griffin.family")
 	postedData.Add("phone", "1234567890")
+	postedData.Add("adults", "2")
 
 	// data to put in session
 	layout = "2006-01-02"
-	sd, _ = time.Parse(layout, "2037-01-01")
-	ed, _ = time.Parse(layout, "2037-01-02")
+	sd, _ = time.Parse(layout, "2036-01-01")
+	ed, _ = time.Parse(layout, "2036-01-02")
 	bungalowId, _ = strconv.Atoi("99")
 
 	reservation = models.Reservation{
@@ -319,17 +382,18 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 		t.Errorf("PostMakeReservation handler failed when trying to inserting a reservation into the database: got %d, wanted %d", rr.Code, http.StatusTemporaryRedirect)
 	}
 
-	// case #6: failure to inserting restriction into database
+	// case #6: failure inserting reservation and restriction into database
 
 	postedData = url.Values{}
 	postedData.Add("full_name", "Peter Griffin")
 	postedData.Add("email", "peter@griffin.family")
 	postedData.Add("phone", "1234567890")
+	postedData.Add("adults", "2")
 
 	// data to put in session
 	layout = "2006-01-02"
-	sd, _ = time.Parse(layout, "2037-01-01")
-	ed, _ = time.Parse(layout, "2037-01-02")
+	sd, _ = time.Parse(layout, "2036-01-01")
+	ed, _ = time.Parse(layout, "2036-01-02")
 	bungalowId, _ = strconv.Atoi("999")
 
 	reservation = models.Reservation{
@@ -361,8 +425,198 @@ func TestRepository_PostMakeReservation(t *testing.T) {
 	if rr.Code != http.StatusTemporaryRedirect {
 		t.Errorf("PostMakeReservation handler failed when trying to inserting a reservation into the database: got %d, wanted %d", rr.Code, http.StatusTemporaryRedirect)
 	}
+
+	// case #7: stay breaks a stay rule of the bungalow
+
+	postedData = url.Values{}
+	postedData.Add("full_name", "Peter Griffin")
+	postedData.Add("email", "peter@griffin.family")
+	postedData.Add("phone", "1234567890")
+	postedData.Add("adults", "2")
+
+	// data to put in session
+	layout = "2006-01-02"
+	sd, _ = time.Parse(layout, "2036-01-01")
+	ed, _ = time.Parse(layout, "2036-01-02")
+	bungalowId, _ = strconv.Atoi("3")
+
+	reservation = models.Reservation{
+		StartDate:  sd,
+		EndDate:    ed,
+		BungalowID: bungalowId,
+		Bungalow: models.Bungalow{
+			BungalowName: "some bungalow name for tests",
+		},
+	}
+
+	// create request
+	req, _ = http.NewRequest("POST", "/make-reservation", strings.NewReader(postedData.Encode()))
+
+	// get the context
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+
+	session.Put(ctx, "reservation", reservation)
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+
+	handler = http.HandlerFunc(Repo.PostMakeReservation)
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/reservation" {
+		t.Errorf("PostMakeReservation handler accepted a stay breaking a stay rule: got %d to %q, wanted %d to %q", rr.Code, rr.Header().Get("Location"), http.StatusSeeOther, "/reservation")
+	}
+
+	// case #8: party is too large for the bungalow
+
+	postedData = url.Values{}
+	postedData.Add("full_name", "Peter Griffin")
+	postedData.Add("email", "peter@griffin.family")
+	postedData.Add("phone", "1234567890")
+	postedData.Add("adults", "2")
+	postedData.Add("children", "3")
+
+	reservation = models.Reservation{
+		StartDate:  sd,
+		EndDate:    ed,
+		BungalowID: 2,
+		Bungalow: models.Bungalow{
+			BungalowName: "some bungalow name for tests",
+			MaxOccupancy: 2,
+		},
+	}
+
+	// create request
+	req, _ = http.NewRequest("POST", "/make-reservation", strings.NewReader(postedData.Encode()))
+
+	// get the context
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+
+	session.Put(ctx, "reservation", reservation)
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+
+	handler = http.HandlerFunc(Repo.PostMakeReservation)
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "at most 2 guests") {
+		t.Errorf("PostMakeReservation handler accepted a party larger than the bungalow: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+
+	// case #9: hold is converted into the reservation
+
+	postedData = url.Values{}
+	postedData.Add("full_name", "Peter Griffin")
+	postedData.Add("email", "peter@griffin.family")
+	postedData.Add("phone", "1234567890")
+	postedData.Add("adults", "2")
+
+	reservation = models.Reservation{
+		StartDate:  sd,
+		EndDate:    ed,
+		BungalowID: 1,
+		Bungalow: models.Bungalow{
+			BungalowName: "some bungalow name for tests",
+		},
+	}
+
+	// create request
+	req, _ = http.NewRequest("POST", "/make-reservation", strings.NewReader(postedData.Encode()))
+
+	// get the context
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+
+	session.Put(ctx, "reservation", reservation)
+	session.Put(ctx, "hold_id", 1)
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+
+	handler = http.HandlerFunc(Repo.PostMakeReservation)
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/reservation-overview" {
+		t.Errorf("PostMakeReservation handler failed to convert a hold: got %d to %q, wanted %d to %q", rr.Code, rr.Header().Get("Location"), http.StatusSeeOther, "/reservation-overview")
+	}
+	if session.Exists(ctx, "hold_id") {
+		t.Error("PostMakeReservation handler kept the converted hold in the session")
+	}
+
+	// case #10: hold expired and the bungalow has been booked meanwhile
+
+	expiredStart, _ := time.Parse(layout, "2037-01-01")
+	expiredEnd, _ := time.Parse(layout, "2037-01-02")
+
+	reservation = models.Reservation{
+		StartDate:  expiredStart,
+		EndDate:    expiredEnd,
+		BungalowID: 1,
+		Bungalow: models.Bungalow{
+			BungalowName: "some bungalow name for tests",
+		},
+	}
+
+	// create request
+	req, _ = http.NewRequest("POST", "/make-reservation", strings.NewReader(postedData.Encode()))
+
+	// get the context
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+
+	session.Put(ctx, "reservation", reservation)
+	session.Put(ctx, "hold_id", 2)
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+
+	handler = http.HandlerFunc(Repo.PostMakeReservation)
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/reservation" {
+		t.Errorf("PostMakeReservation handler accepted an expired hold on a booked bungalow: got %d to %q, wanted %d to %q", rr.Code, rr.Header().Get("Location"), http.StatusSeeOther, "/reservation")
+	}
+
+	// case #11: failure converting the hold in the database
+
+	reservation.StartDate = sd
+	reservation.EndDate = ed
+
+	// create request
+	req, _ = http.NewRequest("POST", "/make-reservation", strings.NewReader(postedData.Encode()))
+
+	// get the context
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+
+	session.Put(ctx, "reservation", reservation)
+	session.Put(ctx, "hold_id", 3)
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+
+	handler = http.HandlerFunc(Repo.PostMakeReservation)
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("PostMakeReservation handler failed when converting a hold: got %d, wanted %d", rr.Code, http.StatusTemporaryRedirect)
+	}
 }
 
+
 // TestRepository_ReservationJSON tests the ReservationJSON POST-request handler
 func TestRepository_ReservationJSON(t *testing.T) {
 
@@ -419,9 +673,13 @@ func TestRepository_ReservationJSON(t *testing.T) {
 	handler = http.HandlerFunc(Repo.ReservationJSON)
 	// -- make request
 	handler.ServeHTTP(rr, req)
-	// -- check response
-	if rr.Code != http.StatusTemporaryRedirect {
-		t.Errorf("Expected status code %d, but got status code %d", http.StatusTemporaryRedirect, rr.Code)
+	// -- parse json and recieve response
+	err = json.Unmarshal([]byte(rr.Body.String()), &j)
+	if err != nil {
+		t.Error("failed to parse json")
+	}
+	if j.OK || !strings.Contains(j.Message, "valid date") {
+		t.Errorf("Expected an invalid date message with response OK: %t, but got response OK: %t and message %q", false, j.OK, j.Message)
 	}
 
 	// case #3: end date invalid
@@ -444,18 +702,24 @@ func TestRepository_ReservationJSON(t *testing.T) {
 	handler = http.HandlerFunc(Repo.ReservationJSON)
 	// -- make request
 	handler.ServeHTTP(rr, req)
-	// -- check response
-	if rr.Code != http.StatusTemporaryRedirect {
-		t.Errorf("Expected status code %d, but got status code %d", http.StatusTemporaryRedirect, rr.Code)
+	// -- parse json and recieve response
+	err = json.Unmarshal([]byte(rr.Body.String()), &j)
+	if err != nil {
+		t.Error("failed to parse json")
+	}
+	if j.OK || !strings.Contains(j.Message, "valid date") {
+		t.Errorf("Expected an invalid date message with response OK: %t, but got response OK: %t and message %q", false, j.OK, j.Message)
 	}
 
 	// case #4: bungalow not available
 	// 2037-01-01
 
+	// -- remember the search counts
+	searches, empty := app.Metrics.AvailabilitySearches.Value("bungalow"), app.Metrics.EmptySearches.Value("bungalow")
 	// -- create request body
 	postData = url.Values{}
 	postData.Add("start", "2037-01-01")
-	postData.Add("end", "2037-01-01")
+	postData.Add("end", "2037-01-02")
 	postData.Add("bungalow_id", "1")
 	// -- create request
 	req = httptest.NewRequest("POST", "/reservation-json", strings.NewReader(postData.Encode()))
@@ -478,13 +742,16 @@ func TestRepository_ReservationJSON(t *testing.T) {
 	if j.OK {
 		t.Errorf("Expected bungalow to be booked with response OK: %t, but got response OK: %t", false, j.OK)
 	}
+	if app.Metrics.AvailabilitySearches.Value("bungalow") != searches+1 || app.Metrics.EmptySearches.Value("bungalow") != empty+1 {
+		t.Error("Expected the search to be counted as a search without result, but it was not")
+	}
 
 	// case #5: bungalow available
 
 	// -- create request body
 	postData = url.Values{}
-	postData.Add("start", "2024-01-17")
-	postData.Add("end", "2024-01-17")
+	postData.Add("start", "2036-01-17")
+	postData.Add("end", "2036-01-18")
 	postData.Add("bungalow_id", "1")
 	// -- create request
 	req = httptest.NewRequest("POST", "/reservation-json", strings.NewReader(postData.Encode()))
@@ -561,6 +828,60 @@ func TestRepository_ReservationJSON(t *testing.T) {
 	if j.OK {
 		t.Errorf("Expected error thrown within ReservationJSON method, but got response OK: %t", j.OK)
 	}
+
+	// case #8: stay is shorter than the minimum stay of the bungalow
+	postData = url.Values{}
+	postData.Add("start", "2036-01-01")
+	postData.Add("end", "2036-01-02")
+	postData.Add("bungalow_id", "3")
+	// -- create request
+	req = httptest.NewRequest("POST", "/reservation-json", strings.NewReader(postData.Encode()))
+	// -- get context
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.ReservationJSON)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- parse json and recieve response
+	err = json.Unmarshal([]byte(rr.Body.String()), &j)
+	if err != nil {
+		t.Error("failed to parse json")
+	}
+	if j.OK || !strings.Contains(j.Message, "minimum stay") {
+		t.Errorf("Expected a minimum stay message with response OK: %t, but got response OK: %t and message %q", false, j.OK, j.Message)
+	}
+
+	// case #9: stay rules cannot be read from database
+	postData = url.Values{}
+	postData.Add("start", "2036-01-01")
+	postData.Add("end", "2036-01-02")
+	postData.Add("bungalow_id", "5")
+	// -- create request
+	req = httptest.NewRequest("POST", "/reservation-json", strings.NewReader(postData.Encode()))
+	// -- get context
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.ReservationJSON)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- parse json and recieve response
+	err = json.Unmarshal([]byte(rr.Body.String()), &j)
+	if err != nil {
+		t.Error("failed to parse json")
+	}
+	if j.OK || j.Message != "Error querying database" {
+		t.Errorf("Expected error reading stay rules, but got response OK: %t and message %q", j.OK, j.Message)
+	}
 }
 
 func getCtx(req *http.Request) context.Context {
@@ -631,7 +952,7 @@ func TestRepository_PostReservation(t *testing.T) {
 	// -- create request body
 	postData = url.Values{}
 	postData.Add("start", "2037-01-01")
-	postData.Add("end", "2037-01-01")
+	postData.Add("end", "2037-01-02")
 	// -- create request
 	req = httptest.NewRequest("POST", "/reservation", strings.NewReader(postData.Encode()))
 	// -- get ctx
@@ -653,8 +974,8 @@ func TestRepository_PostReservation(t *testing.T) {
 	// case #4: bungalow available
 	// -- create request body
 	postData = url.Values{}
-	postData.Add("start", "2024-01-17")
-	postData.Add("end", "2024-01-17")
+	postData.Add("start", "2036-01-17")
+	postData.Add("end", "2036-01-18")
 	// -- create request
 	req = httptest.NewRequest("POST", "/reservation", strings.NewReader(postData.Encode()))
 	// -- get context
@@ -714,37 +1035,134 @@ func TestRepository_PostReservation(t *testing.T) {
 	if rr.Code != http.StatusTemporaryRedirect {
 		t.Errorf("Expected status code: %d, but got status code: %d", http.StatusTemporaryRedirect, rr.Code)
 	}
-}
-
-// ReservationOverview
-func TestRepository_ReservationOverview(t *testing.T) {
-	// case variables
-	var req *http.Request
-	var ctx context.Context
-	var rr *httptest.ResponseRecorder
-	var handler http.Handler
 
-	// case #1: No session data
+	// case #7: arrival date in the past
+	postData = url.Values{}
+	postData.Add("start", "2020-01-01")
+	postData.Add("end", "2020-01-02")
 	// -- create request
-	req = httptest.NewRequest("GET", "/reservation-overview", nil)
-	// -- create ctx
+	req = httptest.NewRequest("POST", "/reservation", strings.NewReader(postData.Encode()))
+	// -- get context
 	ctx = getCtx(req)
 	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	// -- create response recorder
 	rr = httptest.NewRecorder()
 	// -- create handler
-	handler = http.HandlerFunc(Repo.ReservationOverview)
+	handler = http.HandlerFunc(Repo.PostReservation)
 	// -- make request
 	handler.ServeHTTP(rr, req)
-	// check response
+	// -- check response
 	if rr.Code != http.StatusTemporaryRedirect {
-		t.Errorf("Expected response code: %d, got response code: %d", http.StatusTemporaryRedirect, rr.Code)
+		t.Errorf("Expected status code: %d, but got status code: %d", http.StatusTemporaryRedirect, rr.Code)
+	}
+	if app.Session.GetString(ctx, "error") != "Arrival: This date cannot be in the past." {
+		t.Errorf("Expected an arrival in the past message in session, got %q", app.Session.GetString(ctx, "error"))
 	}
 
-	// case #2: Invalid bungalow id
-	// -- create reservation session data
-	reservation := models.Reservation{
-		BungalowID: 4,
+	// case #8: departure date before arrival date
+	postData = url.Values{}
+	postData.Add("start", "2036-01-02")
+	postData.Add("end", "2036-01-01")
+	// -- create request
+	req = httptest.NewRequest("POST", "/reservation", strings.NewReader(postData.Encode()))
+	// -- get context
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.PostReservation)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected status code: %d, but got status code: %d", http.StatusTemporaryRedirect, rr.Code)
+	}
+	if app.Session.GetString(ctx, "error") != "Departure: This date must be after 2036-01-02." {
+		t.Errorf("Expected a departure before arrival message in session, got %q", app.Session.GetString(ctx, "error"))
+	}
+
+	// case #9: no bungalow can host the party
+	postData = url.Values{}
+	postData.Add("start", "2036-01-01")
+	postData.Add("end", "2036-01-02")
+	postData.Add("adults", "4")
+	postData.Add("children", "3")
+	// -- create request
+	req = httptest.NewRequest("POST", "/reservation", strings.NewReader(postData.Encode()))
+	// -- get context
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.PostReservation)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("Expected status code: %d, but got status code: %d", http.StatusSeeOther, rr.Code)
+	}
+
+	// case #10: invalid party size
+	postData = url.Values{}
+	postData.Add("start", "2036-01-01")
+	postData.Add("end", "2036-01-02")
+	postData.Add("adults", "0")
+	// -- create request
+	req = httptest.NewRequest("POST", "/reservation", strings.NewReader(postData.Encode()))
+	// -- get context
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.PostReservation)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected status code: %d, but got status code: %d", http.StatusTemporaryRedirect, rr.Code)
+	}
+}
+
+// ReservationOverview
+func TestRepository_ReservationOverview(t *testing.T) {
+	// case variables
+	var req *http.Request
+	var ctx context.Context
+	var rr *httptest.ResponseRecorder
+	var handler http.Handler
+
+	// case #1: No session data
+	// -- create request
+	req = httptest.NewRequest("GET", "/reservation-overview", nil)
+	// -- create ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.ReservationOverview)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// check response
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected response code: %d, got response code: %d", http.StatusTemporaryRedirect, rr.Code)
+	}
+
+	// case #2: Invalid bungalow id
+	// -- create reservation session data
+	reservation := models.Reservation{
+		BungalowID: 4,
 		Bungalow: models.Bungalow{
 			ID:           4,
 			BungalowName: "The Solitude Shack",
@@ -885,6 +1303,65 @@ func TestRepository_ChooseBungalow(t *testing.T) {
 	if rr.Code != http.StatusSeeOther {
 		t.Errorf("Expected response code: %d, got response code: %d", http.StatusSeeOther, rr.Code)
 	}
+	if session.GetInt(ctx, "hold_id") != 1 {
+		t.Error("Expected a hold in session")
+	}
+
+	// case #4: Dates have been taken meanwhile
+
+	// -- create reservation session data
+	takenStart, _ := time.Parse("2006-01-02", "2037-01-01")
+	reservation = models.Reservation{
+		StartDate: takenStart,
+		EndDate:   takenStart.AddDate(0, 0, 1),
+	}
+	// -- create request
+	req = httptest.NewRequest("GET", "/choose-bungalow/1", nil)
+	// -- create ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- add request URI
+	req.RequestURI = "/choose-bungalow/1"
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- add session data
+	session.Put(ctx, "reservation", reservation)
+	// -- create handler
+	handler = http.HandlerFunc(Repo.ChooseBungalow)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/reservation" {
+		t.Errorf("Expected redirect %d to /reservation, got %d to %q", http.StatusSeeOther, rr.Code, rr.Header().Get("Location"))
+	}
+
+	// case #5: Hold cannot be placed in database
+
+	// -- create reservation session data
+	failingStart, _ := time.Parse("2006-01-02", "2038-01-01")
+	reservation = models.Reservation{
+		StartDate: failingStart,
+		EndDate:   failingStart.AddDate(0, 0, 1),
+	}
+	// -- create request
+	req = httptest.NewRequest("GET", "/choose-bungalow/1", nil)
+	// -- create ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- add request URI
+	req.RequestURI = "/choose-bungalow/1"
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- add session data
+	session.Put(ctx, "reservation", reservation)
+	// -- create handler
+	handler = http.HandlerFunc(Repo.ChooseBungalow)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected response code: %d, got response code: %d", http.StatusTemporaryRedirect, rr.Code)
+	}
 }
 
 // BookBungalow
@@ -899,7 +1376,7 @@ func TestRepository_BookBungalow(t *testing.T) {
 	// case #1: No bungalow in db
 
 	// -- create request
-	req = httptest.NewRequest("GET", "/book-bungalow?s=2024-01-01&e=2024-01-02&id=4", nil)
+	req = httptest.NewRequest("GET", "/book-bungalow?s=2036-01-01&e=2036-01-03&id=4", nil)
 	// -- create ctx
 	ctx = getCtx(req)
 	req = req.WithContext(ctx)
@@ -917,7 +1394,7 @@ func TestRepository_BookBungalow(t *testing.T) {
 	// case #2: OK
 
 	// -- create request
-	req = httptest.NewRequest("GET", "/book-bungalow?s=2024-01-01&e=2024-01-02&id=1", nil)
+	req = httptest.NewRequest("GET", "/book-bungalow?s=2036-01-01&e=2036-01-03&id=1", nil)
 	// -- create ctx
 	ctx = getCtx(req)
 	req = req.WithContext(ctx)
@@ -931,6 +1408,48 @@ func TestRepository_BookBungalow(t *testing.T) {
 	if rr.Code != http.StatusSeeOther {
 		t.Errorf("Expected response code: %d, got response code: %d", http.StatusSeeOther, rr.Code)
 	}
+
+	// case #3: stay breaks a stay rule
+
+	// -- create request
+	req = httptest.NewRequest("GET", "/book-bungalow?s=2036-01-01&e=2036-01-02&id=3", nil)
+	// -- create ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.BookBungalow)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Header().Get("Location") != "/reservation" {
+		t.Errorf("Expected redirect to /reservation, got redirect to %q", rr.Header().Get("Location"))
+	}
+	if !strings.Contains(app.Session.GetString(ctx, "error"), "minimum stay") {
+		t.Error("Expected a minimum stay message in session")
+	}
+
+	// case #4: dates have been taken meanwhile
+
+	// -- create request
+	req = httptest.NewRequest("GET", "/book-bungalow?s=2037-01-01&e=2037-01-03&id=1", nil)
+	// -- create ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.BookBungalow)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Header().Get("Location") != "/reservation" {
+		t.Errorf("Expected redirect to /reservation, got redirect to %q", rr.Header().Get("Location"))
+	}
+	if !strings.Contains(app.Session.GetString(ctx, "error"), "booked by someone else") {
+		t.Error("Expected a booked message in session")
+	}
 }
 
 // ShowLogin
@@ -1170,8 +1689,92 @@ func TestRepository_AdminDashboard(t *testing.T) {
 	// -- make request
 	handler.ServeHTTP(rr, req)
 	// -- check response
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status code %d, but got status code %d", http.StatusOK, rr.Code)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Peter Griffin") || !strings.Contains(rr.Body.String(), "Lois Griffin") {
+		t.Errorf("Expected status code %d showing arrivals and departures, but got status code %d", http.StatusOK, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "too_fast") {
+		t.Error("Expected the rejected spam to be shown, but it was not")
+	}
+	if !strings.Contains(rr.Body.String(), "1 new, 2 processed, 1 confirmed") {
+		t.Error("Expected the reservations to be counted by status, but they were not")
+	}
+
+	// case #2: stats fail
+	req, _ = http.NewRequest("GET", "/admin/dashboard?y=2037", nil)
+	// -- get ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.AdminDashboard)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	// case #3: spam rejections fail
+	req, _ = http.NewRequest("GET", "/admin/dashboard?y=2039", nil)
+	// -- get ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.AdminDashboard)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusInternalServerError, rr.Code)
+	}
+}
+
+// AdminDashboardJSON
+func TestRepository_AdminDashboardJSON(t *testing.T) {
+
+	// case #1: OK
+	req := httptest.NewRequest("GET", "/admin/dashboard/data?y=2036", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminDashboardJSON).ServeHTTP(rr, req)
+
+	var resp dashboardJSON
+	err := json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal("failed to parse json", err)
+	}
+	if resp.Year != 2036 || len(resp.Months) != 12 || resp.Months[1] != "Feb" {
+		t.Errorf("Expected the months of 2036, but got %d %v", resp.Year, resp.Months)
+	}
+	if len(resp.Occupancy) != 1 || resp.Occupancy[0].OccupancyRate[1] != 34.5 {
+		t.Errorf("Expected the occupancy of one bungalow, but got %v", resp.Occupancy)
+	}
+	if resp.Stats.Reservations != 4 || resp.Stats.Processed != 2 || resp.Stats.Confirmed != 1 {
+		t.Errorf("Expected the reservation stats, but got %v", resp.Stats)
+	}
+
+	// case #2: occupancy fails
+	req = httptest.NewRequest("GET", "/admin/dashboard/data?y=2038", nil)
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminDashboardJSON).ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusInternalServerError, rr.Code)
+	}
+
+	// case #3: stats fail
+	req = httptest.NewRequest("GET", "/admin/dashboard/data?y=2037", nil)
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminDashboardJSON).ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusInternalServerError, rr.Code)
 	}
 }
 
@@ -1223,41 +1826,54 @@ func TestRepository_AdminAllReservations(t *testing.T) {
 	// -- make request
 	handler.ServeHTTP(rr, req)
 	// -- check response
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status code %d, but got status code %d", http.StatusOK, rr.Code)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Page 1 of 3") {
+		t.Errorf("Expected status code %d showing page 1 of 3, but got status code %d", http.StatusOK, rr.Code)
 	}
-}
 
-// AdminReservationsCalendar
-func TestRepository_AdminReservationsCalendar(t *testing.T) {
-
-	// -- test variables
-	var req *http.Request
-	var ctx context.Context
-	var rr *httptest.ResponseRecorder
-	var handler http.Handler
+	// case #2: parameters are preserved in links
+	req, _ = http.NewRequest("GET", "/admin/reservations-all?search=Pet&sort=full_name&order=desc&page=2", nil)
+	// -- get ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.AdminAllReservations)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	body := rr.Body.String()
+	for _, link := range []string{
+		`/admin/reservations/all/26/show?order=desc&amp;page=2&amp;search=Pet&amp;sort=full_name`,
+		`/admin/reservations-all?order=desc&amp;search=Pet&amp;sort=full_name`,
+		`/admin/reservations-all?order=desc&amp;page=3&amp;search=Pet&amp;sort=full_name`,
+		`/admin/reservations-all?search=Pet&amp;sort=full_name`,
+	} {
+		if !strings.Contains(body, link) {
+			t.Errorf("Expected link %q in the reservation list", link)
+		}
+	}
 
-	// case #1: OK
-	req, _ = http.NewRequest("GET", "/admin/reservations-calendar", nil)
+	// case #3: query fails
+	req, _ = http.NewRequest("GET", "/admin/reservations-all?search=fail", nil)
 	// -- get ctx
 	ctx = getCtx(req)
 	req = req.WithContext(ctx)
 	// -- create response recorder
 	rr = httptest.NewRecorder()
 	// -- create handler
-	handler = http.HandlerFunc(Repo.AdminReservationsCalendar)
+	handler = http.HandlerFunc(Repo.AdminAllReservations)
 	// -- make request
 	handler.ServeHTTP(rr, req)
 	// -- check response
-	if rr.Code != http.StatusOK {
-		t.Errorf("Expected status code %d, but got status code %d", http.StatusOK, rr.Code)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusInternalServerError, rr.Code)
 	}
 }
 
-// AdminShowReservation
-func TestRepository_AdminShowReservation(t *testing.T) {
+// AdminTrashReservations
+func TestRepository_AdminTrashReservations(t *testing.T) {
 
-  //mux.Get("/reservations/{src}/{id}/show", handlers.Repo.AdminShowReservation)
 	// -- test variables
 	var req *http.Request
 	var ctx context.Context
@@ -1265,25 +1881,493 @@ func TestRepository_AdminShowReservation(t *testing.T) {
 	var handler http.Handler
 
 	// case #1: OK
-	req = httptest.NewRequest("GET", "/admin/reservations/all/1/show", nil)
-  // -- set request URI
-  req.RequestURI = "/admin/reservations/all/1/show"
+	req, _ = http.NewRequest("GET", "/admin/reservations-trash", nil)
 	// -- get ctx
 	ctx = getCtx(req)
 	req = req.WithContext(ctx)
 	// -- create response recorder
 	rr = httptest.NewRecorder()
 	// -- create handler
-	handler = http.HandlerFunc(Repo.AdminShowReservation)
+	handler = http.HandlerFunc(Repo.AdminTrashReservations)
 	// -- make request
 	handler.ServeHTTP(rr, req)
 	// -- check response
+	body := rr.Body.String()
 	if rr.Code != http.StatusOK {
 		t.Errorf("Expected status code %d, but got status code %d", http.StatusOK, rr.Code)
 	}
-
-  // case #2: Cannot parse URI
-	req = httptest.NewRequest("GET", "/admin/reservations/all/invalid/show", nil)
+	for _, s := range []string{"/admin/restore-reservation/1/do?", "/admin/reservations/trash/2/show?", "purged after 30 days"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Expected %q in the trash", s)
+		}
+	}
+	if strings.Contains(body, "/admin/reservations/export") {
+		t.Error("Expected no export of the trash")
+	}
+}
+
+// AdminExportReservations
+func TestRepository_AdminExportReservations(t *testing.T) {
+
+	// case #1: csv
+	req := httptest.NewRequest("GET", "/admin/reservations/export?format=csv&status=1", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminExportReservations).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("Expected a csv file, but got status code %d with %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rr.Header().Get("Content-Disposition"), ".csv") {
+		t.Errorf("Expected a csv attachment, but got %q", rr.Header().Get("Content-Disposition"))
+	}
+	expected := "ID,Full Name,Email,Phone,Bungalow,Arrival,Departure,Nights,Adults,Children,Infants,Status,Booked\n" +
+		"1,Peter Griffin,peter@griffin.family,0123,The Solitude Shack,2036-02-01,2036-02-04,3,2,0,0,New,0001-01-01 00:00\n" +
+		"2,\"Glenn \"\"Quagmire\"\", Jr.\",glenn@quagmire.com,,The Solitude Shack,2036-02-01,2036-02-08,7,1,0,0,Processed,0001-01-01 00:00\n"
+	if rr.Body.String() != expected {
+		t.Errorf("Expected csv %q, but got %q", expected, rr.Body.String())
+	}
+
+	// case #2: xlsx
+	req = httptest.NewRequest("GET", "/admin/reservations/export?format=xlsx", nil)
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminExportReservations).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Body.String(), "PK") || !strings.Contains(rr.Header().Get("Content-Disposition"), ".xlsx") {
+		t.Errorf("Expected an xlsx file, but got status code %d with %q", rr.Code, rr.Header().Get("Content-Disposition"))
+	}
+
+	// case #3: query fails
+	req = httptest.NewRequest("GET", "/admin/reservations/export?search=fail", nil)
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminExportReservations).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError || rr.Header().Get("Content-Disposition") != "" {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusInternalServerError, rr.Code)
+	}
+}
+
+// AdminImportReservations
+func TestRepository_AdminImportReservations(t *testing.T) {
+	req := httptest.NewRequest("GET", "/admin/reservations/import", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(Repo.AdminImportReservations).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `type="file"`) {
+		t.Errorf("Expected status code %d with an upload form, but got status code %d", http.StatusOK, rr.Code)
+	}
+}
+
+// AdminPostImportReservations
+func TestRepository_AdminPostImportReservations(t *testing.T) {
+	header := "Full Name,Email,Phone,Bungalow,Arrival,Departure,Adults\n"
+	valid := "Peter Griffin,peter@griffin.family,,The Solitude Shack,2036-02-01,2036-02-04,1\n" +
+		"Lois Griffin,lois@griffin.family,,1,2036-03-01,2036-03-04,1\n"
+	invalid := "Stewie,stewie,,The Solitude Shack,2036-02-03,2036-02-01,1\n"
+
+	// case #1: preview of an uploaded file
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "reservations.csv")
+	part.Write([]byte(header + valid + invalid))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/admin/reservations/import", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(Repo.AdminPostImportReservations).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Import 2 Reservations") {
+		t.Errorf("Expected status code %d with a preview of 2 valid reservations, but got status code %d", http.StatusOK, rr.Code)
+	}
+	for _, msg := range []string{"Email: Requires a vaild email address", "Departure: This date must be after 2036-02-03."} {
+		if !strings.Contains(rr.Body.String(), msg) {
+			t.Errorf("Expected the error %q in the preview", msg)
+		}
+	}
+
+	var importTests = []struct {
+		name               string
+		csv                string
+		expectedStatusCode int
+		expectedBody       string
+		expectedError      string
+	}{
+		{"import", header + valid + invalid, http.StatusOK, "Imported 2 reservations, skipped 1 rows.", ""},
+		{"nothing-valid", header + invalid, http.StatusOK, "0 of the rows can be imported", ""},
+		{"booked-meanwhile", header + "taken,taken@griffin.family,,1,2036-02-01,2036-02-04,1\n", http.StatusSeeOther, "",
+			"Nothing was imported, The Solitude Shack is not available from 2036-02-01 to 2036-02-04 anymore."},
+		{"import-fails", header + "fail,fail@griffin.family,,1,2036-02-01,2036-02-04,1\n", http.StatusInternalServerError, "", ""},
+		{"missing-column", "Full Name,Email\nPeter Griffin,peter@griffin.family\n", http.StatusSeeOther, "", `the column "bungalow" is missing`},
+		{"empty", header, http.StatusSeeOther, "", "there are no reservations in the file"},
+		{"availability-fails", header + "Peter Griffin,peter@griffin.family,,1,2038-01-01,2038-01-04,1\n", http.StatusInternalServerError, "", ""},
+	}
+
+	for _, e := range importTests {
+		postData := url.Values{}
+		postData.Add("confirm", "1")
+		postData.Add("csv", e.csv)
+
+		req := httptest.NewRequest("POST", "/admin/reservations/import", strings.NewReader(postData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminPostImportReservations).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), e.expectedBody) {
+			t.Errorf("for %s expected %q in the page", e.name, e.expectedBody)
+		}
+		errorMessage := session.GetString(ctx, "error")
+		if !strings.Contains(errorMessage, e.expectedError) {
+			t.Errorf("for %s expected error %q, but got %q", e.name, e.expectedError, errorMessage)
+		}
+	}
+}
+
+func TestValidateImportRows(t *testing.T) {
+	records, err := parseImportFile("\ufeffFull Name, Email, Bungalow, Arrival, Departure, Adults, Children, ID\n" +
+		"Peter Griffin,peter@griffin.family,the solitude shack,2036-02-01,2036-02-04,1,0,7\n" +
+		"Lois Griffin,lois@griffin.family,The Solitude Shack,2036-02-04,2036-02-06,1,0,8\n" +
+		"Meg Griffin,meg@griffin.family,Nowhere,2036-02-10,2036-02-12,1,0,9\n" +
+		"Chris Griffin,chris@griffin.family,1,2036-02-10,2036-02-12,1,1,10\n" +
+		"Brian Griffin,brian@griffin.family,1,2037-02-10,2037-02-12,1,0,11\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := Repo.validateImportRows(context.Background(), records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"",
+		"The stay overlaps the reservation in line 2.",
+		"Bungalow: There is no such bungalow.",
+		"Adults: This holiday home can host at most 1 guests, infants not counted.",
+		"The bungalow is already reserved or blocked on these days.",
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("expected %d rows, but got %d", len(expected), len(rows))
+	}
+	for i, row := range rows {
+		if row.Line != i+2 {
+			t.Errorf("expected line %d, but got %d", i+2, row.Line)
+		}
+		if strings.Join(row.Errors, " ") != expected[i] {
+			t.Errorf("for line %d expected errors %q, but got %q", row.Line, expected[i], row.Errors)
+		}
+	}
+	if rows[0].Reservation.BungalowID != 1 || rows[0].Reservation.FullName != "Peter Griffin" || rows[0].Reservation.EndDate.Format("2006-01-02") != "2036-02-04" {
+		t.Errorf("expected the reservation of Peter Griffin, but got %v", rows[0].Reservation)
+	}
+}
+
+func TestReservationQuery(t *testing.T) {
+	var queryTests = []struct {
+		name           string
+		query          string
+		expectedParams string
+	}{
+		{"defaults", "", ""},
+		{"all", "search=+Peter+&bungalow=2&status=1&from=2036-02-01&to=2036-02-28&sort=email&order=desc&page=3&per_page=50",
+			"bungalow=2&from=2036-02-01&order=desc&page=3&per_page=50&search=Peter&sort=email&status=1&to=2036-02-28"},
+		{"invalid", "bungalow=x&status=x&from=01.02.2036&sort=password&order=up&page=-1&per_page=1000", ""},
+		{"default-values", "sort=start_date&page=1&per_page=25", ""},
+	}
+
+	for _, e := range queryTests {
+		values, _ := url.ParseQuery(e.query)
+		params := listParams(reservationQuery(values))
+		if params.Encode() != e.expectedParams {
+			t.Errorf("for %s expected parameters %q, but got %q", e.name, e.expectedParams, params.Encode())
+		}
+	}
+}
+
+// AdminReservationsCalendar
+func TestRepository_AdminReservationsCalendar(t *testing.T) {
+
+	// -- test variables
+	var req *http.Request
+	var ctx context.Context
+	var rr *httptest.ResponseRecorder
+	var handler http.Handler
+
+	// case #1: OK
+	req, _ = http.NewRequest("GET", "/admin/reservations-calendar", nil)
+	// -- get ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.AdminReservationsCalendar)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusOK, rr.Code)
+	}
+
+	// case #2: timeline views of all bungalows
+	var viewTests = []struct {
+		query         string
+		expectedTitle string
+		expectedDays  string
+	}{
+		{"view=week&y=2036&m=02&d=6", "Week of 4 February 2036", "repeat(7, 2.2rem)"},
+		{"view=month&y=2036&m=02", "February 2036", "repeat(29, 2.2rem)"},
+		{"view=quarter&y=2036&m=05&d=1", "Q2 2036", "repeat(91, 2.2rem)"},
+	}
+
+	for _, e := range viewTests {
+		req, _ = http.NewRequest("GET", "/admin/reservations-calendar?"+e.query, nil)
+		// -- get ctx
+		ctx = getCtx(req)
+		req = req.WithContext(ctx)
+		// -- create response recorder
+		rr = httptest.NewRecorder()
+		// -- make request
+		http.HandlerFunc(Repo.AdminReservationsCalendar).ServeHTTP(rr, req)
+		// -- check response
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK {
+			t.Errorf("for %s expected status code %d, but got status code %d", e.query, http.StatusOK, rr.Code)
+		}
+		if !strings.Contains(body, e.expectedTitle) || !strings.Contains(body, e.expectedDays) {
+			t.Errorf("for %s expected %q over %q", e.query, e.expectedTitle, e.expectedDays)
+		}
+		if !strings.Contains(body, "Peter Griffin (New)") || !strings.Contains(body, "Maintenance: painting the walls") {
+			t.Errorf("for %s expected labelled reservation and block bars", e.query)
+		}
+	}
+}
+
+func TestTimelineRows(t *testing.T) {
+	layout := "2006-01-02"
+	first, _ := time.Parse(layout, "2036-02-01")
+	last, _ := time.Parse(layout, "2036-02-07")
+	before, _ := time.Parse(layout, "2036-01-28")
+	third, _ := time.Parse(layout, "2036-02-03")
+	fifth, _ := time.Parse(layout, "2036-02-05")
+	sixth, _ := time.Parse(layout, "2036-02-06")
+
+	bungalows := []models.Bungalow{{ID: 1}, {ID: 2}}
+	restrictions := []models.BungalowRestriction{
+		{ID: 1, BungalowID: 1, StartDate: before, EndDate: third, ReservationID: 4, RestrictionID: models.RestrictionReservation,
+			Restriction: models.Restriction{BlocksAvailability: true},
+			Reservation: models.Reservation{FullName: "Peter Griffin", Status: 1}},
+		{ID: 2, BungalowID: 2, StartDate: fifth, EndDate: sixth, RestrictionID: models.RestrictionOwnerStay, Note: "family visit",
+			Restriction: models.Restriction{RestrictionName: "Owner Stay", BlocksAvailability: true}},
+		{ID: 3, BungalowID: 2, StartDate: fifth, EndDate: fifth, RestrictionID: 6,
+			Restriction: models.Restriction{RestrictionName: "Cleaning", BlocksAvailability: false}},
+	}
+
+	rows := timelineRows(bungalows, restrictions, first, last)
+
+	if len(rows) != 2 || len(rows[0].Days) != 7 {
+		t.Fatalf("Expected 2 rows of 7 days, got %d rows", len(rows))
+	}
+
+	reservation := rows[0].Bars[0]
+	if reservation.Column != 1 || reservation.Span != 3 || reservation.Label != "Peter Griffin (Processed)" {
+		t.Errorf("Expected reservation cut to column 1 spanning 3 days, got column %d spanning %d labelled %q", reservation.Column, reservation.Span, reservation.Label)
+	}
+	if !rows[0].Days[2].Reserved || rows[0].Days[3].Reserved {
+		t.Error("Expected the first three days to be reserved")
+	}
+
+	block := rows[1].Bars[0]
+	if block.Column != 5 || block.Span != 2 || block.Label != "Owner Stay: family visit" {
+		t.Errorf("Expected block at column 5 spanning 2 days, got column %d spanning %d labelled %q", block.Column, block.Span, block.Label)
+	}
+	if rows[1].Days[4].BlockID != 2 || rows[1].Days[5].BlockID != 2 {
+		t.Error("Expected the blocked days to carry the id of the block")
+	}
+	if len(rows[1].Bars) != 2 || rows[1].Days[6].BlockID != 0 {
+		t.Error("Expected the restriction not blocking availability to be drawn without blocking days")
+	}
+}
+
+// AdminCreateReservation
+func TestRepository_AdminCreateReservation(t *testing.T) {
+
+	// -- test variables
+	var req *http.Request
+	var ctx context.Context
+	var rr *httptest.ResponseRecorder
+	var handler http.Handler
+
+	// case #1: fields are prefilled from the url
+	req, _ = http.NewRequest("GET", "/admin/reservations/new?full_name=Peter+Griffin&bungalow_id=1&start_date=2036-02-01", nil)
+	// -- get ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.AdminCreateReservation)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusOK, rr.Code)
+	}
+	for _, s := range []string{`value="Peter Griffin"`, `value="2036-02-01"`, `<option value="1" selected>`, "checked"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Expected %q in the form", s)
+		}
+	}
+}
+
+// AdminPostCreateReservation
+func TestRepository_AdminPostCreateReservation(t *testing.T) {
+
+	var createTests = []struct {
+		name               string
+		fullName           string
+		bungalowID         string
+		startDate          string
+		endDate            string
+		sendEmail          bool
+		expectedStatusCode int
+		expectedError      string
+	}{
+		{"created", "Peter Griffin", "1", "2036-03-01", "2036-03-03", false, http.StatusSeeOther, ""},
+		{"conflicts", "Peter Griffin", "1", "2037-03-01", "2037-03-08", false, http.StatusOK, "Reservation of Peter Griffin from 2037-03-01 to 2037-03-03"},
+		{"taken-meanwhile", "taken", "1", "2036-03-01", "2036-03-03", false, http.StatusOK, "in the meantime"},
+		{"no-such-bungalow", "Peter Griffin", "7", "2036-03-01", "2036-03-03", false, http.StatusOK, "There is no such bungalow."},
+		{"in-the-past", "Peter Griffin", "1", "2020-03-01", "2020-03-03", false, http.StatusOK, "This date cannot be in the past."},
+		{"create-fails", "fail", "1", "2036-03-01", "2036-03-03", false, http.StatusInternalServerError, ""},
+		{"from-inquiry", "Peter Griffin", "1", "2036-03-01", "2036-03-03", false, http.StatusSeeOther, ""},
+		{"link-fails", "Peter Griffin", "1", "2036-03-01", "2036-03-03", false, http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range createTests {
+		postData := url.Values{}
+		postData.Add("full_name", e.fullName)
+		postData.Add("email", "peter@griffin.family")
+		postData.Add("adults", "1")
+		postData.Add("bungalow_id", e.bungalowID)
+		postData.Add("start_date", e.startDate)
+		postData.Add("end_date", e.endDate)
+		postData.Add("notes", "late arrival")
+		switch e.name {
+		case "from-inquiry":
+			postData.Add("inquiry_id", "1")
+		case "link-fails":
+			postData.Add("inquiry_id", "99")
+		}
+
+		req := httptest.NewRequest("POST", "/admin/reservations/new", strings.NewReader(postData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminPostCreateReservation).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), e.expectedError) {
+			t.Errorf("for %s expected %q on the form", e.name, e.expectedError)
+		}
+		if rr.Code == http.StatusSeeOther && rr.Header().Get("Location") != "/admin/reservations/all/1/show" {
+			t.Errorf("for %s expected redirect to the new reservation, but got %q", e.name, rr.Header().Get("Location"))
+		}
+	}
+
+	// case #2: the confirmation is emailed to the guest
+	mailChan := app.MailChan
+	app.MailChan = make(chan models.MailData, 1)
+	defer func() {
+		app.MailChan = mailChan
+	}()
+
+	postData := url.Values{}
+	postData.Add("full_name", "Peter Griffin")
+	postData.Add("email", "peter@griffin.family")
+	postData.Add("adults", "1")
+	postData.Add("bungalow_id", "1")
+	postData.Add("start_date", "2036-03-01")
+	postData.Add("end_date", "2036-03-03")
+	postData.Add("send_email", "1")
+	// -- create request
+	req := httptest.NewRequest("POST", "/admin/reservations/new", strings.NewReader(postData.Encode()))
+	// -- get ctx
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// -- create response recorder
+	rr := httptest.NewRecorder()
+	// -- make request
+	http.HandlerFunc(Repo.AdminPostCreateReservation).ServeHTTP(rr, req)
+	// -- check response
+	select {
+	case msg := <-app.MailChan:
+		if msg.To != "peter@griffin.family" || msg.Subject != "Confirmation of your reservation" {
+			t.Errorf("Expected the confirmation to the guest, but got %q to %s", msg.Subject, msg.To)
+		}
+	default:
+		t.Error("Expected an email to the guest, but none was sent")
+	}
+}
+
+// AdminShowReservation
+func TestRepository_AdminShowReservation(t *testing.T) {
+
+  //mux.Get("/reservations/{src}/{id}/show", handlers.Repo.AdminShowReservation)
+	// -- test variables
+	var req *http.Request
+	var ctx context.Context
+	var rr *httptest.ResponseRecorder
+	var handler http.Handler
+
+	// case #1: OK
+	req = httptest.NewRequest("GET", "/admin/reservations/all/1/show", nil)
+  // -- set request URI
+  req.RequestURI = "/admin/reservations/all/1/show"
+	// -- get ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.AdminShowReservation)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "<strong>status:</strong> <del>0</del> 1") {
+		t.Errorf("Expected status code %d showing the history, but got status code %d", http.StatusOK, rr.Code)
+	}
+	for _, text := range []string{"needs crib", "Your arrival", "the keys are under the mat."} {
+		if !strings.Contains(rr.Body.String(), text) {
+			t.Errorf("Expected the notes and messages of the reservation, but %q is missing", text)
+		}
+	}
+
+  // case #2: Cannot parse URI
+	req = httptest.NewRequest("GET", "/admin/reservations/all/invalid/show", nil)
   // -- set request URI
   req.RequestURI = "/admin/reservations/all/invalid/show"
 	// -- get ctx
@@ -1293,16 +2377,16 @@ func TestRepository_AdminShowReservation(t *testing.T) {
 	rr = httptest.NewRecorder()
   // -- set up logging
   var errBuf bytes.Buffer
-  defer func() {
-    app.ErrorLog.SetOutput(os.Stdout)
-  }()
-  app.ErrorLog.SetOutput(&errBuf)
+  defer func(logger *slog.Logger) {
+    app.Logger = logger
+  }(app.Logger)
+  app.Logger = logging.New(&errBuf, slog.LevelInfo, false)
 	// -- create handler
 	handler = http.HandlerFunc(Repo.AdminShowReservation)
 	// -- make request
 	handler.ServeHTTP(rr, req)
   errOutput := errBuf.String()
-  if !strings.Contains(errOutput, "parsing \"invalid\"") {
+  if !strings.Contains(errOutput, `parsing \"invalid\"`) {
     t.Error("Explected an error to be logged trying to convert 'invalid' to int")
   }
 
@@ -1316,10 +2400,7 @@ func TestRepository_AdminShowReservation(t *testing.T) {
 	// -- create response recorder
 	rr = httptest.NewRecorder()
   // -- set up logging
-  defer func() {
-    app.ErrorLog.SetOutput(os.Stdout)
-  }()
-  app.ErrorLog.SetOutput(&errBuf)
+  errBuf.Reset()
 	// -- create handler
 	handler = http.HandlerFunc(Repo.AdminShowReservation)
 	// -- make request
@@ -1333,19 +2414,1107 @@ func TestRepository_AdminShowReservation(t *testing.T) {
 // AdminPostShowReservation
 func TestRepository_AdminPostShowReservation(t *testing.T) {
 
-}
-
-// AdminProcessReservation
-func TestRepository_AdminProcessReservation(t *testing.T) {
+	// -- test variables
+	var postData url.Values
+	var req *http.Request
+	var ctx context.Context
+	var rr *httptest.ResponseRecorder
+	var handler http.Handler
 
-}
+	// case #1: OK
+	postData = url.Values{}
+	postData.Add("full_name", "Peter Griffin")
+	postData.Add("email", "peter@griffin.family")
+	postData.Add("phone", "1234567890")
+	postData.Add("adults", "1")
+	postData.Add("bungalow_id", "1")
+	postData.Add("start_date", "2036-02-01")
+	postData.Add("end_date", "2036-02-05")
+	// -- create request
+	req = httptest.NewRequest("POST", "/admin/reservations/all/1", strings.NewReader(postData.Encode()))
+	// -- set request URI
+	req.RequestURI = "/admin/reservations/all/1"
+	// -- get ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.AdminPostShowReservation)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusSeeOther, rr.Code)
+	}
 
-// AdminDeleteReservation
-func TestRepository_AdminDeleteReservation(t *testing.T) {
+	// case #2: invalid email renders the form again
+	postData = url.Values{}
+	postData.Add("full_name", "Peter Griffin")
+	postData.Add("email", "peter")
+	postData.Add("phone", "1234567890")
+	postData.Add("adults", "2")
+	postData.Add("bungalow_id", "1")
+	postData.Add("start_date", "2036-02-01")
+	postData.Add("end_date", "2036-02-05")
+	// -- create request
+	req = httptest.NewRequest("POST", "/admin/reservations/all/1", strings.NewReader(postData.Encode()))
+	// -- set request URI
+	req.RequestURI = "/admin/reservations/all/1"
+	// -- get ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- create handler
+	handler = http.HandlerFunc(Repo.AdminPostShowReservation)
+	// -- make request
+	handler.ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusOK, rr.Code)
+	}
 
-}
+	var moveTests = []struct {
+		name               string
+		fullName           string
+		bungalowID         string
+		startDate          string
+		endDate            string
+		expectedStatusCode int
+		expectedError      string
+	}{
+		{"moved", "Peter Griffin", "1", "2036-03-01", "2036-03-03", http.StatusSeeOther, ""},
+		{"dates-taken", "Peter Griffin", "1", "2037-03-01", "2037-03-03", http.StatusOK, "already reserved or blocked"},
+		{"no-such-bungalow", "Peter Griffin", "7", "2036-03-01", "2036-03-03", http.StatusOK, "There is no such bungalow."},
+		{"departure-before-arrival", "Peter Griffin", "1", "2036-03-03", "2036-03-01", http.StatusOK, "This date must be after 2036-03-03."},
+		{"invalid-date", "Peter Griffin", "1", "01.03.2036", "2036-03-03", http.StatusOK, "Requires a valid date"},
+		{"update-fails", "fail", "1", "2036-03-01", "2036-03-03", http.StatusInternalServerError, ""},
+	}
 
-// AdminPostReservationsCalendar
-func TestRepository_AdminPostReservationsCalendar(t *testing.T) {
+	for _, e := range moveTests {
+		postData = url.Values{}
+		postData.Add("full_name", e.fullName)
+		postData.Add("email", "peter@griffin.family")
+		postData.Add("adults", "1")
+		postData.Add("bungalow_id", e.bungalowID)
+		postData.Add("start_date", e.startDate)
+		postData.Add("end_date", e.endDate)
+
+		req = httptest.NewRequest("POST", "/admin/reservations/all/1", strings.NewReader(postData.Encode()))
+		req.RequestURI = "/admin/reservations/all/1"
+		ctx = getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr = httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminPostShowReservation).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), e.expectedError) {
+			t.Errorf("for %s expected error %q in the form", e.name, e.expectedError)
+		}
+	}
 
+	// case #3: the reservation has been deleted meanwhile
+	postData = url.Values{}
+	postData.Add("full_name", "deleted")
+	postData.Add("email", "peter@griffin.family")
+	postData.Add("adults", "1")
+	postData.Add("bungalow_id", "1")
+	postData.Add("start_date", "2036-02-01")
+	postData.Add("end_date", "2036-02-05")
+	// -- create request
+	req = httptest.NewRequest("POST", "/admin/reservations/all/1", strings.NewReader(postData.Encode()))
+	// -- set request URI
+	req.RequestURI = "/admin/reservations/all/1"
+	// -- get ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- make request
+	http.HandlerFunc(Repo.AdminPostShowReservation).ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusSeeOther || !strings.Contains(session.GetString(ctx, "error"), "deleted meanwhile") ||
+		session.GetString(ctx, "success") != "" {
+		t.Errorf("Expected redirect %d with an error, but got %d", http.StatusSeeOther, rr.Code)
+	}
+
+	// case #4: the guest is emailed about the change
+	mailChan := app.MailChan
+	app.MailChan = make(chan models.MailData, 1)
+	defer func() {
+		app.MailChan = mailChan
+	}()
+
+	postData = url.Values{}
+	postData.Add("full_name", "Peter Griffin")
+	postData.Add("email", "peter@griffin.family")
+	postData.Add("adults", "1")
+	postData.Add("bungalow_id", "1")
+	postData.Add("start_date", "2036-03-01")
+	postData.Add("end_date", "2036-03-03")
+	postData.Add("notify_guest", "1")
+	// -- create request
+	req = httptest.NewRequest("POST", "/admin/reservations/all/1", strings.NewReader(postData.Encode()))
+	// -- set request URI
+	req.RequestURI = "/admin/reservations/all/1"
+	// -- get ctx
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	// -- set headers
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- make request
+	http.HandlerFunc(Repo.AdminPostShowReservation).ServeHTTP(rr, req)
+	// -- check response
+	select {
+	case msg := <-app.MailChan:
+		if msg.To != "peter@griffin.family" || !strings.Contains(msg.Content, "is now from 2036-03-01 to 2036-03-03") {
+			t.Errorf("Expected the guest to be told about the new dates, but got %q to %s", msg.Content, msg.To)
+		}
+	default:
+		t.Error("Expected an email to the guest, but none was sent")
+	}
+}
+
+// AdminPostReservationNote
+func TestRepository_AdminPostReservationNote(t *testing.T) {
+
+	var noteTests = []struct {
+		name               string
+		body               string
+		year               string
+		expectedStatusCode int
+		expectedLocation   string
+		expectedSession    string
+	}{
+		{"added", "late arrival", "", http.StatusSeeOther, "/admin/reservations/all/1/show?page=2", "success"},
+		{"from-calendar", "late arrival", "2036", http.StatusSeeOther, "/admin/reservations/calendar/1/show?y=2036&m=02", "success"},
+		{"empty", "", "", http.StatusSeeOther, "/admin/reservations/all/1/show?page=2", "error"},
+		{"insert-fails", "fail", "", http.StatusInternalServerError, "", ""},
+	}
+
+	for _, e := range noteTests {
+		src := "all"
+		postData := url.Values{}
+		postData.Add("body", e.body)
+		postData.Add("list_query", "page=2")
+		if e.year != "" {
+			src = "calendar"
+			postData.Add("year", e.year)
+			postData.Add("month", "02")
+		}
+
+		req := httptest.NewRequest("POST", "/admin/reservations/"+src+"/1/notes", strings.NewReader(postData.Encode()))
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("src", src)
+		rctx.URLParams.Add("id", "1")
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminPostReservationNote).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode || rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("for %s expected %d to %q, but got %d to %q", e.name, e.expectedStatusCode, e.expectedLocation, rr.Code, rr.Header().Get("Location"))
+		}
+		if e.expectedSession != "" && session.GetString(ctx, e.expectedSession) == "" {
+			t.Errorf("for %s expected %s message in the session, but got none", e.name, e.expectedSession)
+		}
+	}
+}
+
+// AdminPostReservationMessage
+func TestRepository_AdminPostReservationMessage(t *testing.T) {
+
+	mailChan := app.MailChan
+	app.MailChan = make(chan models.MailData, 1)
+	defer func() {
+		app.MailChan = mailChan
+	}()
+
+	var messageTests = []struct {
+		name               string
+		id                 string
+		subject            string
+		body               string
+		expectedStatusCode int
+		expectedSession    string
+	}{
+		{"sent", "1", "Your arrival", "Dear Peter,\nthe keys are <under> the mat.", http.StatusSeeOther, "success"},
+		{"no-subject", "1", "", "Dear Peter", http.StatusSeeOther, "error"},
+		{"no-reservation", "4", "Your arrival", "Dear Peter", http.StatusInternalServerError, ""},
+		{"log-fails", "1", "Your arrival", "fail", http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range messageTests {
+		postData := url.Values{}
+		postData.Add("subject", e.subject)
+		postData.Add("body", e.body)
+
+		req := httptest.NewRequest("POST", "/admin/reservations/all/"+e.id+"/messages", strings.NewReader(postData.Encode()))
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("src", "all")
+		rctx.URLParams.Add("id", e.id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminPostReservationMessage).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if e.expectedSession != "" && session.GetString(ctx, e.expectedSession) == "" {
+			t.Errorf("for %s expected %s message in the session, but got none", e.name, e.expectedSession)
+		}
+
+		select {
+		case msg := <-app.MailChan:
+			if e.name != "sent" {
+				t.Errorf("for %s expected no email, but got %q", e.name, msg.Subject)
+			} else if msg.Subject != "Your arrival" || !strings.Contains(msg.Content, "Dear Peter,<br>the keys are &lt;under&gt; the mat.") {
+				t.Errorf("for %s expected the escaped message, but got %q", e.name, msg.Content)
+			}
+		default:
+			if e.name == "sent" {
+				t.Errorf("for %s expected an email to the guest, but none was sent", e.name)
+			}
+		}
+	}
+}
+
+// AdminProcessReservation
+func TestRepository_AdminProcessReservation(t *testing.T) {
+
+	var processTests = []struct {
+		name             string
+		url              string
+		expectedLocation string
+	}{
+		{"list", "/admin/process-reservation/new/1/do", "/admin/reservations-new"},
+		{"list-params", "/admin/process-reservation/new/1/do?y=&m=&search=Pet&page=2", "/admin/reservations-new?page=2&search=Pet"},
+		{"calendar", "/admin/process-reservation/calendar/1/do?y=2036&m=02", "/admin/reservations-calendar?y=2036&m=02"},
+		{"process-fails", "/admin/process-reservation/new/99/do", "/admin/reservations-new"},
+	}
+
+	for _, e := range processTests {
+		req := httptest.NewRequest("GET", e.url, nil)
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("src", strings.Split(e.url, "/")[3])
+		rctx.URLParams.Add("id", strings.Split(e.url, "/")[4])
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminProcessReservation).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("for %s expected redirect %d to %q, but got %d to %q", e.name, http.StatusSeeOther, e.expectedLocation, rr.Code, rr.Header().Get("Location"))
+		}
+		if e.name == "process-fails" && app.Session.GetString(ctx, "success") != "" {
+			t.Errorf("for %s expected no success message, but got %q", e.name, app.Session.GetString(ctx, "success"))
+		}
+		if e.name == "process-fails" && app.Session.GetString(ctx, "error") == "" {
+			t.Errorf("for %s expected an error message in session, but got none", e.name)
+		}
+	}
+}
+
+// AdminDeleteReservation
+func TestRepository_AdminDeleteReservation(t *testing.T) {
+
+	var deleteTests = []struct {
+		name               string
+		url                string
+		id                 string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"list", "/admin/delete-reservation/all/1/do?y=&m=&search=Pet", "1", http.StatusSeeOther, "/admin/reservations-all?search=Pet"},
+		{"calendar", "/admin/delete-reservation/calendar/1/do?y=2036&m=02", "1", http.StatusSeeOther, "/admin/reservations-calendar?y=2036&m=02"},
+		{"delete-fails", "/admin/delete-reservation/all/99/do", "99", http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range deleteTests {
+		req := httptest.NewRequest("GET", e.url, nil)
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("src", strings.Split(e.url, "/")[3])
+		rctx.URLParams.Add("id", e.id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminDeleteReservation).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode || rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("for %s expected %d to %q, but got %d to %q", e.name, e.expectedStatusCode, e.expectedLocation, rr.Code, rr.Header().Get("Location"))
+		}
+		if rr.Code == http.StatusSeeOther && session.GetString(ctx, "success") != "Reservation moved to the trash" {
+			t.Errorf("for %s expected the reservation to be moved to the trash", e.name)
+		}
+	}
+}
+
+// AdminRestoreReservation
+func TestRepository_AdminRestoreReservation(t *testing.T) {
+
+	var restoreTests = []struct {
+		name               string
+		id                 string
+		expectedStatusCode int
+		expectedSession    string
+	}{
+		{"restored", "1", http.StatusSeeOther, "success"},
+		{"dates-taken", "2", http.StatusSeeOther, "error"},
+		{"restore-fails", "99", http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range restoreTests {
+		req := httptest.NewRequest("GET", "/admin/restore-reservation/"+e.id+"/do?page=2", nil)
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminRestoreReservation).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if rr.Code != http.StatusSeeOther {
+			continue
+		}
+		if rr.Header().Get("Location") != "/admin/reservations-trash?page=2" {
+			t.Errorf("for %s expected redirect to the trash, but got redirect to %q", e.name, rr.Header().Get("Location"))
+		}
+		if session.GetString(ctx, e.expectedSession) == "" {
+			t.Errorf("for %s expected %s message in the session, but got none", e.name, e.expectedSession)
+		}
+	}
+}
+
+// AdminPostReservationsCalendar
+func TestRepository_AdminPostReservationsCalendar(t *testing.T) {
+
+	var calendarTests = []struct {
+		name               string
+		removeBlocks       []string
+		addBlocks          []string
+		expectedStatusCode int
+		expectedWarning    string
+	}{
+		{"nothing", nil, nil, http.StatusSeeOther, ""},
+		{"add-and-remove", []string{"5"}, []string{"1:2036-02-10", "1:2036-02-11", "1:2036-02-13"}, http.StatusSeeOther, ""},
+		{"removed-meanwhile", []string{"6"}, nil, http.StatusSeeOther, "removed meanwhile"},
+		{"added-meanwhile", nil, []string{"1:2037-02-10"}, http.StatusSeeOther, "2037-02-10 to 2037-02-10 has been reserved or blocked meanwhile"},
+		{"invalid-remove", []string{"5:1"}, nil, http.StatusBadRequest, ""},
+		{"invalid-add", nil, []string{"1:10.02.2036"}, http.StatusBadRequest, ""},
+		{"remove-fails", []string{"99"}, nil, http.StatusInternalServerError, ""},
+		{"add-fails", nil, []string{"999:2036-02-10"}, http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range calendarTests {
+		postData := url.Values{}
+		postData.Add("y", "2036")
+		postData.Add("m", "02")
+		for _, op := range e.removeBlocks {
+			postData.Add("remove_block", op)
+		}
+		for _, op := range e.addBlocks {
+			postData.Add("add_block", op)
+		}
+
+		req := httptest.NewRequest("POST", "/admin/reservations-calendar", strings.NewReader(postData.Encode()))
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminPostReservationsCalendar).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if rr.Code != http.StatusSeeOther {
+			continue
+		}
+		if rr.Header().Get("Location") != "/admin/reservations-calendar?y=2036&m=02" {
+			t.Errorf("for %s expected redirect to the calendar, but got redirect to %q", e.name, rr.Header().Get("Location"))
+		}
+		warning := session.GetString(ctx, "warning")
+		if e.expectedWarning == "" && (warning != "" || session.GetString(ctx, "success") == "") {
+			t.Errorf("for %s expected the changes to be saved, but got warning %q", e.name, warning)
+		}
+		if !strings.Contains(warning, e.expectedWarning) {
+			t.Errorf("for %s expected warning %q, but got %q", e.name, e.expectedWarning, warning)
+		}
+	}
+}
+
+func TestBlockRanges(t *testing.T) {
+	blocks, err := blockRanges([]string{"2:2036-02-03", "1:2036-02-02", "1:2036-02-01", "1:2036-02-02", "1:2036-02-05"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"1 2036-02-01 2036-02-02", "1 2036-02-05 2036-02-05", "2 2036-02-03 2036-02-03"}
+	if len(blocks) != len(expected) {
+		t.Fatalf("expected %d blocks, but got %d", len(expected), len(blocks))
+	}
+	for i, b := range blocks {
+		got := fmt.Sprintf("%d %s %s", b.BungalowID, b.StartDate.Format("2006-01-02"), b.EndDate.Format("2006-01-02"))
+		if got != expected[i] {
+			t.Errorf("expected block %q, but got %q", expected[i], got)
+		}
+		if b.RestrictionID != models.RestrictionOwnerStay {
+			t.Errorf("expected an owner stay, but got restriction %d", b.RestrictionID)
+		}
+	}
+
+	_, err = blockRanges([]string{"2036-02-03"})
+	if err == nil {
+		t.Error("expected an error for a day without bungalow")
+	}
+}
+
+// AdminPostBlock
+func TestRepository_AdminPostBlock(t *testing.T) {
+
+	var blockTests = []struct {
+		name               string
+		bungalowID         string
+		startDate          string
+		endDate            string
+		restrictionID      string
+		expectedStatusCode int
+		expectedError      string
+	}{
+		{"ok", "1", "2036-02-01", "2036-02-05", "4", http.StatusSeeOther, ""},
+		{"one-day", "1", "2036-02-01", "2036-02-01", "2", http.StatusSeeOther, ""},
+		{"unknown-type", "1", "2036-02-01", "2036-02-05", "1", http.StatusSeeOther, "Type:"},
+		{"last-before-first", "1", "2036-02-05", "2036-02-01", "5", http.StatusSeeOther, "Last day:"},
+		{"days-taken", "1", "2037-02-01", "2037-02-05", "2", http.StatusSeeOther, "already reserved or blocked"},
+		{"days-taken-not-blocking", "1", "2037-02-01", "2037-02-05", "6", http.StatusSeeOther, ""},
+		{"insert-fails", "999", "2036-02-01", "2036-02-05", "2", http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range blockTests {
+		postData := url.Values{}
+		postData.Add("bungalow_id", e.bungalowID)
+		postData.Add("start_date", e.startDate)
+		postData.Add("end_date", e.endDate)
+		postData.Add("restriction_id", e.restrictionID)
+		postData.Add("note", "painting the walls")
+		postData.Add("y", "2036")
+		postData.Add("m", "02")
+		// -- create request
+		req := httptest.NewRequest("POST", "/admin/blocks", strings.NewReader(postData.Encode()))
+		// -- get ctx
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		// -- set headers
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		// -- create response recorder
+		rr := httptest.NewRecorder()
+		// -- make request
+		http.HandlerFunc(Repo.AdminPostBlock).ServeHTTP(rr, req)
+		// -- check response
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got status code %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if rr.Code == http.StatusSeeOther && rr.Header().Get("Location") != "/admin/reservations-calendar?y=2036&m=02" {
+			t.Errorf("for %s expected redirect to the calendar, but got redirect to %q", e.name, rr.Header().Get("Location"))
+		}
+		errorMessage := session.GetString(ctx, "error")
+		if e.expectedError == "" && errorMessage != "" {
+			t.Errorf("for %s expected no error, but got %q", e.name, errorMessage)
+		}
+		if !strings.Contains(errorMessage, e.expectedError) {
+			t.Errorf("for %s expected error %q, but got %q", e.name, e.expectedError, errorMessage)
+		}
+	}
+}
+
+// AdminDeleteBlock
+func TestRepository_AdminDeleteBlock(t *testing.T) {
+
+	// case #1: OK
+	// -- create request
+	req := httptest.NewRequest("GET", "/admin/delete-block/1/do?y=2036&m=02", nil)
+	// -- get ctx
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr := httptest.NewRecorder()
+	// -- make request
+	http.HandlerFunc(Repo.AdminDeleteBlock).ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/reservations-calendar?y=2036&m=02" {
+		t.Errorf("Expected redirect %d to the calendar, but got %d to %q", http.StatusSeeOther, rr.Code, rr.Header().Get("Location"))
+	}
+
+	// case #2: block removed meanwhile
+	// -- create request
+	req = httptest.NewRequest("GET", "/admin/delete-block/6/do?y=2036&m=02", nil)
+	// -- get ctx
+	ctx = getCtx(req)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "6")
+	ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr = httptest.NewRecorder()
+	// -- make request
+	http.HandlerFunc(Repo.AdminDeleteBlock).ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusSeeOther || session.GetString(ctx, "warning") == "" {
+		t.Errorf("Expected redirect %d with a warning, but got %d", http.StatusSeeOther, rr.Code)
+	}
+}
+
+// AdminInquiries
+func TestRepository_AdminInquiries(t *testing.T) {
+
+	var inboxTests = []struct {
+		name         string
+		url          string
+		expectedText string
+	}{
+		{"open", "/admin/inquiries", "/admin/inquiries/1/show"},
+		{"answered", "/admin/inquiries?show=answered", "/admin/inquiries/2/show"},
+	}
+
+	for _, e := range inboxTests {
+		req := httptest.NewRequest("GET", e.url, nil)
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminInquiries).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), e.expectedText) {
+			t.Errorf("for %s expected status code %d listing %q, but got %d", e.name, http.StatusOK, e.expectedText, rr.Code)
+		}
+	}
+}
+
+// AdminShowInquiry
+func TestRepository_AdminShowInquiry(t *testing.T) {
+
+	var showTests = []struct {
+		name               string
+		id                 string
+		expectedStatusCode int
+		expectedText       string
+	}{
+		{"open", "1", http.StatusOK, "/admin/reservations/new?bungalow_id=1&amp;email=peter%40griffin.family&amp;end_date=2036-02-08&amp;full_name=Peter&#43;Griffin&amp;inquiry_id=1"},
+		{"answered", "2", http.StatusOK, "/admin/answer-inquiry/2/do?answered=0"},
+		{"invalid-id", "x", http.StatusBadRequest, ""},
+		{"not-found", "3", http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range showTests {
+		req := httptest.NewRequest("GET", "/admin/inquiries/"+e.id+"/show", nil)
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminShowInquiry).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), e.expectedText) {
+			t.Errorf("for %s expected %q on the page", e.name, e.expectedText)
+		}
+	}
+}
+
+// AdminAnswerInquiry
+func TestRepository_AdminAnswerInquiry(t *testing.T) {
+
+	var answerTests = []struct {
+		name               string
+		id                 string
+		query              string
+		expectedStatusCode int
+		expectedLocation   string
+	}{
+		{"answered", "1", "", http.StatusSeeOther, "/admin/inquiries"},
+		{"opened-again", "2", "?answered=0", http.StatusSeeOther, "/admin/inquiries?show=answered"},
+		{"update-fails", "99", "", http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range answerTests {
+		req := httptest.NewRequest("GET", "/admin/answer-inquiry/"+e.id+"/do"+e.query, nil)
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(Repo.AdminAnswerInquiry).ServeHTTP(rr, req)
+
+		if rr.Code != e.expectedStatusCode || rr.Header().Get("Location") != e.expectedLocation {
+			t.Errorf("for %s expected %d to %q, but got %d to %q", e.name, e.expectedStatusCode, e.expectedLocation, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+}
+
+// AdminAudit
+func TestRepository_AdminAudit(t *testing.T) {
+
+	// case #1: OK
+	req := httptest.NewRequest("GET", "/admin/audit?entity=block&action=delete&from=2036-02-01", nil)
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminAudit).ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusOK, rr.Code)
+	}
+	for _, s := range []string{"<del>painting the walls</del>", `<option value="block" selected>`, `value="2036-02-01"`, "192.0.2.1"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Expected %q in the audit log", s)
+		}
+	}
+
+	// case #2: query fails
+	req = httptest.NewRequest("GET", "/admin/audit?user=99", nil)
+	ctx = getCtx(req)
+	req = req.WithContext(ctx)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Repo.AdminAudit).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, but got status code %d", http.StatusInternalServerError, rr.Code)
+	}
+}
+
+func TestAuditQuery(t *testing.T) {
+	var queryTests = []struct {
+		query       string
+		expectedURL string
+	}{
+		{"", "/admin/audit"},
+		{"user=3&action=update&entity=reservation&entity_id=7&from=2036-02-01&to=2036-02-28&page=2",
+			"/admin/audit?action=update&entity=reservation&entity_id=7&from=2036-02-01&page=2&to=2036-02-28&user=3"},
+		{"user=x&entity_id=x&from=x&page=0", "/admin/audit"},
+	}
+
+	for _, e := range queryTests {
+		values, _ := url.ParseQuery(e.query)
+		if got := auditURL(auditQuery(values)); got != e.expectedURL {
+			t.Errorf("for %q expected %q, but got %q", e.query, e.expectedURL, got)
+		}
+	}
+}
+
+// AdminRestrictions
+func TestRepository_AdminRestrictions(t *testing.T) {
+
+	// case #1: OK
+	// -- create request
+	req := httptest.NewRequest("GET", "/admin/restrictions", nil)
+	// -- get ctx
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr := httptest.NewRecorder()
+	// -- make request
+	http.HandlerFunc(Repo.AdminRestrictions).ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Seasonal Closure") {
+		t.Errorf("Expected status code %d listing the restriction types, but got status code %d", http.StatusOK, rr.Code)
+	}
+}
+
+// AdminPostRestriction
+func TestRepository_AdminPostRestriction(t *testing.T) {
+
+	var restrictionTests = []struct {
+		name               string
+		restrictionName    string
+		color              string
+		expectedStatusCode int
+	}{
+		{"ok", "Cleaning", "#20c997", http.StatusSeeOther},
+		{"missing-name", "", "#20c997", http.StatusOK},
+		{"invalid-color", "Cleaning", "green", http.StatusOK},
+		{"insert-fails", "fail", "#20c997", http.StatusInternalServerError},
+	}
+
+	for _, e := range restrictionTests {
+		postData := url.Values{}
+		postData.Add("restriction_name", e.restrictionName)
+		postData.Add("color", e.color)
+		postData.Add("blocks_availability", "on")
+		// -- create request
+		req := httptest.NewRequest("POST", "/admin/restrictions", strings.NewReader(postData.Encode()))
+		// -- get ctx
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		// -- set headers
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		// -- create response recorder
+		rr := httptest.NewRecorder()
+		// -- make request
+		http.HandlerFunc(Repo.AdminPostRestriction).ServeHTTP(rr, req)
+		// -- check response
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got status code %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+	}
+}
+
+// AdminShowRestriction
+func TestRepository_AdminShowRestriction(t *testing.T) {
+
+	var showTests = []struct {
+		name               string
+		id                 string
+		expectedStatusCode int
+	}{
+		{"ok", "2", http.StatusOK},
+		{"not-found", "99", http.StatusInternalServerError},
+	}
+
+	for _, e := range showTests {
+		// -- create request
+		req := httptest.NewRequest("GET", "/admin/restrictions/"+e.id+"/show", nil)
+		// -- get ctx with the id url parameter
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		// -- create response recorder
+		rr := httptest.NewRecorder()
+		// -- make request
+		http.HandlerFunc(Repo.AdminShowRestriction).ServeHTTP(rr, req)
+		// -- check response
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got status code %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+	}
+}
+
+// AdminPostShowRestriction
+func TestRepository_AdminPostShowRestriction(t *testing.T) {
+
+	var updateTests = []struct {
+		name               string
+		id                 string
+		blocksAvailability string
+		expectedStatusCode int
+	}{
+		{"ok", "4", "on", http.StatusSeeOther},
+		{"not-blocking", "6", "", http.StatusSeeOther},
+		{"reservation-not-blocking", "1", "", http.StatusOK},
+		{"not-found", "99", "on", http.StatusInternalServerError},
+	}
+
+	for _, e := range updateTests {
+		postData := url.Values{}
+		postData.Add("restriction_name", "Some Type")
+		postData.Add("color", "#123456")
+		if e.blocksAvailability != "" {
+			postData.Add("blocks_availability", e.blocksAvailability)
+		}
+		// -- create request
+		req := httptest.NewRequest("POST", "/admin/restrictions/"+e.id, strings.NewReader(postData.Encode()))
+		// -- get ctx with the id url parameter
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		// -- set headers
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		// -- create response recorder
+		rr := httptest.NewRecorder()
+		// -- make request
+		http.HandlerFunc(Repo.AdminPostShowRestriction).ServeHTTP(rr, req)
+		// -- check response
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got status code %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+	}
+}
+
+// AdminDeleteRestriction
+func TestRepository_AdminDeleteRestriction(t *testing.T) {
+
+	var deleteTests = []struct {
+		name          string
+		id            string
+		expectedError string
+	}{
+		{"ok", "6", ""},
+		{"built-in", "2", "Built-in restriction types"},
+		{"in-use", "7", "still in use"},
+	}
+
+	for _, e := range deleteTests {
+		// -- create request
+		req := httptest.NewRequest("GET", "/admin/delete-restriction/"+e.id+"/do", nil)
+		// -- get ctx with the id url parameter
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		ctx = context.WithValue(ctx, chi.RouteCtxKey, rctx)
+		req = req.WithContext(ctx)
+		// -- create response recorder
+		rr := httptest.NewRecorder()
+		// -- make request
+		http.HandlerFunc(Repo.AdminDeleteRestriction).ServeHTTP(rr, req)
+		// -- check response
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/restrictions" {
+			t.Errorf("for %s expected redirect %d to /admin/restrictions, but got %d to %q", e.name, http.StatusSeeOther, rr.Code, rr.Header().Get("Location"))
+		}
+		errorMessage := session.GetString(ctx, "error")
+		if e.expectedError == "" && errorMessage != "" {
+			t.Errorf("for %s expected no error, but got %q", e.name, errorMessage)
+		}
+		if !strings.Contains(errorMessage, e.expectedError) {
+			t.Errorf("for %s expected error %q, but got %q", e.name, e.expectedError, errorMessage)
+		}
+	}
+}
+
+// AdminStayRules
+func TestRepository_AdminStayRules(t *testing.T) {
+
+	// case #1: OK
+	// -- create request
+	req := httptest.NewRequest("GET", "/admin/stay-rules", nil)
+	// -- get ctx
+	ctx := getCtx(req)
+	req = req.WithContext(ctx)
+	// -- create response recorder
+	rr := httptest.NewRecorder()
+	// -- make request
+	http.HandlerFunc(Repo.AdminStayRules).ServeHTTP(rr, req)
+	// -- check response
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "The Family Cottage") || !strings.Contains(rr.Body.String(), "Saturday") {
+		t.Errorf("Expected status code %d listing the stay rules, but got status code %d", http.StatusOK, rr.Code)
+	}
+}
+
+// AdminPostStayRule
+func TestRepository_AdminPostStayRule(t *testing.T) {
+
+	var ruleTests = []struct {
+		name               string
+		bungalowID         string
+		endDate            string
+		maxNights          string
+		weekdays           []string
+		expectedStatusCode int
+		expectedError      string
+	}{
+		{"ok", "3", "2036-08-31", "14", []string{"6", "0"}, http.StatusSeeOther, ""},
+		{"all-bungalows", "0", "", "0", nil, http.StatusSeeOther, ""},
+		{"unknown-weekday", "3", "2036-08-31", "14", []string{"7"}, http.StatusOK, "Choose the arrival days"},
+		{"end-before-start", "3", "2036-06-30", "14", nil, http.StatusOK, "The last day cannot be before"},
+		{"max-below-min", "3", "2036-08-31", "3", nil, http.StatusOK, "The maximum stay cannot be shorter"},
+		{"invalid-nights", "3", "2036-08-31", "many", nil, http.StatusOK, "Requires a whole number."},
+		{"insert-fails", "5", "2036-08-31", "14", nil, http.StatusInternalServerError, ""},
+	}
+
+	for _, e := range ruleTests {
+		postData := url.Values{}
+		postData.Add("bungalow_id", e.bungalowID)
+		postData.Add("start_date", "2036-07-01")
+		postData.Add("end_date", e.endDate)
+		postData.Add("min_nights", "7")
+		postData.Add("max_nights", e.maxNights)
+		postData.Add("min_lead_days", "0")
+		postData.Add("max_horizon_days", "0")
+		for _, d := range e.weekdays {
+			postData.Add("arrival_weekdays", d)
+		}
+		// -- create request
+		req := httptest.NewRequest("POST", "/admin/stay-rules", strings.NewReader(postData.Encode()))
+		// -- get ctx
+		ctx := getCtx(req)
+		req = req.WithContext(ctx)
+		// -- set headers
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		// -- create response recorder
+		rr := httptest.NewRecorder()
+		// -- make request
+		http.HandlerFunc(Repo.AdminPostStayRule).ServeHTTP(rr, req)
+		// -- check response
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got status code %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if e.expectedError != "" && !strings.Contains(rr.Body.String(), e.expectedError) {
+			t.Errorf("for %s expected error %q to be shown, but it was not", e.name, e.expectedError)
+		}
+	}
+}
+
+// AdminShowStayRule
+func TestRepository_AdminShowStayRule(t *testing.T) {
+
+	var showTests = []struct {
+		name               string
+		id                 string
+		expectedStatusCode int
+	}{
+		{"ok", "2", http.StatusOK},
+		{"not-found", "99", http.StatusInternalServerError},
+	}
+
+	for _, e := range showTests {
+		// -- create request
+		req := httptest.NewRequest("GET", "/admin/stay-rules/"+e.id+"/show", nil)
+		// -- get ctx with the id url parameter
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		// -- create response recorder
+		rr := httptest.NewRecorder()
+		// -- make request
+		http.HandlerFunc(Repo.AdminShowStayRule).ServeHTTP(rr, req)
+		// -- check response
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got status code %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+		if e.name == "ok" && !strings.Contains(rr.Body.String(), `value="6" checked`) {
+			t.Errorf("for %s expected saturday to be checked as arrival day, but it was not", e.name)
+		}
+	}
+}
+
+// AdminPostShowStayRule
+func TestRepository_AdminPostShowStayRule(t *testing.T) {
+
+	var updateTests = []struct {
+		name               string
+		id                 string
+		bungalowID         string
+		minNights          string
+		expectedStatusCode int
+	}{
+		{"ok", "2", "3", "7", http.StatusSeeOther},
+		{"negative-nights", "2", "3", "-1", http.StatusOK},
+		{"update-fails", "2", "5", "7", http.StatusInternalServerError},
+		{"not-found", "99", "3", "7", http.StatusInternalServerError},
+	}
+
+	for _, e := range updateTests {
+		postData := url.Values{}
+		postData.Add("bungalow_id", e.bungalowID)
+		postData.Add("min_nights", e.minNights)
+		postData.Add("max_nights", "0")
+		postData.Add("min_lead_days", "0")
+		postData.Add("max_horizon_days", "0")
+		postData.Add("arrival_weekdays", "6")
+		// -- create request
+		req := httptest.NewRequest("POST", "/admin/stay-rules/"+e.id, strings.NewReader(postData.Encode()))
+		// -- get ctx with the id url parameter
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		// -- set headers
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		// -- create response recorder
+		rr := httptest.NewRecorder()
+		// -- make request
+		http.HandlerFunc(Repo.AdminPostShowStayRule).ServeHTTP(rr, req)
+		// -- check response
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got status code %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+	}
+}
+
+// AdminDeleteStayRule
+func TestRepository_AdminDeleteStayRule(t *testing.T) {
+
+	var deleteTests = []struct {
+		name               string
+		id                 string
+		expectedStatusCode int
+	}{
+		{"ok", "2", http.StatusSeeOther},
+		{"delete-fails", "99", http.StatusInternalServerError},
+	}
+
+	for _, e := range deleteTests {
+		// -- create request
+		req := httptest.NewRequest("GET", "/admin/delete-stay-rule/"+e.id+"/do", nil)
+		// -- get ctx with the id url parameter
+		ctx := getCtx(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", e.id)
+		req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+		// -- create response recorder
+		rr := httptest.NewRecorder()
+		// -- make request
+		http.HandlerFunc(Repo.AdminDeleteStayRule).ServeHTTP(rr, req)
+		// -- check response
+		if rr.Code != e.expectedStatusCode {
+			t.Errorf("for %s expected status code %d, but got status code %d", e.name, e.expectedStatusCode, rr.Code)
+		}
+	}
+}
+
+func TestRepository_actor(t *testing.T) {
+	defer func(trusted []*net.IPNet) {
+		app.TrustedProxies = trusted
+	}(app.TrustedProxies)
+	app.TrustedProxies, _ = ratelimit.ParseNetworks([]string{"10.0.0.1/32"})
+
+	actorTests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expectedIP string
+	}{
+		{"direct", "192.0.2.1:1234", "", "192.0.2.1"},
+		{"spoofed", "192.0.2.1:1234", "198.51.100.7", "192.0.2.1"},
+		{"proxied", "10.0.0.1:1234", "198.51.100.7", "198.51.100.7"},
+	}
+
+	for _, e := range actorTests {
+		req := httptest.NewRequest("GET", "/admin/dashboard", nil)
+		req.RemoteAddr = e.remoteAddr
+		if e.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", e.forwarded)
+		}
+		req = req.WithContext(getCtx(req))
+
+		if actor := Repo.actor(req); actor.IP != e.expectedIP {
+			t.Errorf("for %s expected ip %s, but got %s", e.name, e.expectedIP, actor.IP)
+		}
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -13,6 +14,8 @@ import (
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/amartin3659/VacationHomeRental/internal/config"
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
+	"github.com/amartin3659/VacationHomeRental/internal/metrics"
 	"github.com/amartin3659/VacationHomeRental/internal/helpers"
 	"github.com/amartin3659/VacationHomeRental/internal/models"
 	"github.com/amartin3659/VacationHomeRental/internal/render"
@@ -63,12 +66,11 @@ func TestMain(m *testing.M) {
   gob.Register(models.Reservation{})
 
 	app.InProduction = false
+	app.HoldDuration = 15 * time.Minute
+	app.ReservationRetention = 30 * 24 * time.Hour
 
-  infoLog := log.New(os.Stdout, "[INFO]\t", log.Ldate|log.Ltime)
-  app.InfoLog = infoLog
-  
-  errorLog := log.New(os.Stdout, "[ERROR]\t", log.Ldate|log.Ltime|log.Lshortfile)
-  app.ErrorLog = errorLog
+  app.Logger = logging.New(os.Stdout, slog.LevelInfo, false)
+  app.Metrics = metrics.New()
 
 	session = scs.New()
 	session.Lifetime = 24 * time.Hour
@@ -121,6 +123,7 @@ func getRoutes() http.Handler {
   mux.Get("/", Repo.Home)
   mux.Get("/about", Repo.About)
   mux.Get("/contact", Repo.Contact)
+  mux.Post("/contact", Repo.PostContact)
   mux.Get("/eremite", Repo.Eremite)
   mux.Get("/couple", Repo.Couple)
   mux.Get("/family", Repo.Family)
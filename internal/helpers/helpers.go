@@ -1,7 +1,9 @@
 package helpers
 
 import (
-	"fmt"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"net/http"
 	"runtime/debug"
 
@@ -15,14 +17,13 @@ func NewHelpers(a *config.AppConfig) {
   app = a
 }
 
-func ClientError(w http.ResponseWriter, status int) {
-  app.InfoLog.Println("Client error! Status:", status)
+func ClientError(w http.ResponseWriter, r *http.Request, status int) {
+  app.Logger.InfoContext(r.Context(), "client error", "status", status)
   http.Error(w, http.StatusText(status), status)
 }
 
-func ServerError(w http.ResponseWriter, err error) {
-  trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-  app.ErrorLog.Println(trace)
+func ServerError(w http.ResponseWriter, r *http.Request, err error) {
+  app.Logger.ErrorContext(r.Context(), "server error", "error", err, "stack", string(debug.Stack()))
   http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
@@ -31,3 +32,28 @@ func IsAuthenticated(r *http.Request) bool {
   exists := app.Session.Exists(r.Context(), "user_id")
   return exists
 }
+
+type contextKey string
+
+const nonceKey contextKey = "csp_nonce"
+
+// NewNonce returns a random nonce for the Content-Security-Policy of a response
+func NewNonce() (string, error) {
+  b := make([]byte, 16)
+  _, err := rand.Read(b)
+  if err != nil {
+    return "", err
+  }
+  return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// WithNonce returns a copy of the request carrying the nonce of its Content-Security-Policy
+func WithNonce(r *http.Request, nonce string) *http.Request {
+  return r.WithContext(context.WithValue(r.Context(), nonceKey, nonce))
+}
+
+// Nonce returns the nonce of the Content-Security-Policy of a request, templates mark their inline scripts with it
+func Nonce(r *http.Request) string {
+  nonce, _ := r.Context().Value(nonceKey).(string)
+  return nonce
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo, true)
+
+	logger.InfoContext(WithRequestID(context.Background(), "abc"), "booked", "bungalow", 1)
+	logger.Debug("not logged")
+
+	var record map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &record)
+	if err != nil {
+		t.Fatalf("expected a single json record, but got %q", buf.String())
+	}
+	if record["msg"] != "booked" || record["request_id"] != "abc" || record["bungalow"] != float64(1) {
+		t.Errorf("expected the record to carry the message, request id and attributes, but got %v", record)
+	}
+
+	buf.Reset()
+	logger.With("job", "sweeper").Info("released")
+	if strings.Contains(buf.String(), "request_id") || !strings.Contains(buf.String(), `"job":"sweeper"`) {
+		t.Errorf("expected a record without request id, but got %q", buf.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	var levelTests = []struct {
+		name     string
+		expected slog.Level
+		ok       bool
+	}{
+		{"", slog.LevelInfo, true},
+		{"debug", slog.LevelDebug, true},
+		{"WARN", slog.LevelWarn, true},
+		{"error", slog.LevelError, true},
+		{"loud", slog.LevelInfo, false},
+	}
+
+	for _, e := range levelTests {
+		level, err := ParseLevel(e.name)
+		if (err == nil) != e.ok || (e.ok && level != e.expected) {
+			t.Errorf("for %q expected level %s and ok %v, but got %s and %v", e.name, e.expected, e.ok, level, err)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	if id := RequestID(context.Background()); id != "" {
+		t.Errorf("expected no request id outside of a request, but got %q", id)
+	}
+
+	id := NewRequestID()
+	if !ValidRequestID(id) || id == NewRequestID() {
+		t.Errorf("expected a valid random request id, but got %q", id)
+	}
+
+	for _, id := range []string{"", "a b", strings.Repeat("a", 65), "<script>"} {
+		if ValidRequestID(id) {
+			t.Errorf("expected %q not to be a valid request id", id)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"database/sql"
+)
+
+// the outcomes of sending a mail
+const (
+	MailSent   = "sent"
+	MailFailed = "failed"
+)
+
+// Metrics are the metrics of the application
+type Metrics struct {
+	*Registry
+
+	// HTTPRequestDuration is labelled with the route pattern instead of the path, so ids do not create new series
+	HTTPRequestDuration *Histogram
+	MailsSent           *Counter
+	// ReservationsCreated is labelled with the source of the reservation, a booking, the admin or an import
+	ReservationsCreated *Counter
+	// AvailabilitySearches and EmptySearches are labelled with the scope of the search,
+	// all bungalows or a single one
+	AvailabilitySearches *Counter
+	EmptySearches        *Counter
+}
+
+// New returns the metrics of the application in a new registry
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		Registry: r,
+		HTTPRequestDuration: r.NewHistogram("http_request_duration_seconds",
+			"Duration of HTTP requests by route pattern.", DefaultBuckets, "method", "route", "status"),
+		MailsSent: r.NewCounter("mail_sent_total",
+			"Mails handed to the mail server by outcome.", "outcome"),
+		ReservationsCreated: r.NewCounter("reservations_created_total",
+			"Reservations created by source.", "source"),
+		AvailabilitySearches: r.NewCounter("availability_searches_total",
+			"Availability searches by scope.", "scope"),
+		EmptySearches: r.NewCounter("availability_searches_empty_total",
+			"Availability searches without an available bungalow by scope.", "scope"),
+	}
+}
+
+// CollectMailQueue registers the number of mails waiting to be sent, as returned by length
+func (m *Metrics) CollectMailQueue(length func() int) {
+	m.NewGaugeFunc("mail_queue_length", "Mails waiting to be sent.", func() float64 {
+		return float64(length())
+	})
+}
+
+// CollectDBStats registers the statistics of the connection pool of db
+func (m *Metrics) CollectDBStats(db *sql.DB) {
+	gauges := []struct {
+		name  string
+		help  string
+		value func(sql.DBStats) float64
+	}{
+		{"db_max_open_connections", "Maximum number of open connections to the database.",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"db_open_connections", "Established connections to the database, in use and idle.",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"db_in_use_connections", "Connections to the database currently in use.",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"db_idle_connections", "Idle connections to the database.",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	}
+	for _, g := range gauges {
+		value := g.value
+		m.NewGaugeFunc(g.name, g.help, func() float64 { return value(db.Stats()) })
+	}
+
+	counters := []struct {
+		name  string
+		help  string
+		value func(sql.DBStats) float64
+	}{
+		{"db_wait_count_total", "Connections waited for because the pool was exhausted.",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"db_wait_duration_seconds_total", "Time spent waiting for a connection.",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"db_max_idle_closed_total", "Connections closed because of the maximum of idle connections.",
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"db_max_idle_time_closed_total", "Connections closed because they were idle too long.",
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"db_max_lifetime_closed_total", "Connections closed because of their maximum lifetime.",
+			func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+	for _, c := range counters {
+		value := c.value
+		m.NewCounterFunc(c.name, c.help, func() float64 { return value(db.Stats()) })
+	}
+}
@@ -0,0 +1,318 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// contentType is the content type of the prometheus text format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds in seconds of the buckets of a latency histogram
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is a metric family which writes its samples in the prometheus text format
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry holds the metrics exposed to prometheus
+type Registry struct {
+	mu      sync.Mutex
+	names   map[string]bool
+	metrics []metric
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register adds a metric, a name can only be registered once
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic("metrics: " + name + " is registered twice")
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all metrics in the prometheus text format in the order they were registered
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		m.write(&buf)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ServeHTTP serves the metrics to prometheus
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	r.Write(w)
+}
+
+// vec holds the series of a metric family by their label values
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	keys   []string
+	values map[string][]string
+}
+
+func newVec(name, help, kind string, labels []string) vec {
+	return vec{name: name, help: help, kind: kind, labels: labels, values: make(map[string][]string)}
+}
+
+// key returns the key of the series with the label values, adding it if it is new; the caller holds the lock
+func (v *vec) key(values []string) string {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	k := strings.Join(values, "\xff")
+	if _, ok := v.values[k]; !ok {
+		v.values[k] = append([]string(nil), values...)
+		v.keys = append(v.keys, k)
+		sort.Strings(v.keys)
+	}
+	return k
+}
+
+func (v *vec) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.kind)
+}
+
+// labelString returns the label set of a series, with an extra label like le appended if it is given
+func (v *vec) labelString(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var pairs []string
+	for i, label := range v.labels {
+		pairs = append(pairs, label+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a value which only goes up, partitioned by its labels
+type Counter struct {
+	vec
+	counts map[string]float64
+}
+
+// NewCounter registers a counter with the label names
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, "counter", labels), counts: make(map[string]float64)}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the series with the label values
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the series with the label values
+func (c *Counter) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[c.key(values)] += delta
+}
+
+// Value returns the value of the series with the label values
+func (c *Counter) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[strings.Join(values, "\xff")]
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w)
+	if len(c.labels) == 0 && len(c.keys) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+	for _, k := range c.keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelString(c.values[k]), formatFloat(c.counts[k]))
+	}
+}
+
+// Gauge is a value which goes up and down, partitioned by its labels
+type Gauge struct {
+	vec
+	gauges map[string]float64
+}
+
+// NewGauge registers a gauge with the label names
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labels), gauges: make(map[string]float64)}
+	r.register(name, g)
+	return g
+}
+
+// Set sets the series with the label values to value
+func (g *Gauge) Set(value float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gauges[g.key(values)] = value
+}
+
+// Add adds delta to the series with the label values
+func (g *Gauge) Add(delta float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gauges[g.key(values)] += delta
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.header(w)
+	if len(g.labels) == 0 && len(g.keys) == 0 {
+		fmt.Fprintf(w, "%s 0\n", g.name)
+	}
+	for _, k := range g.keys {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelString(g.values[k]), formatFloat(g.gauges[k]))
+	}
+}
+
+// funcMetric is a counter or gauge without labels whose value is read when it is scraped
+type funcMetric struct {
+	vec
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is returned by fn
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{vec: newVec(name, help, "gauge", nil), fn: fn})
+}
+
+// NewCounterFunc registers a counter whose value is returned by fn, e.g. a total kept by another package
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{vec: newVec(name, help, "counter", nil), fn: fn})
+}
+
+func (f *funcMetric) write(w io.Writer) {
+	f.header(w)
+	fmt.Fprintf(w, "%s %s\n", f.name, formatFloat(f.fn()))
+}
+
+// Histogram counts observations like request durations in buckets, partitioned by its labels
+type Histogram struct {
+	vec
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the upper bounds of its buckets and the label names
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		vec:     newVec(name, help, "histogram", labels),
+		buckets: append([]float64(nil), buckets...),
+		series:  make(map[string]*histogramSeries),
+	}
+	sort.Float64s(h.buckets)
+	r.register(name, h)
+	return h
+}
+
+// Observe adds an observation to the series with the label values
+func (h *Histogram) Observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := h.key(values)
+	s, ok := h.series[k]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[k] = s
+	}
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// Count returns the number of observations of the series with the label values
+func (h *Histogram) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[strings.Join(values, "\xff")]
+	if !ok {
+		return 0
+	}
+	return s.count
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	for _, k := range h.keys {
+		values, s := h.values[k], h.series[k]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(values, "le", formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(values), s.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("mail_sent_total", "Mails sent.", "outcome")
+	g := r.NewGauge("queue_length", "Queue length.")
+	h := r.NewHistogram("request_seconds", "Request duration.", []float64{0.5, 0.1}, "route")
+	r.NewGaugeFunc("open_connections", "Open connections.", func() float64 { return 3 })
+
+	c.Inc("sent")
+	c.Add(2, "failed")
+	c.Inc(`say "hi"`)
+	g.Set(4)
+	g.Add(-1)
+	h.Observe(0.05, "/")
+	h.Observe(0.2, "/")
+	h.Observe(2, "/")
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("expected the prometheus text format, but got %q", rr.Header().Get("Content-Type"))
+	}
+
+	expected := `# HELP mail_sent_total Mails sent.
+# TYPE mail_sent_total counter
+mail_sent_total{outcome="failed"} 2
+mail_sent_total{outcome="say \"hi\""} 1
+mail_sent_total{outcome="sent"} 1
+# HELP queue_length Queue length.
+# TYPE queue_length gauge
+queue_length 3
+# HELP request_seconds Request duration.
+# TYPE request_seconds histogram
+request_seconds_bucket{route="/",le="0.1"} 1
+request_seconds_bucket{route="/",le="0.5"} 2
+request_seconds_bucket{route="/",le="+Inf"} 3
+request_seconds_sum{route="/"} 2.25
+request_seconds_count{route="/"} 3
+# HELP open_connections Open connections.
+# TYPE open_connections gauge
+open_connections 3
+`
+	if rr.Body.String() != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, rr.Body.String())
+	}
+
+	if c.Value("failed") != 2 || h.Count("/") != 3 || h.Count("/other") != 0 {
+		t.Error("expected the values to be read back, but they were not")
+	}
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	var panicTests = []struct {
+		name string
+		fn   func(r *Registry)
+	}{
+		{"duplicate", func(r *Registry) { r.NewGauge("a", ""); r.NewCounter("a", "") }},
+		{"label-values", func(r *Registry) { r.NewCounter("a", "", "x").Inc() }},
+		{"decrease", func(r *Registry) { r.NewCounter("a", "").Add(-1) }},
+	}
+
+	for _, e := range panicTests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("for %s expected a panic, but got none", e.name)
+				}
+			}()
+			e.fn(NewRegistry())
+		}()
+	}
+}
+
+func TestNew(t *testing.T) {
+	m := New()
+	m.CollectMailQueue(func() int { return 7 })
+	m.ReservationsCreated.Inc("booking")
+
+	var b strings.Builder
+	m.Write(&b)
+	for _, line := range []string{
+		`reservations_created_total{source="booking"} 1`,
+		"mail_queue_length 7",
+		"# TYPE availability_searches_empty_total counter",
+		"# TYPE http_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("expected the metrics to contain %q, but got\n%s", line, b.String())
+		}
+	}
+}
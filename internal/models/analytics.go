@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Occupancy is the number of nights a bungalow is booked in a month
+type Occupancy struct {
+	Bungalow     Bungalow
+	Month        time.Time
+	NightsBooked int
+}
+
+// Nights returns the number of nights in the month of the occupancy
+func (o Occupancy) Nights() int {
+	return o.Month.AddDate(0, 1, -o.Month.Day()).Day()
+}
+
+// Rate returns the occupancy rate of the month in percent
+func (o Occupancy) Rate() float64 {
+	return float64(o.NightsBooked) * 100 / float64(o.Nights())
+}
+
+// ReservationStats sums up the reservations arriving in a period, AverageStay is in nights
+// and AverageLeadTime is the average number of days between booking and arrival, New, Processed and Confirmed
+// count the reservations by processing status
+type ReservationStats struct {
+	Reservations    int
+	NightsBooked    int
+	AverageStay     float64
+	AverageLeadTime float64
+	New             int
+	Processed       int
+	Confirmed       int
+}
+
+// SpamRejections is the number of submissions of a public form rejected for a reason
+type SpamRejections struct {
+	Form   string
+	Reason string
+	Count  int
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// the actions recorded in the audit log
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// the entities recorded in the audit log
+const (
+	AuditReservation = "reservation"
+	AuditNote        = "note"
+	AuditMessage     = "message"
+	AuditBlock       = "block"
+	AuditRestriction = "restriction"
+	AuditStayRule    = "stay_rule"
+	AuditInquiry     = "inquiry"
+)
+
+// Actor is the user making a change and the address the request came from, recorded in the audit log
+type Actor struct {
+	UserID int
+	IP     string
+}
+
+// AuditEvent is a change recorded in the audit log, Before and After are json snapshots of the entity
+// and empty if it did not exist before or after the change
+type AuditEvent struct {
+	ID        int
+	UserID    int
+	User      User
+	Action    string
+	Entity    string
+	EntityID  int
+	Before    string
+	After     string
+	IP        string
+	CreatedAt time.Time
+}
+
+// AuditChange is a field of an entity changed by an audit event
+type AuditChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// Changes returns the fields which differ between the snapshots of an event, ordered by name
+func (e AuditEvent) Changes() []AuditChange {
+	before := make(map[string]interface{})
+	after := make(map[string]interface{})
+	if e.Before != "" {
+		_ = json.Unmarshal([]byte(e.Before), &before)
+	}
+	if e.After != "" {
+		_ = json.Unmarshal([]byte(e.After), &after)
+	}
+
+	fields := make(map[string]bool)
+	for field := range before {
+		fields[field] = true
+	}
+	for field := range after {
+		fields[field] = true
+	}
+
+	var changes []AuditChange
+	for field := range fields {
+		b, a := auditValue(before, field), auditValue(after, field)
+		if b != a {
+			changes = append(changes, AuditChange{Field: field, Before: b, After: a})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes
+}
+
+// auditValue returns a field of a snapshot as text
+func auditValue(snapshot map[string]interface{}, field string) string {
+	v, ok := snapshot[field]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// AuditQuery selects a page of audit events, zero values select everything.
+// ReservationID selects the events on a reservation together with those on its notes and messages
+type AuditQuery struct {
+	UserID        int
+	Action        string
+	Entity        string
+	EntityID      int
+	ReservationID int
+	From          time.Time
+	To            time.Time
+	Page          int
+	PerPage       int
+}
+
+// Offset returns the number of events before the page
+func (q AuditQuery) Offset() int {
+	return (q.Page - 1) * q.PerPage
+}
+
+// AuditPage is a page of audit events selected by a query, Total counts the events on all pages
+type AuditPage struct {
+	Events []AuditEvent
+	Total  int
+	Query  AuditQuery
+}
+
+// Pages returns the number of pages
+func (p AuditPage) Pages() int {
+	if p.Query.PerPage < 1 {
+		return 1
+	}
+	return (p.Total + p.Query.PerPage - 1) / p.Query.PerPage
+}
+
+// HasPrevious returns true if there is a page before this one
+func (p AuditPage) HasPrevious() bool {
+	return p.Query.Page > 1
+}
+
+// HasNext returns true if there is a page after this one
+func (p AuditPage) HasNext() bool {
+	return p.Query.Page < p.Pages()
+}
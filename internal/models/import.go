@@ -0,0 +1,14 @@
+package models
+
+// ImportRow is a reservation read from a row of an imported csv file, Line is the line of the row in the file
+// and Errors are the reasons the reservation can't be imported
+type ImportRow struct {
+	Line        int
+	Reservation Reservation
+	Errors      []string
+}
+
+// Valid returns true if the reservation of the row can be imported
+func (r ImportRow) Valid() bool {
+	return len(r.Errors) == 0
+}
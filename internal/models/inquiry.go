@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Inquiry is a message sent with the contact form, the bungalow and the dates are optional
+// and ReservationID is set once the inquiry has been turned into a reservation
+type Inquiry struct {
+	ID            int
+	FullName      string `form:"full_name" validate:"required,min=2,max=255"`
+	Email         string `form:"email" validate:"required,email,max=255"`
+	Subject       string `form:"subject" validate:"required,max=255"`
+	Message       string `form:"message" validate:"required,max=5000"`
+	BungalowID    int
+	Bungalow      Bungalow
+	StartDate     time.Time
+	EndDate       time.Time
+	AnsweredAt    time.Time
+	ReservationID int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Answered returns true if staff have dealt with the inquiry
+func (i Inquiry) Answered() bool {
+	return !i.AnsweredAt.IsZero()
+}
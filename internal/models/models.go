@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // User is the model of user data
 type User struct {
@@ -13,28 +18,51 @@ type User struct {
 	UpdatedAt time.Time
 }
 
-// Bungalow is the model of a bugalow
+// Bungalow is the model of a bugalow, MaxOccupancy 0 means no limit
 type Bungalow struct {
 	ID           int
 	BungalowName string
+	MaxOccupancy int
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
 
-// Restriction is the model of a restriction
+// The restriction types seeded in the restrictions table, custom types get higher ids
+const (
+	RestrictionReservation     = 1
+	RestrictionOwnerStay       = 2
+	RestrictionHold            = 3
+	RestrictionMaintenance     = 4
+	RestrictionSeasonalClosure = 5
+)
+
+// Restriction is the model of a restriction type, the tags bind and validate the types posted in forms
 type Restriction struct {
-	ID              int
-	RestrictionName string
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
+	ID                 int
+	RestrictionName    string `form:"restriction_name" validate:"required,max=255"`
+	Color              string `form:"color" validate:"required,regex=^#[0-9a-fA-F]{6}$"`
+	BlocksAvailability bool   `form:"blocks_availability"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// IsBuiltIn returns true for the seeded restriction types, which cannot be deleted
+func (r Restriction) IsBuiltIn() bool {
+	return r.ID >= RestrictionReservation && r.ID <= RestrictionSeasonalClosure
+}
+
+// IsBlock returns true if owner blocks can be of this restriction type
+func (r Restriction) IsBlock() bool {
+	return r.ID != RestrictionReservation && r.ID != RestrictionHold
 }
 
-// Reservation is the model of a reservation
+// Reservation is the model of a reservation, the tags bind and validate the guest data posted in forms
 type Reservation struct {
-	ID         int
-	FullName   string
-	Email      string
-	Phone      string
+	ID       int
+	FullName string `form:"full_name" validate:"required,min=2,max=255"`
+	Email    string `form:"email" validate:"required,email,max=255"`
+	Phone    string `form:"phone" validate:"phone"`
+	Party
 	StartDate  time.Time
 	EndDate    time.Time
 	BungalowID int
@@ -42,16 +70,58 @@ type Reservation struct {
 	UpdatedAt  time.Time
 	Bungalow   Bungalow
 	Status     int
+	DeletedAt  time.Time
 }
 
-// BungalowRestriction is the model of a bungalowrestriction
+// The processing statuses of a reservation, reservations made by guests start out new
+const (
+	ReservationNew       = 0
+	ReservationProcessed = 1
+	ReservationConfirmed = 2
+)
+
+// Deleted returns true if the reservation has been moved to the trash
+func (r Reservation) Deleted() bool {
+	return !r.DeletedAt.IsZero()
+}
+
+// StatusName returns the name of the processing status of a reservation
+func (r Reservation) StatusName() string {
+	switch r.Status {
+	case ReservationNew:
+		return "New"
+	case ReservationProcessed:
+		return "Processed"
+	case ReservationConfirmed:
+		return "Confirmed"
+	default:
+		return fmt.Sprintf("Status %d", r.Status)
+	}
+}
+
+// Party is the model of the guests staying in a bungalow
+type Party struct {
+	Adults   int `form:"adults" validate:"required,range=1:20"`
+	Children int `form:"children" validate:"range=0:20"`
+	Infants  int `form:"infants" validate:"range=0:10"`
+}
+
+// Guests returns the number of guests counting towards the occupancy of a bungalow, infants are not counted
+func (p Party) Guests() int {
+	return p.Adults + p.Children
+}
+
+// BungalowRestriction is the model of a bungalowrestriction, ExpiresAt is only set for holds,
+// the tags bind and validate the owner blocks posted in forms
 type BungalowRestriction struct {
 	ID            int
-	StartDate     time.Time
-	EndDate       time.Time
-	BungalowID    int
+	StartDate     time.Time `form:"start_date" validate:"required,date"`
+	EndDate       time.Time `form:"end_date" validate:"required,date"`
+	BungalowID    int       `form:"bungalow_id" validate:"required"`
 	ReservationID int
-	RestrictionID int
+	RestrictionID int    `form:"restriction_id" validate:"required"`
+	Note          string `form:"note" validate:"max=255"`
+	ExpiresAt     time.Time
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	Bungalow      Bungalow
@@ -59,6 +129,101 @@ type BungalowRestriction struct {
 	Restriction   Restriction
 }
 
+// IsReservation returns true if the restriction belongs to a reservation
+func (r BungalowRestriction) IsReservation() bool {
+	return r.RestrictionID == RestrictionReservation
+}
+
+// StayRule is the model of a stay rule, BungalowID 0 applies to all bungalows
+// and zero StartDate/EndDate apply all year round. A stay has to keep every rule applying to it,
+// global and bungalow rules alike, so the strictest setting wins and a bungalow rule cannot loosen a global one
+type StayRule struct {
+	ID              int
+	BungalowID      int `form:"bungalow_id"`
+	Bungalow        Bungalow
+	StartDate       time.Time `form:"start_date"`
+	EndDate         time.Time `form:"end_date"`
+	MinNights       int       `form:"min_nights" validate:"range=0:365"`
+	MaxNights       int       `form:"max_nights" validate:"range=0:365"`
+	ArrivalWeekdays []time.Weekday
+	MinLeadDays     int `form:"min_lead_days" validate:"range=0:3650"`
+	MaxHorizonDays  int `form:"max_horizon_days" validate:"range=0:3650"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// ParseWeekdays parses a comma separated list of weekday numbers, 0 is sunday, as stored in arrival_weekdays
+func ParseWeekdays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, d := range strings.Split(s, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		day, err := strconv.Atoi(d)
+		if err != nil || day < int(time.Sunday) || day > int(time.Saturday) {
+			return nil, fmt.Errorf("invalid arrival weekday %q", d)
+		}
+		days = append(days, time.Weekday(day))
+	}
+	return days, nil
+}
+
+// FormatWeekdays returns weekdays as a comma separated list of numbers, the reverse of ParseWeekdays
+func FormatWeekdays(days []time.Weekday) string {
+	numbers := make([]string, len(days))
+	for i, d := range days {
+		numbers[i] = strconv.Itoa(int(d))
+	}
+	return strings.Join(numbers, ",")
+}
+
+// AllowsArrivalOn returns true if the rule lets guests arrive on a weekday
+func (s StayRule) AllowsArrivalOn(day time.Weekday) bool {
+	if len(s.ArrivalWeekdays) == 0 {
+		return true
+	}
+	for _, d := range s.ArrivalWeekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a guest-facing message if a stay from start to end booked on today
+// breaks the rule, otherwise an empty string
+func (s StayRule) Check(start, end, today time.Time) string {
+	nights := int(end.Sub(start).Hours() / 24)
+	leadDays := int(start.Sub(today).Hours() / 24)
+
+	if s.MinLeadDays > 0 && leadDays < s.MinLeadDays {
+		return fmt.Sprintf("Bookings must be made at least %d days before arrival.", s.MinLeadDays)
+	}
+
+	if s.MaxHorizonDays > 0 && leadDays > s.MaxHorizonDays {
+		return fmt.Sprintf("Bookings can be made at most %d days in advance.", s.MaxHorizonDays)
+	}
+
+	if s.MinNights > 0 && nights < s.MinNights {
+		return fmt.Sprintf("The minimum stay for these dates is %d nights.", s.MinNights)
+	}
+
+	if s.MaxNights > 0 && nights > s.MaxNights {
+		return fmt.Sprintf("The maximum stay for these dates is %d nights.", s.MaxNights)
+	}
+
+	if !s.AllowsArrivalOn(start.Weekday()) {
+		var days []string
+		for _, d := range s.ArrivalWeekdays {
+			days = append(days, d.String())
+		}
+		return fmt.Sprintf("Arrival for these dates is only possible on %s.", strings.Join(days, " or "))
+	}
+
+	return ""
+}
+
 // MailData is a model of an email message
 type MailData struct {
 	To       string
@@ -66,4 +231,8 @@ type MailData struct {
 	Subject  string
 	Content  string
 	Template string
+	// RequestID is the id of the request sending the mail, for the logs of the mail listener
+	RequestID string
+	// MessageID is the id of the reservation message whose delivery status the mail listener updates, 0 if there is none
+	MessageID int
 }
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReservationNote is an internal note of staff on a reservation, never shown to the guest
+type ReservationNote struct {
+	ID            int
+	ReservationID int
+	UserID        int
+	User          User
+	Body          string `form:"body" validate:"required,max=2000"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// ReservationMessage is an email sent to the guest of a reservation, Body is the plain text of the message
+type ReservationMessage struct {
+	ID            int
+	ReservationID int
+	UserID        int
+	User          User
+	To            string
+	Subject       string `form:"subject" validate:"required,max=255"`
+	Body          string `form:"body" validate:"required,max=10000"`
+	Status        int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// The delivery statuses of a message, messages are queued until the mail listener hands them to the mail server
+const (
+	MessageQueued = 0
+	MessageSent   = 1
+	MessageFailed = 2
+)
+
+// StatusName returns the name of the delivery status of a message
+func (m ReservationMessage) StatusName() string {
+	switch m.Status {
+	case MessageQueued:
+		return "Queued"
+	case MessageSent:
+		return "Sent"
+	case MessageFailed:
+		return "Failed"
+	default:
+		return fmt.Sprintf("Status %d", m.Status)
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// ReservationStatusAny selects reservations of any status in a ReservationQuery
+const ReservationStatusAny = -1
+
+// ReservationSortColumns are the columns a reservation list can be sorted by
+var ReservationSortColumns = []string{"id", "full_name", "email", "phone", "bungalow", "start_date", "end_date", "created_at", "status", "deleted_at"}
+
+// ReservationQuery selects a page of reservations, Search matches any part of the name, email or phone regardless of case,
+// From and To limit the arrival date and zero values select everything except for Status which uses ReservationStatusAny,
+// Deleted selects the reservations in the trash instead of the others
+type ReservationQuery struct {
+	Search     string
+	BungalowID int
+	Status     int
+	From       time.Time
+	To         time.Time
+	Deleted    bool
+	Sort       string
+	Desc       bool
+	Page       int
+	PerPage    int
+}
+
+// Offset returns the number of reservations before the page
+func (q ReservationQuery) Offset() int {
+	return (q.Page - 1) * q.PerPage
+}
+
+// ReservationPage is a page of reservations selected by a query, Total counts the reservations on all pages
+type ReservationPage struct {
+	Reservations []Reservation
+	Total        int
+	Query        ReservationQuery
+}
+
+// Pages returns the number of pages
+func (p ReservationPage) Pages() int {
+	if p.Query.PerPage < 1 {
+		return 1
+	}
+	return (p.Total + p.Query.PerPage - 1) / p.Query.PerPage
+}
+
+// HasPrevious returns true if there is a page before this one
+func (p ReservationPage) HasPrevious() bool {
+	return p.Query.Page > 1
+}
+
+// HasNext returns true if there is a page after this one
+func (p ReservationPage) HasNext() bool {
+	return p.Query.Page < p.Pages()
+}
@@ -9,9 +9,12 @@ type TemplateData struct {
 	FloatMap        map[float64]float64
 	Data            map[string]interface{}
 	CSRFToken       string
+	CSPNonce        string
 	Success         string
 	Warning         string
 	Error           string
 	Form            *forms.Form
 	IsAuthenticated int
+	SpamToken       string
+	SpamChallenge   map[string]string
 }
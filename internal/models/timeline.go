@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// TimelineRow is the row of a bungalow in the admin timeline
+type TimelineRow struct {
+	Bungalow Bungalow
+	Days     []TimelineDay
+	Bars     []TimelineBar
+}
+
+// TimelineDay is a day of a bungalow in the admin timeline, BlockID is the id of the
+// restriction blocking the day and Reserved is true if a reservation covers it
+type TimelineDay struct {
+	Date     time.Time
+	BlockID  int
+	Reserved bool
+}
+
+// TimelineBar is a restriction drawn as a bar in the admin timeline, Column is the
+// day the bar starts at (1 is the first day shown) and Span the number of days it covers
+type TimelineBar struct {
+	Restriction BungalowRestriction
+	Column      int
+	Span        int
+	Label       string
+}
@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the limiter looks for idle buckets to evict
+const sweepInterval = time.Minute
+
+// Rule allows Requests requests Per period on average, with bursts of up to Burst requests
+type Rule struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// ParseRule returns r changed by the settings in s, a comma separated list like
+// "requests=300,per=1m,burst=100", settings left out keep their value
+func ParseRule(s string, r Rule) (Rule, error) {
+	for _, setting := range strings.Split(s, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return r, fmt.Errorf("rate limit setting %q is not name=value", setting)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		var err error
+		switch name {
+		case "requests":
+			r.Requests, err = strconv.Atoi(value)
+		case "per":
+			r.Per, err = time.ParseDuration(value)
+		case "burst":
+			r.Burst, err = strconv.Atoi(value)
+		default:
+			return r, fmt.Errorf("unknown rate limit setting %q", name)
+		}
+		if err != nil {
+			return r, fmt.Errorf("invalid value %q of rate limit setting %s", value, name)
+		}
+	}
+
+	if r.Requests < 1 || r.Per <= 0 || r.Burst < 1 {
+		return r, fmt.Errorf("rate limit needs at least one request per positive period and a burst of at least one")
+	}
+
+	return r, nil
+}
+
+// rate returns the number of tokens added to a bucket per second
+func (r Rule) rate() float64 {
+	return float64(r.Requests) / r.Per.Seconds()
+}
+
+// bucket holds the tokens left for a key at the time it was last used
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket rate limiter keeping a bucket per key in memory
+type Limiter struct {
+	rule Rule
+	now  func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New returns a limiter applying rule to every key
+func New(rule Rule) *Limiter {
+	return &Limiter{
+		rule:    rule,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket of key, if the bucket is empty it returns false
+// and how long to wait for the next token
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.evict(now)
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.rule.Burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.rule.Burst), b.tokens+now.Sub(b.last).Seconds()*l.rule.rate())
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rule.rate() * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// evict drops the buckets which have been idle long enough to be full again,
+// a new bucket for the key is full as well
+func (l *Limiter) evict(now time.Time) {
+	full := time.Duration(float64(l.rule.Burst) / l.rule.rate() * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// KeyFunc returns the key a request is limited by. There is no key func for api keys on purpose: the
+// application issues none, and a key made up by the client would let it escape the limit of its address
+type KeyFunc func(r *http.Request) string
+
+// ClientIP returns the address of the client sending a request. X-Forwarded-For is only followed
+// while the request comes from one of the trusted proxies, so clients cannot make up their address
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0 && isTrusted(ip, trusted); i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+	}
+
+	return ip
+}
+
+// FromTrustedProxy reports whether a request is sent by one of the trusted proxies
+func FromTrustedProxy(r *http.Request, trusted []*net.IPNet) bool {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return isTrusted(ip, trusted)
+}
+
+func isTrusted(ip string, trusted []*net.IPNet) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNetworks parses a list of networks in CIDR notation
+func ParseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestLimiter returns a limiter whose clock is moved on by the returned function
+func newTestLimiter(rule Rule) (*Limiter, func(time.Duration)) {
+	l := New(rule)
+	now := time.Date(2036, time.February, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l, wait := newTestLimiter(Rule{Requests: 6, Per: time.Minute, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("10.0.0.1"); !ok {
+			t.Errorf("expected request %d of the burst to be allowed, but it was not", i+1)
+		}
+	}
+
+	ok, retry := l.Allow("10.0.0.1")
+	if ok || retry != 10*time.Second {
+		t.Errorf("expected the request after the burst to wait 10s, but got %v and %s", ok, retry)
+	}
+
+	if ok, _ := l.Allow("10.0.0.2"); !ok {
+		t.Error("expected another key to have its own bucket, but it was limited")
+	}
+
+	wait(10 * time.Second)
+	if ok, _ := l.Allow("10.0.0.1"); !ok {
+		t.Error("expected a token to be added after 10s, but the request was limited")
+	}
+	if ok, _ := l.Allow("10.0.0.1"); ok {
+		t.Error("expected only one token to be added after 10s, but two requests were allowed")
+	}
+}
+
+func TestLimiter_Evict(t *testing.T) {
+	l, wait := newTestLimiter(Rule{Requests: 6, Per: time.Minute, Burst: 3})
+	l.Allow("10.0.0.1")
+	l.Allow("10.0.0.2")
+
+	wait(50 * time.Second)
+	l.Allow("10.0.0.2")
+
+	// the buckets are full again after 30s idle and looked at once a minute
+	wait(15 * time.Second)
+	l.Allow("10.0.0.3")
+
+	if _, ok := l.buckets["10.0.0.1"]; ok {
+		t.Error("expected the idle bucket to be evicted, but it was kept")
+	}
+	if len(l.buckets) != 2 {
+		t.Errorf("expected 2 buckets to be kept, but got %d", len(l.buckets))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseNetworks([]string{"127.0.0.1/32", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ipTests = []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{"direct", "203.0.113.7:4321", "", "203.0.113.7"},
+		{"untrusted-forwarded", "203.0.113.7:4321", "198.51.100.1", "203.0.113.7"},
+		{"trusted-proxy", "127.0.0.1:4321", "198.51.100.1", "198.51.100.1"},
+		{"proxy-chain", "127.0.0.1:4321", "192.0.2.9, 198.51.100.1, 10.0.0.5", "198.51.100.1"},
+		{"invalid-hop", "127.0.0.1:4321", "unknown", "127.0.0.1"},
+	}
+
+	for _, e := range ipTests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = e.remoteAddr
+		if e.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", e.forwarded)
+		}
+		if ip := ClientIP(req, trusted); ip != e.expected {
+			t.Errorf("for %s expected %s, but got %s", e.name, e.expected, ip)
+		}
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	base := Rule{Requests: 20, Per: time.Minute, Burst: 10}
+
+	var parseTests = []struct {
+		name        string
+		settings    string
+		expected    Rule
+		expectError bool
+	}{
+		{"empty", "", base, false},
+		{"changed", "requests=100, per=1h", Rule{Requests: 100, Per: time.Hour, Burst: 10}, false},
+		{"unknown", "rate=5", Rule{}, true},
+		{"no-value", "burst", Rule{}, true},
+		{"invalid-value", "per=a minute", Rule{}, true},
+		{"no-requests", "requests=0", Rule{}, true},
+		{"no-burst", "burst=0", Rule{}, true},
+	}
+
+	for _, e := range parseTests {
+		r, err := ParseRule(e.settings, base)
+		if e.expectError {
+			if err == nil {
+				t.Errorf("for %s expected an error, but got none", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("for %s expected no error, but got %s", e.name, err)
+		}
+		if r != e.expected {
+			t.Errorf("for %s expected %+v, but got %+v", e.name, e.expected, r)
+		}
+	}
+}
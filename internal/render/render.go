@@ -5,13 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
-	"log"
 	"net/http"
 	"path/filepath"
 	"time"
 
 	"github.com/amartin3659/VacationHomeRental/internal/config"
+	"github.com/amartin3659/VacationHomeRental/internal/helpers"
 	"github.com/amartin3659/VacationHomeRental/internal/models"
+	"github.com/amartin3659/VacationHomeRental/internal/spam"
 	"github.com/justinas/nosurf"
 )
 
@@ -53,9 +54,19 @@ func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateDa
 	td.Error = app.Session.PopString(r.Context(), "error")
 	td.Warning = app.Session.PopString(r.Context(), "warning")
 	td.CSRFToken = nosurf.Token(r)
+	td.CSPNonce = helpers.Nonce(r)
 	if app.Session.Exists(r.Context(), "user_id") {
 		td.IsAuthenticated = 1
 	}
+	if app.Spam != nil {
+		// the challenge question is only shown on the forms asking it
+		token, question := app.Spam.Renew(r.PostForm.Get(spam.TokenField))
+		td.SpamToken = token
+		td.SpamChallenge = make(map[string]string)
+		for _, form := range app.Spam.Challenged() {
+			td.SpamChallenge[form] = question
+		}
+	}
 	return td
 }
 
@@ -91,13 +102,13 @@ func Template(w http.ResponseWriter, r *http.Request, tmpl string, td *models.Te
 
 	err := t.Execute(buf, td)
 	if err != nil {
-		log.Println(err)
+		app.Logger.ErrorContext(r.Context(), "could not execute template", "template", tmpl, "error", err)
 	}
 
 	// render that template
 	_, err = buf.WriteTo(w)
 	if err != nil {
-		log.Println(err)
+		app.Logger.ErrorContext(r.Context(), "could not write template", "template", tmpl, "error", err)
 	}
 
 	return nil
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/amartin3659/VacationHomeRental/internal/helpers"
 	"github.com/amartin3659/VacationHomeRental/internal/models"
 )
 
@@ -18,10 +19,15 @@ func TestAddDefaultData(t *testing.T) {
 
 	session.Put(r.Context(), "success", "a success message")
 
+	r = helpers.WithNonce(r, "abc123")
+
 	result := AddDefaultData(&td, r)
 	if result.Success != "a success message" {
 		t.Error("expected a value for key success but success message not found in session")
 	}
+	if result.CSPNonce != "abc123" {
+		t.Errorf("expected the nonce of the request, but got %q", result.CSPNonce)
+	}
 }
 
 func TestTemplate(t *testing.T) {
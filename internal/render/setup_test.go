@@ -2,7 +2,7 @@ package render
 
 import (
 	"encoding/gob"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"testing"
@@ -10,6 +10,7 @@ import (
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/amartin3659/VacationHomeRental/internal/config"
+	"github.com/amartin3659/VacationHomeRental/internal/logging"
 	"github.com/amartin3659/VacationHomeRental/internal/models"
 )
 
@@ -23,11 +24,7 @@ func TestMain(m *testing.M) {
 
 	testApp.InProduction = false
 
-  infoLog := log.New(os.Stdout, "[INFO]\t", log.Ldate|log.Ltime)
-  testApp.InfoLog = infoLog
-  
-  errorLog := log.New(os.Stdout, "[ERROR]\t", log.Ldate|log.Ltime|log.Lshortfile)
-  testApp.ErrorLog = errorLog
+  testApp.Logger = logging.New(os.Stdout, slog.LevelInfo, false)
 
 	session = scs.New()
 	session.Lifetime = 24 * time.Hour
@@ -2,33 +2,38 @@ package dbrepo
 
 import (
 	"context"
+	"database/sql"
 	"errors"
-	"log"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/amartin3659/VacationHomeRental/internal/models"
+	"github.com/amartin3659/VacationHomeRental/internal/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func (m *postgresDBRepo) AllUsers() bool {
+func (m *postgresDBRepo) AllUsers(ctx context.Context) bool {
 	return true
 }
 
-func (m *postgresDBRepo) InsertReservation(res models.Reservation) (int, error) {
+func (m *postgresDBRepo) InsertReservation(ctx context.Context, res models.Reservation) (int, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	var newID int
 
 	stmt := `
     insert into reservations
-      (full_name, email, phone, start_date, end_date, bungalow_id, created_at, updated_at)
+      (full_name, email, phone, start_date, end_date, bungalow_id, created_at, updated_at, adults, children, infants)
     values
-      ($1, $2, $3, $4, $5, $6, $7, $8) returning id
+      ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) returning id
   `
 
-	err := m.DB.QueryRowContext(ctx, stmt, res.FullName, res.Email, res.Phone, res.StartDate, res.EndDate, res.BungalowID, time.Now(), time.Now()).Scan(&newID)
+	err := m.DB.QueryRowContext(ctx, stmt, res.FullName, res.Email, res.Phone, res.StartDate, res.EndDate, res.BungalowID, time.Now(), time.Now(),
+		res.Adults, res.Children, res.Infants).Scan(&newID)
 	if err != nil {
 		return 0, err
 	}
@@ -37,8 +42,8 @@ func (m *postgresDBRepo) InsertReservation(res models.Reservation) (int, error)
 }
 
 // InsertBungalowRestriction places a restriction in the database
-func (m *postgresDBRepo) InsertBungalowRestriction(r models.BungalowRestriction) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *postgresDBRepo) InsertBungalowRestriction(ctx context.Context, r models.BungalowRestriction) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	stmt := `
@@ -56,10 +61,11 @@ func (m *postgresDBRepo) InsertBungalowRestriction(r models.BungalowRestriction)
 	return nil
 }
 
-// SearchAvailabilityByDatesByBungalowID returns true if there is availability for a bungalow between date range, false if not
-func (m *postgresDBRepo) SearchAvailabilityByDatesByBungalowID(start, end time.Time, bungalowID int) (bool, error) {
+// SearchAvailabilityByDatesByBungalowID returns true if there is availability for a bungalow between date range, false if not,
+// only restrictions of types blocking availability count, holds only until they expire
+func (m *postgresDBRepo) SearchAvailabilityByDatesByBungalowID(ctx context.Context, start, end time.Time, bungalowID int) (bool, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	var numRows int
@@ -71,7 +77,9 @@ func (m *postgresDBRepo) SearchAvailabilityByDatesByBungalowID(start, end time.T
       bungalow_restrictions
     where
       bungalow_id = $1 and
-      $2 <= end_date and $3 >= start_date;
+      $2 <= end_date and $3 >= start_date and
+      (expires_at is null or expires_at > now()) and
+      restriction_id in (select id from restrictions where blocks_availability);
   `
 	row := m.DB.QueryRowContext(ctx, query, bungalowID, start, end)
 	err := row.Scan(&numRows)
@@ -86,16 +94,17 @@ func (m *postgresDBRepo) SearchAvailabilityByDatesByBungalowID(start, end time.T
 	return false, nil
 }
 
-// SearchAvailabilityByDatesForAllBungalows returns a slice of available bungalows, if any for a queried date range
-func (m *postgresDBRepo) SearchAvailabilityByDatesForAllBungalows(start, end time.Time) ([]models.Bungalow, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// SearchAvailabilityByDatesForAllBungalows returns a slice of available bungalows, if any for a queried date range,
+// which can host a number of guests (0 for any)
+func (m *postgresDBRepo) SearchAvailabilityByDatesForAllBungalows(ctx context.Context, start, end time.Time, guests int) ([]models.Bungalow, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	var bungalows []models.Bungalow
 
 	query := `
     select
-      b.id, b.bungalow_name
+      b.id, b.bungalow_name, b.max_occupancy
     from
       bungalows b
     where b.id not in
@@ -104,10 +113,13 @@ func (m *postgresDBRepo) SearchAvailabilityByDatesForAllBungalows(start, end tim
       from
         bungalow_restrictions br
       where
-        $1 <= br.end_date and $2 >= br.start_date);
+        $1 <= br.end_date and $2 >= br.start_date and
+        (br.expires_at is null or br.expires_at > now()) and
+        br.restriction_id in (select id from restrictions where blocks_availability))
+    and (b.max_occupancy = 0 or b.max_occupancy >= $3);
   `
 
-	rows, err := m.DB.QueryContext(ctx, query, start, end)
+	rows, err := m.DB.QueryContext(ctx, query, start, end, guests)
 	if err != nil {
 		return bungalows, err
 	}
@@ -118,6 +130,7 @@ func (m *postgresDBRepo) SearchAvailabilityByDatesForAllBungalows(start, end tim
 		err := rows.Scan(
 			&bungalow.ID,
 			&bungalow.BungalowName,
+			&bungalow.MaxOccupancy,
 		)
 		if err != nil {
 			return bungalows, err
@@ -134,14 +147,14 @@ func (m *postgresDBRepo) SearchAvailabilityByDatesForAllBungalows(start, end tim
 }
 
 // GetBungalowByID gets a bungalow by id
-func (m *postgresDBRepo) GetBungalowByID(id int) (models.Bungalow, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *postgresDBRepo) GetBungalowByID(ctx context.Context, id int) (models.Bungalow, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	var bungalow models.Bungalow
 
 	query := `
-    select id, bungalow_name, created_at, updated_at
+    select id, bungalow_name, max_occupancy, created_at, updated_at
     from bungalows
     where id = $1;
   `
@@ -150,6 +163,7 @@ func (m *postgresDBRepo) GetBungalowByID(id int) (models.Bungalow, error) {
 	err := row.Scan(
 		&bungalow.ID,
 		&bungalow.BungalowName,
+		&bungalow.MaxOccupancy,
 		&bungalow.CreatedAt,
 		&bungalow.UpdatedAt,
 	)
@@ -162,8 +176,8 @@ func (m *postgresDBRepo) GetBungalowByID(id int) (models.Bungalow, error) {
 }
 
 // GetUserByID returns user data by id
-func (m *postgresDBRepo) GetUserByID(id int) (models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *postgresDBRepo) GetUserByID(ctx context.Context, id int) (models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	var user models.User
@@ -191,8 +205,8 @@ func (m *postgresDBRepo) GetUserByID(id int) (models.User, error) {
 }
 
 // UpdateUser updates basic user data in the database
-func (m *postgresDBRepo) UpdateUser(u models.User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *postgresDBRepo) UpdateUser(ctx context.Context, u models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	query := `
@@ -208,8 +222,8 @@ func (m *postgresDBRepo) UpdateUser(u models.User) error {
 }
 
 // Authenticate authenticates a user by data
-func (m *postgresDBRepo) Authenticate(email, testPassword string) (int, string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *postgresDBRepo) Authenticate(ctx context.Context, email, testPassword string) (int, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	var id int
@@ -232,30 +246,101 @@ func (m *postgresDBRepo) Authenticate(email, testPassword string) (int, string,
 	return id, passwordHash, nil
 }
 
-// AllReservations builds and returns a slice of all reservations from the database
-func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
+// reservationSortColumns maps the sort columns of a reservation query to sql
+var reservationSortColumns = map[string]string{
+	"id":         "r.id",
+	"full_name":  "r.full_name",
+	"email":      "r.email",
+	"phone":      "r.phone",
+	"bungalow":   "b.bungalow_name",
+	"start_date": "r.start_date",
+	"end_date":   "r.end_date",
+	"created_at": "r.created_at",
+	"status":     "r.status",
+	"deleted_at": "r.deleted_at",
+}
+
+// reservationFilter returns the where clause and its arguments for the filters of a reservation query
+func reservationFilter(q models.ReservationQuery) (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if q.Deleted {
+		where = append(where, "r.deleted_at is not null")
+	} else {
+		where = append(where, "r.deleted_at is null")
+	}
+	if q.Search != "" {
+		// the search matches any part of the name, email or phone regardless of case
+		search := arg(strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q.Search))
+		where = append(where, "(r.full_name ilike '%' || "+search+" || '%' or r.email ilike '%' || "+search+" || '%' or "+
+			"r.phone ilike '%' || "+search+" || '%')")
+	}
+	if q.BungalowID > 0 {
+		where = append(where, "r.bungalow_id = "+arg(q.BungalowID))
+	}
+	if q.Status != models.ReservationStatusAny {
+		where = append(where, "r.status = "+arg(q.Status))
+	}
+	if !q.From.IsZero() {
+		where = append(where, "r.start_date >= "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "r.start_date <= "+arg(q.To))
+	}
+
+	return " where " + strings.Join(where, " and "), args
+}
+
+// reservationOrder returns the order by clause of a reservation query
+func reservationOrder(q models.ReservationQuery) string {
+	sort, ok := reservationSortColumns[q.Sort]
+	if !ok {
+		sort = "r.start_date"
+	}
+	order := "asc"
+	if q.Desc {
+		order = "desc"
+	}
+	return " order by " + sort + " " + order + ", r.id " + order
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// GetReservations returns a page of the reservations selected by a query
+func (m *postgresDBRepo) GetReservations(ctx context.Context, q models.ReservationQuery) (models.ReservationPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
-	var reservations []models.Reservation
+	page := models.ReservationPage{Query: q}
+
+	where, args := reservationFilter(q)
 
 	query := `
     select
       r.id, r.full_name, r.email, r.phone, r.start_date, r.end_date, r.bungalow_id, r.created_at, r.updated_at, r.status,
-      b.id, b.bungalow_name
+      r.adults, r.children, r.infants, r.deleted_at, b.id, b.bungalow_name, count(*) over()
     from reservations r
     left join bungalows b on (r.bungalow_id = b.id)
-    order by r.start_date asc
   `
-	rows, err := m.DB.QueryContext(ctx, query)
+	query += where + reservationOrder(q)
+
+	if q.PerPage > 0 {
+		args = append(args, q.PerPage, q.Offset())
+		query += " limit $" + strconv.Itoa(len(args)-1) + " offset $" + strconv.Itoa(len(args))
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return reservations, err
+		return page, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var i models.Reservation
+		var deletedAt sql.NullTime
 		err := rows.Scan(
 			&i.ID,
 			&i.FullName,
@@ -267,43 +352,49 @@ func (m *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Status,
+			&i.Adults,
+			&i.Children,
+			&i.Infants,
+			&deletedAt,
 			&i.Bungalow.ID,
 			&i.Bungalow.BungalowName,
+			&page.Total,
 		)
 
 		if err != nil {
-			return reservations, err
+			return page, err
 		}
-		reservations = append(reservations, i)
+		i.DeletedAt = deletedAt.Time
+		page.Reservations = append(page.Reservations, i)
 	}
 
 	if err = rows.Err(); err != nil {
-		return reservations, err
+		return page, err
 	}
 
-	return reservations, nil
+	return page, nil
 }
 
-// AllNewReservations builds and returns a slice of all new reservations from the database
-func (m *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
-
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// ExportReservations calls fn for every reservation selected by a query, ignoring its page, as the rows are read
+// from the database so large exports are not held in memory
+func (m *postgresDBRepo) ExportReservations(ctx context.Context, q models.ReservationQuery, fn func(models.Reservation) error) error {
+	// exports take longer than a page of reservations
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	var reservations []models.Reservation
+	where, args := reservationFilter(q)
 
 	query := `
     select
       r.id, r.full_name, r.email, r.phone, r.start_date, r.end_date, r.bungalow_id, r.created_at, r.updated_at, r.status,
-      b.id, b.bungalow_name
+      r.adults, r.children, r.infants, b.id, b.bungalow_name
     from reservations r
     left join bungalows b on (r.bungalow_id = b.id)
-    where r.status = 0
-    order by r.start_date asc
-  `
-	rows, err := m.DB.QueryContext(ctx, query)
+  ` + where + reservationOrder(q)
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return reservations, err
+		return err
 	}
 	defer rows.Close()
 
@@ -320,35 +411,222 @@ func (m *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Status,
+			&i.Adults,
+			&i.Children,
+			&i.Infants,
 			&i.Bungalow.ID,
 			&i.Bungalow.BungalowName,
 		)
+		if err != nil {
+			return err
+		}
 
+		err = fn(i)
 		if err != nil {
-			return reservations, err
+			return err
 		}
-		reservations = append(reservations, i)
 	}
 
-	if err = rows.Err(); err != nil {
-		return reservations, err
+	return rows.Err()
+}
+
+// ImportReservations inserts reservations with their restrictions and audit events in one transaction and returns their ids,
+// if the bungalow of one of them is not available nothing is imported and a *repository.ImportConflictError is returned
+func (m *postgresDBRepo) ImportReservations(ctx context.Context, reservations []models.Reservation, actor models.Actor) ([]int, error) {
+	// imports take longer than a single reservation
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	return reservations, nil
+	var ids []int
+
+	for i, res := range reservations {
+		newID, err := insertReservation(ctx, tx, res, actor)
+		if err == repository.ErrNotAvailable {
+			return nil, &repository.ImportConflictError{Index: i}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, newID)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// CreateReservation inserts a reservation made by staff with its restriction, audit event and an optional internal note
+// in one transaction and returns its id, if it is made from an inquiry the inquiry is linked to it and marked as answered
+// in the same transaction. If the bungalow is not available nothing is inserted and repository.ErrNotAvailable is returned
+func (m *postgresDBRepo) CreateReservation(ctx context.Context, res models.Reservation, note string, inquiryID int, actor models.Actor) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	newID, err := insertReservation(ctx, tx, res, actor)
+	if err != nil {
+		return 0, err
+	}
+
+	if note != "" {
+		stmt := `
+      insert into reservation_notes (reservation_id, user_id, body, created_at, updated_at)
+      values ($1, nullif($2, 0), $3, $4, $5)
+    `
+
+		_, err = tx.ExecContext(ctx, stmt, newID, actor.UserID, note, time.Now(), time.Now())
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if inquiryID > 0 {
+		stmt := `
+      update inquiries set reservation_id = $1, answered_at = coalesce(answered_at, $2), updated_at = $2
+      where id = $3
+    `
+
+		_, err = tx.ExecContext(ctx, stmt, newID, time.Now(), inquiryID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// insertReservation inserts a reservation with its restriction and audit event within a transaction and returns its id,
+// if its days are not free repository.ErrNotAvailable is returned
+func insertReservation(ctx context.Context, tx *sql.Tx, res models.Reservation, actor models.Actor) (int, error) {
+	var newID int
+	var after string
+
+	stmt := `
+    insert into reservations as r
+      (full_name, email, phone, start_date, end_date, bungalow_id, created_at, updated_at, adults, children, infants, status)
+    values
+      ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) returning r.id, to_jsonb(r)
+  `
+
+	err := tx.QueryRowContext(ctx, stmt, res.FullName, res.Email, res.Phone, res.StartDate, res.EndDate, res.BungalowID,
+		time.Now(), time.Now(), res.Adults, res.Children, res.Infants, res.Status).Scan(&newID, &after)
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditCreate, models.AuditReservation, newID, "", after)
+	if err != nil {
+		return 0, err
+	}
+
+	restrictionID, _, err := insertRestrictionIfFree(ctx, tx, models.BungalowRestriction{
+		StartDate:     res.StartDate,
+		EndDate:       res.EndDate,
+		BungalowID:    res.BungalowID,
+		ReservationID: newID,
+		RestrictionID: models.RestrictionReservation,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if restrictionID == 0 {
+		return 0, repository.ErrNotAvailable
+	}
+
+	return newID, nil
+}
+
+// insertRestrictionIfFree inserts a restriction within a transaction and returns its id and row as json. If its type
+// blocks availability it is only inserted while the days are free, otherwise 0 is returned. The bungalow is locked
+// first, so concurrent transactions for the same bungalow check and insert one after the other instead of both
+// finding the days free under read committed
+func insertRestrictionIfFree(ctx context.Context, tx *sql.Tx, r models.BungalowRestriction) (int, string, error) {
+	var locked int
+	err := tx.QueryRowContext(ctx, `select id from bungalows where id = $1 for update`, r.BungalowID).Scan(&locked)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var taken bool
+
+	query := `
+    select
+      coalesce((select blocks_availability from restrictions where id = $4), true) and
+      exists
+        (select
+          id
+        from
+          bungalow_restrictions
+        where
+          bungalow_id = $3 and
+          $1 <= end_date and $2 >= start_date and
+          (expires_at is null or expires_at > now()) and
+          restriction_id in (select id from restrictions where blocks_availability))
+  `
+
+	err = tx.QueryRowContext(ctx, query, r.StartDate, r.EndDate, r.BungalowID, r.RestrictionID).Scan(&taken)
+	if err != nil {
+		return 0, "", err
+	}
+	if taken {
+		return 0, "", nil
+	}
+
+	var id int
+	var after string
+	var expiresAt sql.NullTime
+	if !r.ExpiresAt.IsZero() {
+		expiresAt = sql.NullTime{Time: r.ExpiresAt, Valid: true}
+	}
+
+	stmt := `
+    insert into bungalow_restrictions as br
+      (start_date, end_date, bungalow_id, reservation_id, restriction_id, note, expires_at, created_at, updated_at)
+    values
+      ($1, $2, $3, nullif($4, 0), $5, $6, $7, $8, $9)
+    returning br.id, to_jsonb(br)
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, r.StartDate, r.EndDate, r.BungalowID, r.ReservationID, r.RestrictionID, r.Note,
+		expiresAt, time.Now(), time.Now()).Scan(&id, &after)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return id, after, nil
 }
 
 // GetReservationByID returns a reservation by ID
-func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error) {
+func (m *postgresDBRepo) GetReservationByID(ctx context.Context, id int) (models.Reservation, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	var res models.Reservation
+	var deletedAt sql.NullTime
 
 	query := `
     select
       r.id, r.full_name, r.email, r.phone, r.start_date, r.end_date, r.bungalow_id, r.created_at, r.updated_at, r.status,
-      b.id, b.bungalow_name
+      r.adults, r.children, r.infants, r.deleted_at, b.id, b.bungalow_name
     from reservations r
     left join bungalows b on (r.bungalow_id = b.id)
     where r.id = $1
@@ -366,6 +644,10 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 		&res.CreatedAt,
 		&res.UpdatedAt,
 		&res.Status,
+		&res.Adults,
+		&res.Children,
+		&res.Infants,
+		&deletedAt,
 		&res.Bungalow.ID,
 		&res.Bungalow.BungalowName,
 	)
@@ -374,71 +656,266 @@ func (m *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
     return res, nil
   }
 
+	res.DeletedAt = deletedAt.Time
+
 	return res, nil
 }
 
-// UpdateReservation updates the data of a reservation in the database
-func (m *postgresDBRepo) UpdateReservation(r models.Reservation) error {
+// UpdateReservation updates the data of a reservation in the database and records the change in the audit log,
+// if the stay is moved to other dates or another bungalow its restriction is moved along as long as the new days are free,
+// otherwise nothing is changed and false is returned. A reservation which does not exist or is in the trash is not changed
+// and repository.ErrNotFound is returned
+func (m *postgresDBRepo) UpdateReservation(ctx context.Context, r models.Reservation, actor models.Actor) (bool, error) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var previous models.Reservation
+	var before, after string
+
 	query := `
-    update reservations set full_name = $1, email = $2, phone = $3, updated_at = $4 where id = $5;
+    select start_date, end_date, bungalow_id, to_jsonb(r) from reservations r
+    where id = $1 and deleted_at is null
+    for update
   `
 
-	_, err := m.DB.ExecContext(ctx, query, r.FullName, r.Email, r.Phone, time.Now(), r.ID)
+	err = tx.QueryRowContext(ctx, query, r.ID).Scan(&previous.StartDate, &previous.EndDate, &previous.BungalowID, &before)
+	if err == sql.ErrNoRows {
+		return false, repository.ErrNotFound
+	}
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	moved := r.BungalowID != previous.BungalowID || !r.StartDate.Equal(previous.StartDate) || !r.EndDate.Equal(previous.EndDate)
+	if moved {
+		// the days of the reservation itself are freed first, so it can be moved to days overlapping its current stay
+		_, err = tx.ExecContext(ctx, `delete from bungalow_restrictions where reservation_id = $1`, r.ID)
+		if err != nil {
+			return false, err
+		}
+
+		restrictionID, _, err := insertRestrictionIfFree(ctx, tx, models.BungalowRestriction{
+			StartDate:     r.StartDate,
+			EndDate:       r.EndDate,
+			BungalowID:    r.BungalowID,
+			ReservationID: r.ID,
+			RestrictionID: models.RestrictionReservation,
+		})
+		if err != nil {
+			return false, err
+		}
+		if restrictionID == 0 {
+			return false, nil
+		}
+	}
+
+	query = `
+    update reservations r set full_name = $1, email = $2, phone = $3, adults = $4, children = $5, infants = $6,
+      start_date = $7, end_date = $8, bungalow_id = $9, updated_at = $10
+    where id = $11
+    returning to_jsonb(r)
+  `
+
+	err = tx.QueryRowContext(ctx, query, r.FullName, r.Email, r.Phone, r.Adults, r.Children, r.Infants,
+		r.StartDate, r.EndDate, r.BungalowID, time.Now(), r.ID).Scan(&after)
+	if err != nil {
+		return false, err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditUpdate, models.AuditReservation, r.ID, before, after)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
 }
 
-// DeleteReservation by id deletes an entry of a reservation from the database
-func (m *postgresDBRepo) DeleteReservation(id int) error {
-  
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// DeleteReservation by id moves a reservation to the trash, freeing its days, and records it in the audit log
+func (m *postgresDBRepo) DeleteReservation(ctx context.Context, id int, actor models.Actor) error {
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before, after string
+
+	err = tx.QueryRowContext(ctx, `select to_jsonb(r) from reservations r where id = $1 and deleted_at is null for update`, id).Scan(&before)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
 	query := `
-    delete from reservations where id = $1;
+    update reservations r set deleted_at = $1, updated_at = $1 where id = $2
+    returning to_jsonb(r)
   `
 
-	_, err := m.DB.ExecContext(ctx, query, id)
+	err = tx.QueryRowContext(ctx, query, time.Now(), id).Scan(&after)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// the restriction is inserted again on restore, so the days of a reservation in the trash are free
+	_, err = tx.ExecContext(ctx, `delete from bungalow_restrictions where reservation_id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditDelete, models.AuditReservation, id, before, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RestoreReservation by id takes a reservation out of the trash and records it in the audit log,
+// it returns false if the days of the reservation have been taken in the meantime
+func (m *postgresDBRepo) RestoreReservation(ctx context.Context, id int, actor models.Actor) (bool, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var res models.Reservation
+	var before, after string
+
+	query := `
+    select start_date, end_date, bungalow_id, to_jsonb(r) from reservations r
+    where id = $1 and deleted_at is not null
+    for update
+  `
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(&res.StartDate, &res.EndDate, &res.BungalowID, &before)
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	restrictionID, _, err := insertRestrictionIfFree(ctx, tx, models.BungalowRestriction{
+		StartDate:     res.StartDate,
+		EndDate:       res.EndDate,
+		BungalowID:    res.BungalowID,
+		ReservationID: id,
+		RestrictionID: models.RestrictionReservation,
+	})
+	if err != nil {
+		return false, err
+	}
+	if restrictionID == 0 {
+		return false, nil
+	}
+
+	query = `
+    update reservations r set deleted_at = null, updated_at = $1 where id = $2
+    returning to_jsonb(r)
+  `
+
+	err = tx.QueryRowContext(ctx, query, time.Now(), id).Scan(&after)
+	if err != nil {
+		return false, err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditRestore, models.AuditReservation, id, before, after)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
 }
 
-// UpdateStatusOfReservation by id updates the status of a reservation in the database
-func (m *postgresDBRepo) UpdateStatusOfReservation(id int, status int) error {
-  
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// PurgeDeletedReservations deletes the reservations moved to the trash before a time for good, records them in the
+// audit log and returns how many were deleted
+func (m *postgresDBRepo) PurgeDeletedReservations(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
 
 	query := `
-    update reservations set status = $1 where id = $2;
+    with purged as (
+      delete from reservations r where deleted_at < $1
+      returning r.id, to_jsonb(r) as snapshot
+    )
+    insert into audit_events (action, entity, entity_id, before, created_at, updated_at)
+    select $2, $3, id, snapshot, now(), now() from purged
   `
 
-	_, err := m.DB.ExecContext(ctx, query, status, id)
+	result, err := m.DB.ExecContext(ctx, query, before, models.AuditPurge, models.AuditReservation)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// UpdateStatusOfReservation by id updates the status of a reservation in the database and records the change in the audit log
+func (m *postgresDBRepo) UpdateStatusOfReservation(ctx context.Context, id int, status int, actor models.Actor) error {
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	var before, after string
+
+	err = tx.QueryRowContext(ctx, `select to_jsonb(r) from reservations r where id = $1 and deleted_at is null for update`, id).Scan(&before)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	query := `
+    update reservations r set status = $1, updated_at = $2 where id = $3
+    returning to_jsonb(r)
+  `
+
+	err = tx.QueryRowContext(ctx, query, status, time.Now(), id).Scan(&after)
+	if err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditUpdate, models.AuditReservation, id, before, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // AllBungalows returns a slice of bungalows from the database
-func (m *postgresDBRepo) AllBungalows() ([]models.Bungalow, error) {
-  ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *postgresDBRepo) AllBungalows(ctx context.Context) ([]models.Bungalow, error) {
+  ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
   defer cancel()
 
   var bungalows []models.Bungalow
 
-  query := `select id, bungalow_name, created_at, updated_at from bungalows order by id`
+  query := `select id, bungalow_name, max_occupancy, created_at, updated_at from bungalows order by id`
 
   rows, err := m.DB.QueryContext(ctx, query)
   if err != nil {
@@ -451,6 +928,7 @@ func (m *postgresDBRepo) AllBungalows() ([]models.Bungalow, error) {
     err := rows.Scan(
         &b.ID,
         &b.BungalowName,
+        &b.MaxOccupancy,
         &b.CreatedAt,
         &b.UpdatedAt,
       )
@@ -467,20 +945,27 @@ func (m *postgresDBRepo) AllBungalows() ([]models.Bungalow, error) {
   return bungalows, nil
 }
 
-// GetRestrictionsForBungalowByDate returns restrictions for a bungalow by date range
-func (m *postgresDBRepo) GetRestrictionsForBungalowByDate(bungalowID int, start, end time.Time) ([]models.BungalowRestriction, error) {
-  ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// GetRestrictionsByDate returns the restrictions of all bungalows by date range together with their type
+// and the guest and status of their reservation, holds are left out
+func (m *postgresDBRepo) GetRestrictionsByDate(ctx context.Context, start, end time.Time) ([]models.BungalowRestriction, error) {
+  ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
   defer cancel()
 
   var restrictions []models.BungalowRestriction
 
   query := `
-    select id, coalesce(reservation_id, 0), restriction_id, bungalow_id, start_date, end_date
-    from bungalow_restrictions where $1 < end_date and $2 >= start_date
-    and bungalow_id = $3;
+    select br.id, coalesce(br.reservation_id, 0), br.restriction_id, br.bungalow_id, br.start_date, br.end_date,
+    br.note, r.id, r.restriction_name, r.color, r.blocks_availability,
+    coalesce(res.full_name, ''), coalesce(res.status, 0)
+    from bungalow_restrictions br
+    join restrictions r on (r.id = br.restriction_id)
+    left join reservations res on (res.id = br.reservation_id)
+    where $1 <= br.end_date and $2 >= br.start_date
+    and br.expires_at is null
+    order by br.bungalow_id, br.start_date;
   `
 
-  rows, err := m.DB.QueryContext(ctx, query, start, end, bungalowID)
+  rows, err := m.DB.QueryContext(ctx, query, start, end)
   if err != nil {
     return nil, err
   }
@@ -495,10 +980,18 @@ func (m *postgresDBRepo) GetRestrictionsForBungalowByDate(bungalowID int, start,
         &r.BungalowID,
         &r.StartDate,
         &r.EndDate,
+        &r.Note,
+        &r.Restriction.ID,
+        &r.Restriction.RestrictionName,
+        &r.Restriction.Color,
+        &r.Restriction.BlocksAvailability,
+        &r.Reservation.FullName,
+        &r.Reservation.Status,
       )
     if err != nil {
       return nil, err
     }
+    r.Reservation.ID = r.ReservationID
 
     restrictions = append(restrictions, r)
   }
@@ -510,38 +1003,1301 @@ func (m *postgresDBRepo) GetRestrictionsForBungalowByDate(bungalowID int, start,
   return restrictions, nil
 }
 
-// InsertBlockForBungalow inserts a bungalow restriction by bungalow id for a 
-func (m *postgresDBRepo) InsertBlockForBungalow(id int, startDate time.Time) error {
-  ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// InsertBlockForBungalow inserts an owner block for a bungalow from its first to its last day, if its type
+// blocks availability it is only inserted while the days are free; it returns false if it was not inserted
+func (m *postgresDBRepo) InsertBlockForBungalow(ctx context.Context, r models.BungalowRestriction, actor models.Actor) (bool, error) {
+  ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
   defer cancel()
 
-  query := `
-    insert into bungalow_restrictions (start_date, end_date, bungalow_id, restriction_id, created_at, updated_at) values ($1, $2, $3, $4, $5, $6)
-  `
+  tx, err := m.DB.BeginTx(ctx, nil)
+  if err != nil {
+    return false, err
+  }
+  defer tx.Rollback()
+
+  id, after, err := insertRestrictionIfFree(ctx, tx, models.BungalowRestriction{
+    StartDate:     r.StartDate,
+    EndDate:       r.EndDate,
+    BungalowID:    r.BungalowID,
+    RestrictionID: r.RestrictionID,
+    Note:          r.Note,
+  })
+  if err != nil {
+    m.App.Logger.ErrorContext(ctx, "could not insert block", "bungalow_id", r.BungalowID, "error", err)
+    return false, err
+  }
+  if id == 0 {
+    return false, nil
+  }
 
-  _, err := m.DB.ExecContext(ctx, query, startDate, startDate.AddDate(0, 0, 1), id, 2, time.Now(), time.Now())
+  err = insertAuditEvent(ctx, tx, actor, models.AuditCreate, models.AuditBlock, id, "", after)
   if err != nil {
-    log.Println(err)
-    return err
+    return false, err
   }
 
-  return nil
+  if err = tx.Commit(); err != nil {
+    return false, err
+  }
+
+  return true, nil
 }
 
-// DeleteBlockByID deletes a bungalow restriction by id
-func (m *postgresDBRepo) DeleteBlockByID(id int) error {
-  ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// DeleteBlockByID deletes a whole owner block by id, reservations and holds are left untouched. Blocks are never
+// changed in place and ids are not reused, so a block that still exists is the one that was seen; it returns false
+// if the block was removed meanwhile
+func (m *postgresDBRepo) DeleteBlockByID(ctx context.Context, id int, actor models.Actor) (bool, error) {
+  ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
   defer cancel()
 
+  tx, err := m.DB.BeginTx(ctx, nil)
+  if err != nil {
+    return false, err
+  }
+  defer tx.Rollback()
+
+  var before string
+
   query := `
-    delete from bungalow_restrictions where id = $1
+    delete from bungalow_restrictions br
+    where id = $1 and reservation_id is null and expires_at is null
+    returning to_jsonb(br)
   `
 
-  _, err := m.DB.ExecContext(ctx, query, id)
+  err = tx.QueryRowContext(ctx, query, id).Scan(&before)
+  if err == sql.ErrNoRows {
+    return false, nil
+  }
   if err != nil {
-    log.Println(err)
-    return err
+    m.App.Logger.ErrorContext(ctx, "could not delete block", "id", id, "error", err)
+    return false, err
   }
 
-  return nil
+  err = insertAuditEvent(ctx, tx, actor, models.AuditDelete, models.AuditBlock, id, before, "")
+  if err != nil {
+    return false, err
+  }
+
+  if err = tx.Commit(); err != nil {
+    return false, err
+  }
+
+  return true, nil
+}
+
+// stayRuleColumns are the columns of a stay rule selected by scanStayRule
+const stayRuleColumns = `
+    s.id, coalesce(s.bungalow_id, 0), coalesce(b.bungalow_name, ''), s.start_date, s.end_date, s.min_nights, s.max_nights,
+    s.arrival_weekdays, s.min_lead_days, s.max_horizon_days, s.created_at, s.updated_at
+  `
+
+// scanStayRule scans a row of stayRuleColumns
+func scanStayRule(row interface{ Scan(...interface{}) error }) (models.StayRule, error) {
+	var r models.StayRule
+	var startDate, endDate sql.NullTime
+	var weekdays string
+
+	err := row.Scan(
+		&r.ID,
+		&r.BungalowID,
+		&r.Bungalow.BungalowName,
+		&startDate,
+		&endDate,
+		&r.MinNights,
+		&r.MaxNights,
+		&weekdays,
+		&r.MinLeadDays,
+		&r.MaxHorizonDays,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		return r, err
+	}
+
+	r.Bungalow.ID = r.BungalowID
+	r.StartDate = startDate.Time
+	r.EndDate = endDate.Time
+
+	// a rule with weekdays which cannot be read would let guests arrive on any day, so it is an error
+	r.ArrivalWeekdays, err = models.ParseWeekdays(weekdays)
+	if err != nil {
+		return r, fmt.Errorf("stay rule %d: %w", r.ID, err)
+	}
+
+	return r, nil
+}
+
+// GetStayRulesForBungalowByDate returns the stay rules of a bungalow and the global stay rules that apply to an arrival date,
+// global rules first. A stay has to keep all of them, the order only decides which broken rule is reported
+func (m *postgresDBRepo) GetStayRulesForBungalowByDate(ctx context.Context, bungalowID int, arrival time.Time) ([]models.StayRule, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var rules []models.StayRule
+
+	query := `select` + stayRuleColumns + `
+    from stay_rules s
+    left join bungalows b on (b.id = s.bungalow_id)
+    where
+      (s.bungalow_id is null or s.bungalow_id = $1) and
+      (s.start_date is null or s.start_date <= $2) and
+      (s.end_date is null or s.end_date >= $2)
+    order by s.bungalow_id nulls first, s.id
+  `
+
+	rows, err := m.DB.QueryContext(ctx, query, bungalowID, arrival)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanStayRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// AllStayRules returns all stay rules, the global ones first
+func (m *postgresDBRepo) AllStayRules(ctx context.Context) ([]models.StayRule, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var rules []models.StayRule
+
+	query := `select` + stayRuleColumns + `
+    from stay_rules s
+    left join bungalows b on (b.id = s.bungalow_id)
+    order by s.bungalow_id nulls first, s.start_date nulls first, s.id
+  `
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return rules, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanStayRule(rows)
+		if err != nil {
+			return rules, err
+		}
+		rules = append(rules, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return rules, err
+	}
+
+	return rules, nil
+}
+
+// GetStayRuleByID returns a stay rule by id
+func (m *postgresDBRepo) GetStayRuleByID(ctx context.Context, id int) (models.StayRule, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `select` + stayRuleColumns + `
+    from stay_rules s
+    left join bungalows b on (b.id = s.bungalow_id)
+    where s.id = $1
+  `
+
+	return scanStayRule(m.DB.QueryRowContext(ctx, query, id))
+}
+
+// InsertStayRule inserts a stay rule and records it in the audit log
+func (m *postgresDBRepo) InsertStayRule(ctx context.Context, r models.StayRule, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var after string
+
+	stmt := `
+    insert into stay_rules as s (bungalow_id, start_date, end_date, min_nights, max_nights, arrival_weekdays,
+      min_lead_days, max_horizon_days, created_at, updated_at)
+    values (nullif($1, 0), $2, $3, $4, $5, $6, $7, $8, $9, $10)
+    returning id, to_jsonb(s)
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, r.BungalowID,
+		sql.NullTime{Time: r.StartDate, Valid: !r.StartDate.IsZero()}, sql.NullTime{Time: r.EndDate, Valid: !r.EndDate.IsZero()},
+		r.MinNights, r.MaxNights, models.FormatWeekdays(r.ArrivalWeekdays), r.MinLeadDays, r.MaxHorizonDays,
+		time.Now(), time.Now()).Scan(&id, &after)
+	if err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditCreate, models.AuditStayRule, id, "", after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateStayRule updates a stay rule and records the change in the audit log
+func (m *postgresDBRepo) UpdateStayRule(ctx context.Context, r models.StayRule, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before, after string
+
+	err = tx.QueryRowContext(ctx, `select to_jsonb(s) from stay_rules s where id = $1 for update`, r.ID).Scan(&before)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+    update stay_rules s set bungalow_id = nullif($1, 0), start_date = $2, end_date = $3, min_nights = $4, max_nights = $5,
+      arrival_weekdays = $6, min_lead_days = $7, max_horizon_days = $8, updated_at = $9
+    where id = $10
+    returning to_jsonb(s)
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, r.BungalowID,
+		sql.NullTime{Time: r.StartDate, Valid: !r.StartDate.IsZero()}, sql.NullTime{Time: r.EndDate, Valid: !r.EndDate.IsZero()},
+		r.MinNights, r.MaxNights, models.FormatWeekdays(r.ArrivalWeekdays), r.MinLeadDays, r.MaxHorizonDays,
+		time.Now(), r.ID).Scan(&after)
+	if err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditUpdate, models.AuditStayRule, r.ID, before, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteStayRule deletes a stay rule and records it in the audit log
+func (m *postgresDBRepo) DeleteStayRule(ctx context.Context, id int, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before string
+
+	err = tx.QueryRowContext(ctx, `delete from stay_rules s where id = $1 returning to_jsonb(s)`, id).Scan(&before)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditDelete, models.AuditStayRule, id, before, "")
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InsertHold places a hold on a bungalow for a date range until r.ExpiresAt and returns its id,
+// or 0 if the bungalow is not available anymore
+func (m *postgresDBRepo) InsertHold(ctx context.Context, r models.BungalowRestriction) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	r.RestrictionID = models.RestrictionHold
+	newID, _, err := insertRestrictionIfFree(ctx, tx, r)
+	if err != nil || newID == 0 {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// ConvertHoldToReservation inserts a reservation and turns the hold on its bungalow into the reservation's restriction,
+// it returns repository.ErrHoldExpired if the hold has expired
+func (m *postgresDBRepo) ConvertHoldToReservation(ctx context.Context, holdID int, res models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var id int
+
+	query := `
+    select id from bungalow_restrictions
+    where id = $1 and restriction_id = $2 and expires_at > now()
+    for update
+  `
+
+	err = tx.QueryRowContext(ctx, query, holdID, models.RestrictionHold).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, repository.ErrHoldExpired
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var newID int
+
+	stmt := `
+    insert into reservations
+      (full_name, email, phone, start_date, end_date, bungalow_id, created_at, updated_at, adults, children, infants)
+    values
+      ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) returning id
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, res.FullName, res.Email, res.Phone, res.StartDate, res.EndDate, res.BungalowID, time.Now(), time.Now(),
+		res.Adults, res.Children, res.Infants).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `
+    update bungalow_restrictions
+    set reservation_id = $1, restriction_id = $2, expires_at = null, updated_at = $3
+    where id = $4
+  `
+
+	_, err = tx.ExecContext(ctx, stmt, newID, models.RestrictionReservation, time.Now(), holdID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// InsertReservationIfAvailable inserts a reservation made by a guest without a hold together with its restriction
+// in one transaction and returns its id, if the days are not free anymore nothing is inserted and
+// repository.ErrNotAvailable is returned
+func (m *postgresDBRepo) InsertReservationIfAvailable(ctx context.Context, res models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var newID int
+
+	stmt := `
+    insert into reservations
+      (full_name, email, phone, start_date, end_date, bungalow_id, created_at, updated_at, adults, children, infants)
+    values
+      ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) returning id
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, res.FullName, res.Email, res.Phone, res.StartDate, res.EndDate, res.BungalowID, time.Now(), time.Now(),
+		res.Adults, res.Children, res.Infants).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	restrictionID, _, err := insertRestrictionIfFree(ctx, tx, models.BungalowRestriction{
+		StartDate:     res.StartDate,
+		EndDate:       res.EndDate,
+		BungalowID:    res.BungalowID,
+		ReservationID: newID,
+		RestrictionID: models.RestrictionReservation,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if restrictionID == 0 {
+		return 0, repository.ErrNotAvailable
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// DeleteHold releases a hold by id
+func (m *postgresDBRepo) DeleteHold(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `
+    delete from bungalow_restrictions where id = $1 and restriction_id = $2
+  `
+
+	_, err := m.DB.ExecContext(ctx, query, id, models.RestrictionHold)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpiredHolds releases all expired holds and returns how many were released
+func (m *postgresDBRepo) DeleteExpiredHolds(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `
+    delete from bungalow_restrictions where restriction_id = $1 and expires_at <= now()
+  `
+
+	result, err := m.DB.ExecContext(ctx, query, models.RestrictionHold)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// AllRestrictions returns all restriction types
+func (m *postgresDBRepo) AllRestrictions(ctx context.Context) ([]models.Restriction, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var restrictions []models.Restriction
+
+	query := `
+    select id, restriction_name, color, blocks_availability, created_at, updated_at
+    from restrictions
+    order by id
+  `
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return restrictions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.Restriction
+		err := rows.Scan(
+			&r.ID,
+			&r.RestrictionName,
+			&r.Color,
+			&r.BlocksAvailability,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+		)
+		if err != nil {
+			return restrictions, err
+		}
+
+		restrictions = append(restrictions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return restrictions, err
+	}
+
+	return restrictions, nil
+}
+
+// GetRestrictionByID returns a restriction type by id
+func (m *postgresDBRepo) GetRestrictionByID(ctx context.Context, id int) (models.Restriction, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var r models.Restriction
+
+	query := `
+    select id, restriction_name, color, blocks_availability, created_at, updated_at
+    from restrictions
+    where id = $1
+  `
+
+	row := m.DB.QueryRowContext(ctx, query, id)
+	err := row.Scan(
+		&r.ID,
+		&r.RestrictionName,
+		&r.Color,
+		&r.BlocksAvailability,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// InsertRestriction inserts a custom restriction type
+func (m *postgresDBRepo) InsertRestriction(ctx context.Context, r models.Restriction, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var after string
+
+	stmt := `
+    insert into restrictions as r (restriction_name, color, blocks_availability, created_at, updated_at)
+    values ($1, $2, $3, $4, $5)
+    returning id, to_jsonb(r)
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, r.RestrictionName, r.Color, r.BlocksAvailability, time.Now(), time.Now()).Scan(&id, &after)
+	if err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditCreate, models.AuditRestriction, id, "", after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateRestriction updates a restriction type
+func (m *postgresDBRepo) UpdateRestriction(ctx context.Context, r models.Restriction, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before, after string
+
+	err = tx.QueryRowContext(ctx, `select to_jsonb(r) from restrictions r where id = $1 for update`, r.ID).Scan(&before)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+    update restrictions r set restriction_name = $1, color = $2, blocks_availability = $3, updated_at = $4
+    where id = $5
+    returning to_jsonb(r)
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, r.RestrictionName, r.Color, r.BlocksAvailability, time.Now(), r.ID).Scan(&after)
+	if err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditUpdate, models.AuditRestriction, r.ID, before, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteRestriction deletes a custom restriction type, it returns false if the type is still in use
+func (m *postgresDBRepo) DeleteRestriction(ctx context.Context, id int, actor models.Actor) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var before string
+
+	stmt := `
+    delete from restrictions r
+    where id = $1 and not exists (select id from bungalow_restrictions where restriction_id = $1)
+    returning to_jsonb(r)
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, id).Scan(&before)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditDelete, models.AuditRestriction, id, before, "")
+	if err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetOccupancyByMonth returns the nights booked per bungalow and month for the months from start until end
+func (m *postgresDBRepo) GetOccupancyByMonth(ctx context.Context, start, end time.Time) ([]models.Occupancy, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var occupancy []models.Occupancy
+
+	query := `
+    select
+      b.id, b.bungalow_name, mon.month::date,
+      coalesce(sum(
+        greatest(0, least(r.end_date, (mon.month + interval '1 month')::date) - greatest(r.start_date, mon.month::date))
+      ), 0)
+    from bungalows b
+    cross join generate_series($1::timestamp, $2::timestamp - interval '1 day', interval '1 month') as mon(month)
+    left join reservations r on (
+      r.bungalow_id = b.id and r.start_date < (mon.month + interval '1 month')::date and r.end_date > mon.month::date and
+      r.deleted_at is null
+    )
+    group by b.id, b.bungalow_name, mon.month
+    order by b.id, mon.month
+  `
+
+	rows, err := m.DB.QueryContext(ctx, query, start, end)
+	if err != nil {
+		return occupancy, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var o models.Occupancy
+		err := rows.Scan(
+			&o.Bungalow.ID,
+			&o.Bungalow.BungalowName,
+			&o.Month,
+			&o.NightsBooked,
+		)
+		if err != nil {
+			return occupancy, err
+		}
+		occupancy = append(occupancy, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return occupancy, err
+	}
+
+	return occupancy, nil
+}
+
+// GetReservationStats sums up the reservations arriving from start until the day before end
+func (m *postgresDBRepo) GetReservationStats(ctx context.Context, start, end time.Time) (models.ReservationStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var stats models.ReservationStats
+
+	query := `
+    select
+      count(*),
+      coalesce(sum(end_date - start_date), 0),
+      coalesce(avg(end_date - start_date), 0)::float8,
+      coalesce(avg(start_date - created_at::date), 0)::float8,
+      count(*) filter (where status = $3),
+      count(*) filter (where status = $4),
+      count(*) filter (where status = $5)
+    from reservations
+    where start_date >= $1 and start_date < $2 and deleted_at is null
+  `
+
+	row := m.DB.QueryRowContext(ctx, query, start, end, models.ReservationNew, models.ReservationProcessed, models.ReservationConfirmed)
+	err := row.Scan(
+		&stats.Reservations,
+		&stats.NightsBooked,
+		&stats.AverageStay,
+		&stats.AverageLeadTime,
+		&stats.New,
+		&stats.Processed,
+		&stats.Confirmed,
+	)
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// GetArrivalsAndDepartures returns the reservations arriving or departing on a day
+func (m *postgresDBRepo) GetArrivalsAndDepartures(ctx context.Context, day time.Time) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var reservations []models.Reservation
+
+	query := `
+    select
+      r.id, r.full_name, r.email, r.phone, r.start_date, r.end_date, r.bungalow_id, r.created_at, r.updated_at, r.status,
+      r.adults, r.children, r.infants, b.id, b.bungalow_name
+    from reservations r
+    left join bungalows b on (r.bungalow_id = b.id)
+    where (r.start_date = $1 or r.end_date = $1) and r.deleted_at is null
+    order by b.bungalow_name asc
+  `
+	rows, err := m.DB.QueryContext(ctx, query, day)
+	if err != nil {
+		return reservations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i models.Reservation
+		err := rows.Scan(
+			&i.ID,
+			&i.FullName,
+			&i.Email,
+			&i.Phone,
+			&i.StartDate,
+			&i.EndDate,
+			&i.BungalowID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Status,
+			&i.Adults,
+			&i.Children,
+			&i.Infants,
+			&i.Bungalow.ID,
+			&i.Bungalow.BungalowName,
+		)
+		if err != nil {
+			return reservations, err
+		}
+		reservations = append(reservations, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return reservations, err
+	}
+
+	return reservations, nil
+}
+
+// insertAuditEvent records a change in the audit log within the transaction making the change, before and after
+// are json snapshots of the entity and empty if it did not exist before or after the change
+func insertAuditEvent(ctx context.Context, tx *sql.Tx, actor models.Actor, action, entity string, entityID int, before, after string) error {
+	stmt := `
+    insert into audit_events (user_id, action, entity, entity_id, before, after, ip, created_at, updated_at)
+    values (nullif($1, 0), $2, $3, $4, nullif($5, '')::jsonb, nullif($6, '')::jsonb, $7, $8, $9)
+  `
+
+	_, err := tx.ExecContext(ctx, stmt, actor.UserID, action, entity, entityID, before, after, actor.IP, time.Now(), time.Now())
+	return err
+}
+
+// GetAuditEvents returns a page of the audit events selected by a query, newest first
+func (m *postgresDBRepo) GetAuditEvents(ctx context.Context, q models.AuditQuery) (models.AuditPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	page := models.AuditPage{Query: q}
+
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if q.UserID > 0 {
+		where = append(where, "a.user_id = "+arg(q.UserID))
+	}
+	if q.Action != "" {
+		where = append(where, "a.action = "+arg(q.Action))
+	}
+	if q.Entity != "" {
+		where = append(where, "a.entity = "+arg(q.Entity))
+	}
+	if q.EntityID > 0 {
+		where = append(where, "a.entity_id = "+arg(q.EntityID))
+	}
+	if q.ReservationID > 0 {
+		// the notes and messages of a reservation carry its id in their snapshots
+		id := arg(q.ReservationID)
+		where = append(where, "((a.entity = "+arg(models.AuditReservation)+" and a.entity_id = "+id+") or (a.entity in ("+
+			arg(models.AuditNote)+", "+arg(models.AuditMessage)+") and (coalesce(a.after, a.before)->>'reservation_id')::int = "+id+"))")
+	}
+	if !q.From.IsZero() {
+		where = append(where, "a.created_at >= "+arg(q.From))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "a.created_at < "+arg(q.To.AddDate(0, 0, 1)))
+	}
+
+	query := `
+    select
+      a.id, coalesce(a.user_id, 0), coalesce(u.full_name, ''), coalesce(u.email, ''), a.action, a.entity, a.entity_id,
+      coalesce(a.before::text, ''), coalesce(a.after::text, ''), a.ip, a.created_at, count(*) over()
+    from audit_events a
+    left join users u on (a.user_id = u.id)
+  `
+	if len(where) > 0 {
+		query += " where " + strings.Join(where, " and ")
+	}
+	query += " order by a.created_at desc, a.id desc"
+
+	if q.PerPage > 0 {
+		query += " limit " + arg(q.PerPage) + " offset " + arg(q.Offset())
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.AuditEvent
+		err := rows.Scan(
+			&e.ID,
+			&e.UserID,
+			&e.User.FullName,
+			&e.User.Email,
+			&e.Action,
+			&e.Entity,
+			&e.EntityID,
+			&e.Before,
+			&e.After,
+			&e.IP,
+			&e.CreatedAt,
+			&page.Total,
+		)
+		if err != nil {
+			return page, err
+		}
+		e.User.ID = e.UserID
+		page.Events = append(page.Events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return page, err
+	}
+
+	return page, nil
+}
+
+// GetReservationNotes returns the internal notes of a reservation with their authors, oldest first
+func (m *postgresDBRepo) GetReservationNotes(ctx context.Context, reservationID int) ([]models.ReservationNote, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var notes []models.ReservationNote
+
+	query := `
+    select n.id, n.reservation_id, coalesce(n.user_id, 0), n.body, n.created_at, n.updated_at,
+      coalesce(u.full_name, ''), coalesce(u.email, '')
+    from reservation_notes n
+    left join users u on (u.id = n.user_id)
+    where n.reservation_id = $1
+    order by n.created_at, n.id
+  `
+
+	rows, err := m.DB.QueryContext(ctx, query, reservationID)
+	if err != nil {
+		return notes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n models.ReservationNote
+		err := rows.Scan(
+			&n.ID,
+			&n.ReservationID,
+			&n.UserID,
+			&n.Body,
+			&n.CreatedAt,
+			&n.UpdatedAt,
+			&n.User.FullName,
+			&n.User.Email,
+		)
+		if err != nil {
+			return notes, err
+		}
+		n.User.ID = n.UserID
+		notes = append(notes, n)
+	}
+
+	if err = rows.Err(); err != nil {
+		return notes, err
+	}
+
+	return notes, nil
+}
+
+// InsertReservationNote inserts an internal note on a reservation and records it in the audit log
+func (m *postgresDBRepo) InsertReservationNote(ctx context.Context, n models.ReservationNote, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var newID int
+	var after string
+
+	stmt := `
+    insert into reservation_notes as rn (reservation_id, user_id, body, created_at, updated_at)
+    values ($1, nullif($2, 0), $3, $4, $5) returning rn.id, to_jsonb(rn)
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, n.ReservationID, n.UserID, n.Body, time.Now(), time.Now()).Scan(&newID, &after)
+	if err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditCreate, models.AuditNote, newID, "", after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetReservationMessages returns the emails sent to the guest of a reservation with their senders, oldest first
+func (m *postgresDBRepo) GetReservationMessages(ctx context.Context, reservationID int) ([]models.ReservationMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var messages []models.ReservationMessage
+
+	query := `
+    select rm.id, rm.reservation_id, coalesce(rm.user_id, 0), rm.to_address, rm.subject, rm.body, rm.status, rm.created_at, rm.updated_at,
+      coalesce(u.full_name, ''), coalesce(u.email, '')
+    from reservation_messages rm
+    left join users u on (u.id = rm.user_id)
+    where rm.reservation_id = $1
+    order by rm.created_at, rm.id
+  `
+
+	rows, err := m.DB.QueryContext(ctx, query, reservationID)
+	if err != nil {
+		return messages, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msg models.ReservationMessage
+		err := rows.Scan(
+			&msg.ID,
+			&msg.ReservationID,
+			&msg.UserID,
+			&msg.To,
+			&msg.Subject,
+			&msg.Body,
+			&msg.Status,
+			&msg.CreatedAt,
+			&msg.UpdatedAt,
+			&msg.User.FullName,
+			&msg.User.Email,
+		)
+		if err != nil {
+			return messages, err
+		}
+		msg.User.ID = msg.UserID
+		messages = append(messages, msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return messages, err
+	}
+
+	return messages, nil
+}
+
+// InsertReservationMessage records an email to the guest of a reservation as queued in its messages and in the audit log,
+// and returns the id of the message
+func (m *postgresDBRepo) InsertReservationMessage(ctx context.Context, msg models.ReservationMessage, actor models.Actor) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var newID int
+	var after string
+
+	stmt := `
+    insert into reservation_messages as rm (reservation_id, user_id, to_address, subject, body, status, created_at, updated_at)
+    values ($1, nullif($2, 0), $3, $4, $5, $6, $7, $8) returning rm.id, to_jsonb(rm)
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, msg.ReservationID, msg.UserID, msg.To, msg.Subject, msg.Body, models.MessageQueued,
+		time.Now(), time.Now()).Scan(&newID, &after)
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditCreate, models.AuditMessage, newID, "", after)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, tx.Commit()
+}
+
+// UpdateReservationMessageStatus sets the delivery status of a message to the guest of a reservation
+func (m *postgresDBRepo) UpdateReservationMessageStatus(ctx context.Context, id int, status int) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	stmt := `update reservation_messages set status = $1, updated_at = $2 where id = $3`
+
+	_, err := m.DB.ExecContext(ctx, stmt, status, time.Now(), id)
+	return err
+}
+
+// InsertInquiry inserts an inquiry sent with the contact form and returns its id
+func (m *postgresDBRepo) InsertInquiry(ctx context.Context, i models.Inquiry) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var newID int
+
+	stmt := `
+    insert into inquiries (full_name, email, subject, message, bungalow_id, start_date, end_date, created_at, updated_at)
+    values ($1, $2, $3, $4, nullif($5, 0), $6, $7, $8, $9) returning id
+  `
+
+	err := m.DB.QueryRowContext(ctx, stmt, i.FullName, i.Email, i.Subject, i.Message, i.BungalowID,
+		sql.NullTime{Time: i.StartDate, Valid: !i.StartDate.IsZero()}, sql.NullTime{Time: i.EndDate, Valid: !i.EndDate.IsZero()},
+		time.Now(), time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// inquiryColumns are the columns of an inquiry selected by scanInquiry
+const inquiryColumns = `
+    i.id, i.full_name, i.email, i.subject, i.message, coalesce(i.bungalow_id, 0), coalesce(b.bungalow_name, ''),
+    i.start_date, i.end_date, i.answered_at, coalesce(i.reservation_id, 0), i.created_at, i.updated_at
+  `
+
+// scanInquiry scans a row of inquiryColumns
+func scanInquiry(row interface{ Scan(...interface{}) error }) (models.Inquiry, error) {
+	var i models.Inquiry
+	var startDate, endDate, answeredAt sql.NullTime
+
+	err := row.Scan(
+		&i.ID,
+		&i.FullName,
+		&i.Email,
+		&i.Subject,
+		&i.Message,
+		&i.BungalowID,
+		&i.Bungalow.BungalowName,
+		&startDate,
+		&endDate,
+		&answeredAt,
+		&i.ReservationID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	if err != nil {
+		return i, err
+	}
+
+	i.Bungalow.ID = i.BungalowID
+	i.StartDate = startDate.Time
+	i.EndDate = endDate.Time
+	i.AnsweredAt = answeredAt.Time
+
+	return i, nil
+}
+
+// GetInquiries returns the open or the answered inquiries, newest first
+func (m *postgresDBRepo) GetInquiries(ctx context.Context, answered bool) ([]models.Inquiry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var inquiries []models.Inquiry
+
+	query := `select` + inquiryColumns + `
+    from inquiries i
+    left join bungalows b on (b.id = i.bungalow_id)
+    where (i.answered_at is not null) = $1
+    order by i.created_at desc, i.id desc
+  `
+
+	rows, err := m.DB.QueryContext(ctx, query, answered)
+	if err != nil {
+		return inquiries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		i, err := scanInquiry(rows)
+		if err != nil {
+			return inquiries, err
+		}
+		inquiries = append(inquiries, i)
+	}
+
+	if err = rows.Err(); err != nil {
+		return inquiries, err
+	}
+
+	return inquiries, nil
+}
+
+// GetInquiryByID returns an inquiry by id
+func (m *postgresDBRepo) GetInquiryByID(ctx context.Context, id int) (models.Inquiry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	query := `select` + inquiryColumns + `
+    from inquiries i
+    left join bungalows b on (b.id = i.bungalow_id)
+    where i.id = $1
+  `
+
+	return scanInquiry(m.DB.QueryRowContext(ctx, query, id))
+}
+
+// UpdateInquiryAnswered marks an inquiry as answered or opens it again and records the change in the audit log
+func (m *postgresDBRepo) UpdateInquiryAnswered(ctx context.Context, id int, answered bool, actor models.Actor) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var before, after string
+
+	err = tx.QueryRowContext(ctx, `select to_jsonb(i) from inquiries i where id = $1 for update`, id).Scan(&before)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+    update inquiries i set answered_at = case when $1 then coalesce(answered_at, $2) end, updated_at = $2
+    where id = $3
+    returning to_jsonb(i)
+  `
+
+	err = tx.QueryRowContext(ctx, stmt, answered, time.Now(), id).Scan(&after)
+	if err != nil {
+		return err
+	}
+
+	err = insertAuditEvent(ctx, tx, actor, models.AuditUpdate, models.AuditInquiry, id, before, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InsertSpamRejection counts a submission of a public form rejected as spam
+func (m *postgresDBRepo) InsertSpamRejection(ctx context.Context, form, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	stmt := `
+    insert into spam_rejections (form, reason, created_at, updated_at)
+    values ($1, $2, $3, $4)
+  `
+
+	_, err := m.DB.ExecContext(ctx, stmt, form, reason, time.Now(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetSpamRejections returns the number of submissions rejected as spam from start until the day before end,
+// by form and reason
+func (m *postgresDBRepo) GetSpamRejections(ctx context.Context, start, end time.Time) ([]models.SpamRejections, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var rejections []models.SpamRejections
+
+	query := `
+    select form, reason, count(*)
+    from spam_rejections
+    where created_at >= $1 and created_at < $2
+    group by form, reason
+    order by form, count(*) desc
+  `
+
+	rows, err := m.DB.QueryContext(ctx, query, start, end)
+	if err != nil {
+		return rejections, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.SpamRejections
+		err := rows.Scan(&r.Form, &r.Reason, &r.Count)
+		if err != nil {
+			return rejections, err
+		}
+		rejections = append(rejections, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return rejections, err
+	}
+
+	return rejections, nil
 }
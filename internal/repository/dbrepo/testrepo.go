@@ -1,18 +1,20 @@
 package dbrepo
 
 import (
+	"context"
 	"errors"
 	"log"
 	"time"
 
 	"github.com/amartin3659/VacationHomeRental/internal/models"
+	"github.com/amartin3659/VacationHomeRental/internal/repository"
 )
 
-func (m *testDBRepo) AllUsers() bool {
+func (m *testDBRepo) AllUsers(ctx context.Context) bool {
 	return true
 }
 
-func (m *testDBRepo) InsertReservation(res models.Reservation) (int, error) {
+func (m *testDBRepo) InsertReservation(ctx context.Context, res models.Reservation) (int, error) {
   if res.BungalowID == 99 {
     return 0, errors.New("some error")
   }
@@ -20,7 +22,7 @@ func (m *testDBRepo) InsertReservation(res models.Reservation) (int, error) {
 }
 
 // InsertBungalowRestriction places a restriction in the database
-func (m *testDBRepo) InsertBungalowRestriction(r models.BungalowRestriction) error {
+func (m *testDBRepo) InsertBungalowRestriction(ctx context.Context, r models.BungalowRestriction) error {
   if r.BungalowID == 999 {
     return errors.New("some error")
   }
@@ -28,7 +30,7 @@ func (m *testDBRepo) InsertBungalowRestriction(r models.BungalowRestriction) err
 }
 
 // SearchAvailabilityByDatesByBungalowID returns true if there is availability for a bungalow between date range, false if not
-func (m *testDBRepo) SearchAvailabilityByDatesByBungalowID(start, end time.Time, bungalowID int) (bool, error) {
+func (m *testDBRepo) SearchAvailabilityByDatesByBungalowID(ctx context.Context, start, end time.Time, bungalowID int) (bool, error) {
   // set up a test time
 	layout := "2006-01-02"
 	str := "2036-12-31"
@@ -58,7 +60,7 @@ func (m *testDBRepo) SearchAvailabilityByDatesByBungalowID(start, end time.Time,
 }
 
 // SearchAvailabilityByDatesForAllBungalows returns a slice of available bungalows, if any for a queried date range
-func (m *testDBRepo) SearchAvailabilityByDatesForAllBungalows(start, end time.Time) ([]models.Bungalow, error) {
+func (m *testDBRepo) SearchAvailabilityByDatesForAllBungalows(ctx context.Context, start, end time.Time, guests int) ([]models.Bungalow, error) {
 	var bungalows []models.Bungalow
   
   // set up a test time
@@ -79,9 +81,9 @@ func (m *testDBRepo) SearchAvailabilityByDatesForAllBungalows(start, end time.Ti
 		return nil, errors.New("some error")
 	}
 
-	// if the start date is after 2036-12-31, then return 0 bungalows,
+	// if the start date is after 2036-12-31 or there are more than 6 guests, then return 0 bungalows,
 	// indicating no availability;
-	if start.After(t) {
+	if start.After(t) || guests > 6 {
     bungalows = make([]models.Bungalow, 0)
 		return bungalows, nil
 	}
@@ -105,48 +107,96 @@ func (m *testDBRepo) SearchAvailabilityByDatesForAllBungalows(start, end time.Ti
 }
 
 // GetBungalowByID gets a bungalow by id
-func (m *testDBRepo) GetBungalowByID(id int) (models.Bungalow, error) {
+func (m *testDBRepo) GetBungalowByID(ctx context.Context, id int) (models.Bungalow, error) {
   var bungalow models.Bungalow
 
   if id > 3 {
     return bungalow, errors.New("an error occured")
   }
 
+  // bungalow 2 hosts at most 2 guests
+  if id == 2 {
+    bungalow.MaxOccupancy = 2
+  }
+
   return bungalow, nil
 }
 
-func (m *testDBRepo) GetUserByID(id int) (models.User, error) {
+func (m *testDBRepo) GetUserByID(ctx context.Context, id int) (models.User, error) {
   var u models.User
 
   return u, nil
 }
 
-func (m *testDBRepo) UpdateUser(u models.User) error {
+func (m *testDBRepo) UpdateUser(ctx context.Context, u models.User) error {
   return nil
 }
 
-func (m *testDBRepo) Authenticate(email, testPassword string) (int, string, error) {
+func (m *testDBRepo) Authenticate(ctx context.Context, email, testPassword string) (int, string, error) {
   if email == "validemail@test.com" {
     return 1, "", nil
   }
   return 0, "", errors.New("not a user")
 }
 
-// AllReservations builds and returns a slice of all reservations from the database
-func (m *testDBRepo) AllReservations() ([]models.Reservation, error) {
-  var reservations []models.Reservation
-
-  return reservations, nil
+// GetReservations returns a page of the reservations selected by a query
+func (m *testDBRepo) GetReservations(ctx context.Context, q models.ReservationQuery) (models.ReservationPage, error) {
+  page := models.ReservationPage{Query: q}
+  if q.Search == "fail" {
+    return page, errors.New("some error")
+  }
+  // there are 60 reservations, only the first two of the page are returned
+  page.Total = 60
+  page.Reservations = []models.Reservation{
+    {ID: q.Offset() + 1, FullName: "Peter Griffin", BungalowID: 1, Bungalow: models.Bungalow{ID: 1, BungalowName: "The Solitude Shack"}},
+    {ID: q.Offset() + 2, FullName: "Lois Griffin", BungalowID: 1, Bungalow: models.Bungalow{ID: 1, BungalowName: "The Solitude Shack"}},
+  }
+  if q.Deleted {
+    for i := range page.Reservations {
+      page.Reservations[i].DeletedAt = time.Now()
+    }
+  }
+  return page, nil
 }
 
-// AllNewReservations builds and returns a slice of all new reservations from the database
-func (m *testDBRepo) AllNewReservations() ([]models.Reservation, error) {
-  var reservations []models.Reservation
+// ExportReservations calls fn for every reservation selected by a query
+func (m *testDBRepo) ExportReservations(ctx context.Context, q models.ReservationQuery, fn func(models.Reservation) error) error {
+  if q.Search == "fail" {
+    return errors.New("some error")
+  }
+  start, _ := time.Parse("2006-01-02", "2036-02-01")
+  reservations := []models.Reservation{
+    {ID: 1, FullName: "Peter Griffin", Email: "peter@griffin.family", Phone: "0123", StartDate: start, EndDate: start.AddDate(0, 0, 3),
+      BungalowID: 1, Bungalow: models.Bungalow{ID: 1, BungalowName: "The Solitude Shack"}, Party: models.Party{Adults: 2}},
+    {ID: 2, FullName: "Glenn \"Quagmire\", Jr.", Email: "glenn@quagmire.com", StartDate: start, EndDate: start.AddDate(0, 0, 7),
+      BungalowID: 1, Bungalow: models.Bungalow{ID: 1, BungalowName: "The Solitude Shack"}, Party: models.Party{Adults: 1}, Status: 1},
+  }
+  for _, res := range reservations {
+    err := fn(res)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
 
-  return reservations, nil
+// ImportReservations inserts reservations with their restrictions
+func (m *testDBRepo) ImportReservations(ctx context.Context, reservations []models.Reservation, actor models.Actor) ([]int, error) {
+  var ids []int
+  for i, res := range reservations {
+    // reservations of guests called "taken" have been booked meanwhile, "fail" can't be written
+    if res.FullName == "taken" {
+      return nil, &repository.ImportConflictError{Index: i}
+    }
+    if res.FullName == "fail" {
+      return nil, errors.New("some error")
+    }
+    ids = append(ids, i+1)
+  }
+  return ids, nil
 }
 
-func (m *testDBRepo) GetReservationByID(id int) (models.Reservation, error) {
+func (m *testDBRepo) GetReservationByID(ctx context.Context, id int) (models.Reservation, error) {
   var res models.Reservation
   if id > 3 {
     return res, errors.New("invalid id")
@@ -154,34 +204,409 @@ func (m *testDBRepo) GetReservationByID(id int) (models.Reservation, error) {
   return res, nil
 }
 
-func (m *testDBRepo) UpdateReservation(r models.Reservation) error {
-  return nil
+func (m *testDBRepo) CreateReservation(ctx context.Context, res models.Reservation, note string, inquiryID int, actor models.Actor) (int, error) {
+  if res.FullName == "fail" || inquiryID == 99 {
+    return 0, errors.New("some error")
+  }
+  // the bungalow has been taken by someone else meanwhile
+  if res.FullName == "taken" {
+    return 0, repository.ErrNotAvailable
+  }
+  return 1, nil
+}
+
+func (m *testDBRepo) UpdateReservation(ctx context.Context, r models.Reservation, actor models.Actor) (bool, error) {
+  if r.FullName == "fail" {
+    return false, errors.New("some error")
+  }
+  // the reservation has been moved to the trash meanwhile
+  if r.FullName == "deleted" {
+    return false, repository.ErrNotFound
+  }
+  // like searching for availability, stays after 2036 are not available
+  if r.StartDate.Year() > 2036 {
+    return false, nil
+  }
+  return true, nil
 }
 
-func (m *testDBRepo) DeleteReservation(id int) error {
+func (m *testDBRepo) DeleteReservation(ctx context.Context, id int, actor models.Actor) error {
+  if id == 99 {
+    return errors.New("some error")
+  }
   return nil
 }
 
-func (m *testDBRepo) UpdateStatusOfReservation(id int, status int) error {
+func (m *testDBRepo) RestoreReservation(ctx context.Context, id int, actor models.Actor) (bool, error) {
+  if id == 99 {
+    return false, errors.New("some error")
+  }
+  // the days of reservation 2 have been taken since it was deleted
+  if id == 2 {
+    return false, nil
+  }
+  return true, nil
+}
+
+func (m *testDBRepo) PurgeDeletedReservations(ctx context.Context, before time.Time) (int64, error) {
+  return 0, nil
+}
+
+func (m *testDBRepo) UpdateStatusOfReservation(ctx context.Context, id int, status int, actor models.Actor) error {
+  if id == 99 {
+    return errors.New("some error")
+  }
   return nil
 }
 
-func (m *testDBRepo) AllBungalows() ([]models.Bungalow, error) {
+func (m *testDBRepo) AllBungalows(ctx context.Context) ([]models.Bungalow, error) {
   var bungalows []models.Bungalow
 
+  bungalows = append(bungalows, models.Bungalow{ID: 1, BungalowName: "The Solitude Shack", MaxOccupancy: 1})
+
   return bungalows, nil
 }
 
-func (m *testDBRepo) GetRestrictionsForBungalowByDate(bungalowID int, start, end time.Time) ([]models.BungalowRestriction, error) {
+func (m *testDBRepo) GetRestrictionsByDate(ctx context.Context, start, end time.Time) ([]models.BungalowRestriction, error) {
   var restrictions []models.BungalowRestriction
 
+  // a reservation over the first three days and a maintenance block on the fifth day shown
+  restrictions = append(restrictions, models.BungalowRestriction{
+    ID:            1,
+    StartDate:     start,
+    EndDate:       start.AddDate(0, 0, 2),
+    BungalowID:    1,
+    ReservationID: 1,
+    RestrictionID: models.RestrictionReservation,
+    Restriction:   models.Restriction{ID: models.RestrictionReservation, RestrictionName: "Reservation", Color: "#dc3545", BlocksAvailability: true},
+    Reservation:   models.Reservation{ID: 1, FullName: "Peter Griffin"},
+  })
+  restrictions = append(restrictions, models.BungalowRestriction{
+    ID:            2,
+    StartDate:     start.AddDate(0, 0, 4),
+    EndDate:       start.AddDate(0, 0, 4),
+    BungalowID:    1,
+    RestrictionID: models.RestrictionMaintenance,
+    Note:          "painting the walls",
+    Restriction:   models.Restriction{ID: models.RestrictionMaintenance, RestrictionName: "Maintenance", Color: "#fd7e14", BlocksAvailability: true},
+  })
+
   return restrictions, nil
 }
 
-func (m *testDBRepo) InsertBlockForBungalow(id int, startDate time.Time) error {
+func (m *testDBRepo) InsertBlockForBungalow(ctx context.Context, r models.BungalowRestriction, actor models.Actor) (bool, error) {
+  if r.BungalowID == 999 {
+    return false, errors.New("some error")
+  }
+
+  // days after 2036-12-31 are taken like in SearchAvailabilityByDatesByBungalowID,
+  // which only matters for types blocking availability
+  t, _ := time.Parse("2006-01-02", "2036-12-31")
+  restriction, err := m.GetRestrictionByID(ctx, r.RestrictionID)
+  if err != nil {
+    return false, err
+  }
+  if restriction.BlocksAvailability && r.EndDate.After(t) {
+    return false, nil
+  }
+  return true, nil
+}
+
+func (m *testDBRepo) DeleteBlockByID(ctx context.Context, id int, actor models.Actor) (bool, error) {
+  if id == 99 {
+    return false, errors.New("some error")
+  }
+
+  // block 6 has been removed meanwhile
+  if id == 6 {
+    return false, nil
+  }
+  return true, nil
+}
+
+func (m *testDBRepo) GetStayRulesForBungalowByDate(ctx context.Context, bungalowID int, arrival time.Time) ([]models.StayRule, error) {
+  var rules []models.StayRule
+
+  // bungalow 3 requires a stay of 2 to 14 nights
+  if bungalowID == 3 {
+    rules = append(rules, models.StayRule{
+      BungalowID: 3,
+      MinNights:  2,
+      MaxNights:  14,
+    })
+  }
+
+  // the stay rules of bungalow 5 cannot be read from the database
+  if bungalowID == 5 {
+    return rules, errors.New("some error")
+  }
+
+  return rules, nil
+}
+
+func (m *testDBRepo) AllStayRules(ctx context.Context) ([]models.StayRule, error) {
+  start, _ := time.Parse("2006-01-02", "2036-07-01")
+  rules := []models.StayRule{
+    {ID: 1, MaxHorizonDays: 365},
+    {ID: 2, BungalowID: 3, Bungalow: models.Bungalow{ID: 3, BungalowName: "The Family Cottage"}, StartDate: start,
+      EndDate: start.AddDate(0, 2, -1), MinNights: 7, ArrivalWeekdays: []time.Weekday{time.Saturday}},
+  }
+  return rules, nil
+}
+
+func (m *testDBRepo) GetStayRuleByID(ctx context.Context, id int) (models.StayRule, error) {
+  rules, _ := m.AllStayRules(ctx)
+  for _, r := range rules {
+    if r.ID == id {
+      return r, nil
+    }
+  }
+  return models.StayRule{}, errors.New("some error")
+}
+
+func (m *testDBRepo) InsertStayRule(ctx context.Context, r models.StayRule, actor models.Actor) error {
+  // the rules of bungalow 5 cannot be written to the database
+  if r.BungalowID == 5 {
+    return errors.New("some error")
+  }
+  return nil
+}
+
+func (m *testDBRepo) UpdateStayRule(ctx context.Context, r models.StayRule, actor models.Actor) error {
+  if r.BungalowID == 5 {
+    return errors.New("some error")
+  }
   return nil
 }
 
-func (m *testDBRepo) DeleteBlockByID(id int) error {
+func (m *testDBRepo) DeleteStayRule(ctx context.Context, id int, actor models.Actor) error {
+  if id == 99 {
+    return errors.New("some error")
+  }
   return nil
 }
+
+func (m *testDBRepo) InsertHold(ctx context.Context, r models.BungalowRestriction) (int, error) {
+  // the dates of a hold are taken or fail like in SearchAvailabilityByDatesByBungalowID
+  available, err := m.SearchAvailabilityByDatesByBungalowID(ctx, r.StartDate, r.EndDate, r.BungalowID)
+  if err != nil {
+    return 0, err
+  }
+  if !available {
+    return 0, nil
+  }
+  return 1, nil
+}
+
+func (m *testDBRepo) ConvertHoldToReservation(ctx context.Context, holdID int, res models.Reservation) (int, error) {
+  // hold 2 has expired, hold 3 cannot be converted
+  if holdID == 0 || holdID == 2 {
+    return 0, repository.ErrHoldExpired
+  }
+  if holdID == 3 {
+    return 0, errors.New("some error")
+  }
+  return 1, nil
+}
+
+func (m *testDBRepo) InsertReservationIfAvailable(ctx context.Context, res models.Reservation) (int, error) {
+  if res.BungalowID == 99 || res.BungalowID == 999 {
+    return 0, errors.New("some error")
+  }
+  // like searching for availability, stays after 2036 are not available
+  if res.StartDate.Year() > 2036 {
+    return 0, repository.ErrNotAvailable
+  }
+  return 1, nil
+}
+
+func (m *testDBRepo) DeleteHold(ctx context.Context, id int) error {
+  return nil
+}
+
+func (m *testDBRepo) DeleteExpiredHolds(ctx context.Context) (int64, error) {
+  return 0, nil
+}
+
+func (m *testDBRepo) AllRestrictions(ctx context.Context) ([]models.Restriction, error) {
+  restrictions := []models.Restriction{
+    {ID: models.RestrictionReservation, RestrictionName: "Reservation", Color: "#dc3545", BlocksAvailability: true},
+    {ID: models.RestrictionOwnerStay, RestrictionName: "Owner Stay", Color: "#ffc107", BlocksAvailability: true},
+    {ID: models.RestrictionHold, RestrictionName: "Hold", Color: "#0dcaf0", BlocksAvailability: true},
+    {ID: models.RestrictionMaintenance, RestrictionName: "Maintenance", Color: "#fd7e14", BlocksAvailability: true},
+    {ID: models.RestrictionSeasonalClosure, RestrictionName: "Seasonal Closure", Color: "#6f42c1", BlocksAvailability: true},
+    {ID: 6, RestrictionName: "Cleaning", Color: "#20c997", BlocksAvailability: false},
+  }
+  return restrictions, nil
+}
+
+func (m *testDBRepo) GetRestrictionByID(ctx context.Context, id int) (models.Restriction, error) {
+  restrictions, _ := m.AllRestrictions(ctx)
+  for _, r := range restrictions {
+    if r.ID == id {
+      return r, nil
+    }
+  }
+  return models.Restriction{}, errors.New("some error")
+}
+
+func (m *testDBRepo) InsertRestriction(ctx context.Context, r models.Restriction, actor models.Actor) error {
+  if r.RestrictionName == "fail" {
+    return errors.New("some error")
+  }
+  return nil
+}
+
+func (m *testDBRepo) UpdateRestriction(ctx context.Context, r models.Restriction, actor models.Actor) error {
+  return nil
+}
+
+func (m *testDBRepo) DeleteRestriction(ctx context.Context, id int, actor models.Actor) (bool, error) {
+  // restriction type 6 is not used by any restriction
+  return id == 6, nil
+}
+
+func (m *testDBRepo) GetOccupancyByMonth(ctx context.Context, start, end time.Time) ([]models.Occupancy, error) {
+  var occupancy []models.Occupancy
+  if start.Year() == 2038 {
+    return occupancy, errors.New("some error")
+  }
+  // bungalow 1 is booked 10 nights every month
+  for month := start; month.Before(end); month = month.AddDate(0, 1, 0) {
+    occupancy = append(occupancy, models.Occupancy{
+      Bungalow:     models.Bungalow{ID: 1, BungalowName: "The Solitude Shack"},
+      Month:        month,
+      NightsBooked: 10,
+    })
+  }
+  return occupancy, nil
+}
+
+func (m *testDBRepo) GetReservationStats(ctx context.Context, start, end time.Time) (models.ReservationStats, error) {
+  if start.Year() == 2037 {
+    return models.ReservationStats{}, errors.New("some error")
+  }
+  stats := models.ReservationStats{
+    Reservations:    4,
+    NightsBooked:    18,
+    AverageStay:     4.5,
+    AverageLeadTime: 30,
+    New:             1,
+    Processed:       2,
+    Confirmed:       1,
+  }
+  return stats, nil
+}
+
+func (m *testDBRepo) GetArrivalsAndDepartures(ctx context.Context, day time.Time) ([]models.Reservation, error) {
+  reservations := []models.Reservation{
+    {ID: 1, FullName: "Peter Griffin", StartDate: day, EndDate: day.AddDate(0, 0, 3), BungalowID: 1},
+    {ID: 2, FullName: "Lois Griffin", StartDate: day.AddDate(0, 0, -5), EndDate: day, BungalowID: 1},
+  }
+  return reservations, nil
+}
+
+func (m *testDBRepo) GetAuditEvents(ctx context.Context, q models.AuditQuery) (models.AuditPage, error) {
+  page := models.AuditPage{Query: q}
+  if q.UserID == 99 {
+    return page, errors.New("some error")
+  }
+  // a reservation was processed and a block deleted
+  page.Total = 2
+  page.Events = []models.AuditEvent{
+    {ID: 2, UserID: 1, User: models.User{ID: 1, FullName: "Admin"}, Action: models.AuditUpdate, Entity: models.AuditReservation,
+      EntityID: 1, Before: `{"id": 1, "status": 0}`, After: `{"id": 1, "status": 1}`, IP: "192.0.2.1"},
+    {ID: 1, UserID: 1, User: models.User{ID: 1, FullName: "Admin"}, Action: models.AuditDelete, Entity: models.AuditBlock,
+      EntityID: 5, Before: `{"id": 5, "note": "painting the walls"}`, IP: "192.0.2.1"},
+  }
+  return page, nil
+}
+
+func (m *testDBRepo) GetReservationNotes(ctx context.Context, reservationID int) ([]models.ReservationNote, error) {
+  created, _ := time.Parse("2006-01-02 15:04", "2036-01-10 09:30")
+  notes := []models.ReservationNote{
+    {ID: 1, ReservationID: reservationID, UserID: 1, User: models.User{ID: 1, FullName: "Admin"}, Body: "needs crib", CreatedAt: created},
+  }
+  return notes, nil
+}
+
+func (m *testDBRepo) InsertReservationNote(ctx context.Context, n models.ReservationNote, actor models.Actor) error {
+  if n.Body == "fail" {
+    return errors.New("some error")
+  }
+  return nil
+}
+
+func (m *testDBRepo) GetReservationMessages(ctx context.Context, reservationID int) ([]models.ReservationMessage, error) {
+  created, _ := time.Parse("2006-01-02 15:04", "2036-01-11 14:00")
+  messages := []models.ReservationMessage{
+    {ID: 1, ReservationID: reservationID, UserID: 1, User: models.User{ID: 1, FullName: "Admin"}, To: "peter@griffin.family",
+      Subject: "Your arrival", Body: "Dear Peter,\nthe keys are under the mat.", Status: models.MessageSent, CreatedAt: created},
+  }
+  return messages, nil
+}
+
+func (m *testDBRepo) InsertReservationMessage(ctx context.Context, msg models.ReservationMessage, actor models.Actor) (int, error) {
+  if msg.Body == "fail" {
+    return 0, errors.New("some error")
+  }
+  return 1, nil
+}
+
+func (m *testDBRepo) UpdateReservationMessageStatus(ctx context.Context, id int, status int) error {
+  if id == 99 {
+    return errors.New("some error")
+  }
+  return nil
+}
+
+func (m *testDBRepo) InsertInquiry(ctx context.Context, i models.Inquiry) (int, error) {
+  if i.FullName == "fail" {
+    return 0, errors.New("some error")
+  }
+  return 1, nil
+}
+
+func (m *testDBRepo) GetInquiries(ctx context.Context, answered bool) ([]models.Inquiry, error) {
+  var inquiries []models.Inquiry
+  start, _ := time.Parse("2006-01-02", "2036-02-01")
+  if answered {
+    inquiries = append(inquiries, models.Inquiry{ID: 2, FullName: "Lois Griffin", Email: "lois@griffin.family", Subject: "Pets",
+      Message: "Can we bring our dog?", AnsweredAt: start.AddDate(0, -1, 0)})
+    return inquiries, nil
+  }
+  inquiries = append(inquiries, models.Inquiry{ID: 1, FullName: "Peter Griffin", Email: "peter@griffin.family", Subject: "Family holiday",
+    Message: "Is the shack free in February?", BungalowID: 1, Bungalow: models.Bungalow{ID: 1, BungalowName: "The Solitude Shack"},
+    StartDate: start, EndDate: start.AddDate(0, 0, 7)})
+  return inquiries, nil
+}
+
+func (m *testDBRepo) GetInquiryByID(ctx context.Context, id int) (models.Inquiry, error) {
+  if id > 2 {
+    return models.Inquiry{}, errors.New("invalid id")
+  }
+  inquiries, _ := m.GetInquiries(ctx, id == 2)
+  return inquiries[0], nil
+}
+
+func (m *testDBRepo) UpdateInquiryAnswered(ctx context.Context, id int, answered bool, actor models.Actor) error {
+  if id == 99 {
+    return errors.New("some error")
+  }
+  return nil
+}
+
+func (m *testDBRepo) InsertSpamRejection(ctx context.Context, form, reason string) error {
+  return nil
+}
+
+func (m *testDBRepo) GetSpamRejections(ctx context.Context, start, end time.Time) ([]models.SpamRejections, error) {
+  if start.Year() == 2039 {
+    return nil, errors.New("some error")
+  }
+  rejections := []models.SpamRejections{
+    {Form: "contact", Reason: "honeypot", Count: 12},
+    {Form: "contact", Reason: "too_fast", Count: 3},
+  }
+  return rejections, nil
+}
@@ -1,30 +1,87 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/amartin3659/VacationHomeRental/internal/models"
 )
 
+// ErrHoldExpired is returned when a booking hold has expired or does not exist
+var ErrHoldExpired = errors.New("booking hold expired")
+
+// ErrNotFound is returned when a record to change does not exist or has been moved to the trash
+var ErrNotFound = errors.New("not found")
+
+// ErrNotAvailable is returned when the bungalow of a reservation is already reserved or blocked on its days
+var ErrNotAvailable = errors.New("bungalow not available")
+
+// ImportConflictError is returned when the bungalow of an imported reservation is not available anymore,
+// Index is the position of the reservation in the import
+type ImportConflictError struct {
+	Index int
+}
+
+func (e *ImportConflictError) Error() string {
+	return fmt.Sprintf("bungalow of imported reservation %d not available", e.Index)
+}
+
 type DatabaseRepo interface {
-	AllUsers() bool
-
-	InsertReservation(res models.Reservation) (int, error)
-	InsertBungalowRestriction(r models.BungalowRestriction) error
-	SearchAvailabilityByDatesByBungalowID(start, end time.Time, bungalowID int) (bool, error)
-	SearchAvailabilityByDatesForAllBungalows(start, end time.Time) ([]models.Bungalow, error)
-	GetBungalowByID(id int) (models.Bungalow, error)
-	GetUserByID(id int) (models.User, error)
-	UpdateUser(user models.User) error
-	Authenticate(email, testPassword string) (int, string, error)
-	AllReservations() ([]models.Reservation, error)
-	AllNewReservations() ([]models.Reservation, error)
-	GetReservationByID(id int) (models.Reservation, error)
-	UpdateReservation(r models.Reservation) error
-	DeleteReservation(id int) error
-	UpdateStatusOfReservation(id int, status int) error
-	AllBungalows() ([]models.Bungalow, error)
-	GetRestrictionsForBungalowByDate(bungalowID int, start, end time.Time) ([]models.BungalowRestriction, error)
-	InsertBlockForBungalow(id int, startDate time.Time) error
-	DeleteBlockByID(id int) error
+	AllUsers(ctx context.Context) bool
+
+	InsertReservation(ctx context.Context, res models.Reservation) (int, error)
+	CreateReservation(ctx context.Context, res models.Reservation, note string, inquiryID int, actor models.Actor) (int, error)
+	InsertBungalowRestriction(ctx context.Context, r models.BungalowRestriction) error
+	SearchAvailabilityByDatesByBungalowID(ctx context.Context, start, end time.Time, bungalowID int) (bool, error)
+	SearchAvailabilityByDatesForAllBungalows(ctx context.Context, start, end time.Time, guests int) ([]models.Bungalow, error)
+	GetBungalowByID(ctx context.Context, id int) (models.Bungalow, error)
+	GetUserByID(ctx context.Context, id int) (models.User, error)
+	UpdateUser(ctx context.Context, user models.User) error
+	Authenticate(ctx context.Context, email, testPassword string) (int, string, error)
+	GetReservations(ctx context.Context, q models.ReservationQuery) (models.ReservationPage, error)
+	ExportReservations(ctx context.Context, q models.ReservationQuery, fn func(models.Reservation) error) error
+	ImportReservations(ctx context.Context, reservations []models.Reservation, actor models.Actor) ([]int, error)
+	GetAuditEvents(ctx context.Context, q models.AuditQuery) (models.AuditPage, error)
+	GetReservationByID(ctx context.Context, id int) (models.Reservation, error)
+	UpdateReservation(ctx context.Context, r models.Reservation, actor models.Actor) (bool, error)
+	DeleteReservation(ctx context.Context, id int, actor models.Actor) error
+	RestoreReservation(ctx context.Context, id int, actor models.Actor) (bool, error)
+	PurgeDeletedReservations(ctx context.Context, before time.Time) (int64, error)
+	UpdateStatusOfReservation(ctx context.Context, id int, status int, actor models.Actor) error
+	AllBungalows(ctx context.Context) ([]models.Bungalow, error)
+	GetRestrictionsByDate(ctx context.Context, start, end time.Time) ([]models.BungalowRestriction, error)
+	InsertBlockForBungalow(ctx context.Context, r models.BungalowRestriction, actor models.Actor) (bool, error)
+	DeleteBlockByID(ctx context.Context, id int, actor models.Actor) (bool, error)
+	GetStayRulesForBungalowByDate(ctx context.Context, bungalowID int, arrival time.Time) ([]models.StayRule, error)
+	AllStayRules(ctx context.Context) ([]models.StayRule, error)
+	GetStayRuleByID(ctx context.Context, id int) (models.StayRule, error)
+	InsertStayRule(ctx context.Context, r models.StayRule, actor models.Actor) error
+	UpdateStayRule(ctx context.Context, r models.StayRule, actor models.Actor) error
+	DeleteStayRule(ctx context.Context, id int, actor models.Actor) error
+	InsertHold(ctx context.Context, r models.BungalowRestriction) (int, error)
+	ConvertHoldToReservation(ctx context.Context, holdID int, res models.Reservation) (int, error)
+	InsertReservationIfAvailable(ctx context.Context, res models.Reservation) (int, error)
+	DeleteHold(ctx context.Context, id int) error
+	DeleteExpiredHolds(ctx context.Context) (int64, error)
+	AllRestrictions(ctx context.Context) ([]models.Restriction, error)
+	GetRestrictionByID(ctx context.Context, id int) (models.Restriction, error)
+	InsertRestriction(ctx context.Context, r models.Restriction, actor models.Actor) error
+	UpdateRestriction(ctx context.Context, r models.Restriction, actor models.Actor) error
+	DeleteRestriction(ctx context.Context, id int, actor models.Actor) (bool, error)
+	GetOccupancyByMonth(ctx context.Context, start, end time.Time) ([]models.Occupancy, error)
+	GetReservationStats(ctx context.Context, start, end time.Time) (models.ReservationStats, error)
+	GetArrivalsAndDepartures(ctx context.Context, day time.Time) ([]models.Reservation, error)
+	GetReservationNotes(ctx context.Context, reservationID int) ([]models.ReservationNote, error)
+	InsertReservationNote(ctx context.Context, n models.ReservationNote, actor models.Actor) error
+	GetReservationMessages(ctx context.Context, reservationID int) ([]models.ReservationMessage, error)
+	InsertReservationMessage(ctx context.Context, msg models.ReservationMessage, actor models.Actor) (int, error)
+	UpdateReservationMessageStatus(ctx context.Context, id int, status int) error
+	InsertInquiry(ctx context.Context, i models.Inquiry) (int, error)
+	GetInquiries(ctx context.Context, answered bool) ([]models.Inquiry, error)
+	GetInquiryByID(ctx context.Context, id int) (models.Inquiry, error)
+	UpdateInquiryAnswered(ctx context.Context, id int, answered bool, actor models.Actor) error
+	InsertSpamRejection(ctx context.Context, form, reason string) error
+	GetSpamRejections(ctx context.Context, start, end time.Time) ([]models.SpamRejections, error)
 }
@@ -0,0 +1,276 @@
+package spam
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the names of the form fields added to protected forms
+const (
+	TokenField    = "form_token"
+	HoneypotField = "website"
+	AnswerField   = "challenge_answer"
+)
+
+// the reasons a submission is rejected for
+const (
+	ReasonHoneypot  = "honeypot"
+	ReasonToken     = "invalid_token"
+	ReasonTooFast   = "too_fast"
+	ReasonChallenge = "challenge"
+	ReasonRateLimit = "rate_limit"
+)
+
+// tokenLifetime is how long a rendered form can be submitted
+const tokenLifetime = 24 * time.Hour
+
+// Policy configures the bot defenses of a form, zero values switch a defense off
+type Policy struct {
+	// Honeypot rejects submissions filling in the hidden honeypot field
+	Honeypot bool
+	// MinFillTime rejects submissions sent faster after rendering the form than a person can fill it in
+	MinFillTime time.Duration
+	// MaxPerIP is the number of submissions a client address can send within Window
+	MaxPerIP int
+	Window   time.Duration
+	// Challenge requires the answer to a small arithmetic question
+	Challenge bool
+	// Redirect is the page a rejected submission is sent back to, without it the rejection is answered as json
+	Redirect string
+}
+
+// ParsePolicy returns p changed by the settings in s, a comma separated list like
+// "honeypot=true,min_fill_time=5s,max_per_ip=5,window=1h,challenge=false", settings left out keep their value
+func ParsePolicy(s string, p Policy) (Policy, error) {
+	for _, setting := range strings.Split(s, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return p, fmt.Errorf("spam policy setting %q is not name=value", setting)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+
+		var err error
+		switch name {
+		case "honeypot":
+			p.Honeypot, err = strconv.ParseBool(value)
+		case "min_fill_time":
+			p.MinFillTime, err = time.ParseDuration(value)
+		case "max_per_ip":
+			p.MaxPerIP, err = strconv.Atoi(value)
+		case "window":
+			p.Window, err = time.ParseDuration(value)
+		case "challenge":
+			p.Challenge, err = strconv.ParseBool(value)
+		default:
+			return p, fmt.Errorf("unknown spam policy setting %q", name)
+		}
+		if err != nil {
+			return p, fmt.Errorf("invalid value %q of spam policy setting %s", value, name)
+		}
+	}
+
+	if p.MinFillTime < 0 || p.MaxPerIP < 0 || (p.MaxPerIP > 0 && p.Window <= 0) {
+		return p, fmt.Errorf("spam policy needs a non negative fill time and a window for its rate limit")
+	}
+
+	return p, nil
+}
+
+// Guard checks the submissions of the forms it has a policy for
+type Guard struct {
+	key      []byte
+	policies map[string]Policy
+	now      func() time.Time
+
+	mu        sync.Mutex
+	hits      map[string][]time.Time
+	lastSweep time.Time
+}
+
+// New returns a guard signing its tokens with key
+func New(key []byte, policies map[string]Policy) *Guard {
+	return &Guard{
+		key:      key,
+		policies: policies,
+		now:      time.Now,
+		hits:     make(map[string][]time.Time),
+	}
+}
+
+// NewKey returns a random key to sign tokens with
+func NewKey() ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	return key, err
+}
+
+// Policy returns the policy of a form
+func (g *Guard) Policy(form string) (Policy, bool) {
+	p, ok := g.policies[form]
+	return p, ok
+}
+
+// Challenged returns the forms asking a challenge question
+func (g *Guard) Challenged() []string {
+	var forms []string
+	for form, p := range g.policies {
+		if p.Challenge {
+			forms = append(forms, form)
+		}
+	}
+	return forms
+}
+
+// Issue returns a signed token holding the time the form is rendered and the numbers of the challenge,
+// along with the challenge question
+func (g *Guard) Issue() (string, string) {
+	a, b := randomInt(10), randomInt(10)
+	payload := fmt.Sprintf("%d.%d.%d", g.now().Unix(), a, b)
+	return payload + "." + g.sign(payload), fmt.Sprintf("What is %d plus %d?", a, b)
+}
+
+// Renew returns token and its challenge question when a form is rendered again after a submission,
+// so the fill time still counts from the first rendering, or a new token if it is not valid
+func (g *Guard) Renew(token string) (string, string) {
+	_, a, b, ok := g.verify(token)
+	if !ok {
+		return g.Issue()
+	}
+	return token, fmt.Sprintf("What is %d plus %d?", a, b)
+}
+
+// Check returns the reason a submission of form from the client address ip is rejected for,
+// or an empty string if it passes
+func (g *Guard) Check(form, ip string, values url.Values) string {
+	p, ok := g.policies[form]
+	if !ok {
+		return ""
+	}
+
+	if p.MaxPerIP > 0 && !g.allow(form+" "+ip, p.MaxPerIP, p.Window) {
+		return ReasonRateLimit
+	}
+
+	if p.Honeypot && values.Get(HoneypotField) != "" {
+		return ReasonHoneypot
+	}
+
+	if p.MinFillTime == 0 && !p.Challenge {
+		return ""
+	}
+
+	issued, a, b, ok := g.verify(values.Get(TokenField))
+	if !ok {
+		return ReasonToken
+	}
+
+	if g.now().Sub(issued) < p.MinFillTime {
+		return ReasonTooFast
+	}
+
+	if p.Challenge {
+		answer, err := strconv.Atoi(strings.TrimSpace(values.Get(AnswerField)))
+		if err != nil || answer != a+b {
+			return ReasonChallenge
+		}
+	}
+
+	return ""
+}
+
+// Message returns a message for a person whose submission was rejected for reason
+func Message(reason string) string {
+	switch reason {
+	case ReasonRateLimit:
+		return "You have sent this form too often, please try again later."
+	case ReasonChallenge:
+		return "The answer to the question was wrong, please try again."
+	case ReasonToken:
+		return "The form has expired, please fill it in again."
+	default:
+		return "Your submission could not be accepted, please try again."
+	}
+}
+
+// verify returns the issue time and challenge numbers of a token signed by the guard and not expired yet
+func (g *Guard) verify(token string) (time.Time, int, int, bool) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(g.sign(token[:i]))) {
+		return time.Time{}, 0, 0, false
+	}
+
+	parts := strings.Split(token[:i], ".")
+	if len(parts) != 3 {
+		return time.Time{}, 0, 0, false
+	}
+	var n [3]int64
+	for j, part := range parts {
+		v, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return time.Time{}, 0, 0, false
+		}
+		n[j] = v
+	}
+
+	issued := time.Unix(n[0], 0)
+	if g.now().Sub(issued) > tokenLifetime {
+		return time.Time{}, 0, 0, false
+	}
+
+	return issued, int(n[1]), int(n[2]), true
+}
+
+func (g *Guard) sign(payload string) string {
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// allow records a submission for key and reports whether there are at most max submissions within window
+func (g *Guard) allow(key string, max int, window time.Duration) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+
+	// forget the clients not seen for a while, so the map does not grow forever
+	if now.Sub(g.lastSweep) > time.Hour {
+		for k, hits := range g.hits {
+			if len(hits) == 0 || now.Sub(hits[len(hits)-1]) > 24*time.Hour {
+				delete(g.hits, k)
+			}
+		}
+		g.lastSweep = now
+	}
+
+	hits := g.hits[key]
+	i := 0
+	for i < len(hits) && now.Sub(hits[i]) >= window {
+		i++
+	}
+	hits = append(hits[i:], now)
+	g.hits[key] = hits
+
+	return len(hits) <= max
+}
+
+func randomInt(max int64) int {
+	n, err := rand.Int(rand.Reader, big.NewInt(max))
+	if err != nil {
+		return 1
+	}
+	return int(n.Int64()) + 1
+}
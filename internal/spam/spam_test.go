@@ -0,0 +1,161 @@
+package spam
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var policies = map[string]Policy{
+	"contact":     {Honeypot: true, MinFillTime: 5 * time.Second, MaxPerIP: 2, Window: time.Hour, Challenge: true},
+	"reservation": {Honeypot: true},
+}
+
+// newTestGuard returns a guard whose clock is moved on by the returned function
+func newTestGuard() (*Guard, func(time.Duration)) {
+	g := New([]byte("secret"), policies)
+	now := time.Date(2036, time.February, 1, 12, 0, 0, 0, time.UTC)
+	g.now = func() time.Time { return now }
+	return g, func(d time.Duration) { now = now.Add(d) }
+}
+
+// answer returns the answer to the question issued along with a token
+func answer(question string) string {
+	var a, b int
+	fmt.Sscanf(question, "What is %d plus %d?", &a, &b)
+	return fmt.Sprint(a + b)
+}
+
+func TestGuard_Check(t *testing.T) {
+	g, wait := newTestGuard()
+	token, question := g.Issue()
+	wait(10 * time.Second)
+
+	other, _ := New([]byte("other"), policies).Issue()
+
+	var checkTests = []struct {
+		name     string
+		form     string
+		values   url.Values
+		expected string
+	}{
+		{"passes", "contact", url.Values{TokenField: {token}, AnswerField: {answer(question)}}, ""},
+		{"unprotected-form", "login", url.Values{HoneypotField: {"http://spam.example"}}, ""},
+		{"honeypot-only", "reservation", url.Values{}, ""},
+		{"honeypot", "reservation", url.Values{HoneypotField: {"http://spam.example"}}, ReasonHoneypot},
+		{"missing-token", "contact", url.Values{AnswerField: {answer(question)}}, ReasonToken},
+		{"foreign-token", "contact", url.Values{TokenField: {other}, AnswerField: {answer(question)}}, ReasonToken},
+		{"tampered-token", "contact", url.Values{TokenField: {strings.Replace(token, ".", "0.", 1)}, AnswerField: {answer(question)}}, ReasonToken},
+		{"wrong-answer", "contact", url.Values{TokenField: {token}, AnswerField: {"x"}}, ReasonChallenge},
+	}
+
+	for _, e := range checkTests {
+		// use a fresh address for every case, so the rate limit does not interfere
+		reason := g.Check(e.form, e.name, e.values)
+		if reason != e.expected {
+			t.Errorf("for %s expected reason %q, but got %q", e.name, e.expected, reason)
+		}
+	}
+}
+
+func TestGuard_CheckFillTime(t *testing.T) {
+	g, wait := newTestGuard()
+	token, question := g.Issue()
+	values := url.Values{TokenField: {token}, AnswerField: {answer(question)}}
+
+	wait(2 * time.Second)
+	if reason := g.Check("contact", "1", values); reason != ReasonTooFast {
+		t.Errorf("expected a form sent after 2 seconds to be too fast, but got %q", reason)
+	}
+
+	wait(25 * time.Hour)
+	if reason := g.Check("contact", "2", values); reason != ReasonToken {
+		t.Errorf("expected a form sent after a day to have an expired token, but got %q", reason)
+	}
+}
+
+func TestGuard_CheckRateLimit(t *testing.T) {
+	g, wait := newTestGuard()
+	token, question := g.Issue()
+	values := url.Values{TokenField: {token}, AnswerField: {answer(question)}}
+	wait(time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if reason := g.Check("contact", "10.0.0.1", values); reason != "" {
+			t.Errorf("expected submission %d to pass, but got %q", i+1, reason)
+		}
+	}
+	if reason := g.Check("contact", "10.0.0.1", values); reason != ReasonRateLimit {
+		t.Errorf("expected the third submission to be rate limited, but got %q", reason)
+	}
+	if reason := g.Check("contact", "10.0.0.2", values); reason != "" {
+		t.Errorf("expected another address not to be rate limited, but got %q", reason)
+	}
+
+	wait(time.Hour)
+	if reason := g.Check("contact", "10.0.0.1", values); reason != "" {
+		t.Errorf("expected the rate limit to end after the window, but got %q", reason)
+	}
+}
+
+func TestGuard_Challenged(t *testing.T) {
+	g, _ := newTestGuard()
+	forms := g.Challenged()
+	if len(forms) != 1 || forms[0] != "contact" {
+		t.Errorf("expected only the contact form to be challenged, but got %v", forms)
+	}
+}
+
+func TestGuard_Renew(t *testing.T) {
+	g, wait := newTestGuard()
+	token, question := g.Issue()
+	wait(time.Minute)
+
+	renewed, renewedQuestion := g.Renew(token)
+	if renewed != token || renewedQuestion != question {
+		t.Errorf("expected a valid token to be kept, but got %q asking %q", renewed, renewedQuestion)
+	}
+
+	renewed, _ = g.Renew("invalid")
+	if _, _, _, ok := g.verify(renewed); !ok {
+		t.Errorf("expected an invalid token to be replaced by a valid one, but got %q", renewed)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	base := Policy{Honeypot: true, MinFillTime: 5 * time.Second, MaxPerIP: 5, Window: time.Hour, Redirect: "/contact"}
+
+	var parseTests = []struct {
+		name        string
+		settings    string
+		expected    Policy
+		expectError bool
+	}{
+		{"empty", "", base, false},
+		{"changed", "challenge=true, max_per_ip=3,window=30m", Policy{Honeypot: true, MinFillTime: 5 * time.Second,
+			MaxPerIP: 3, Window: 30 * time.Minute, Challenge: true, Redirect: "/contact"}, false},
+		{"switched-off", "honeypot=false,min_fill_time=0s,max_per_ip=0", Policy{Window: time.Hour, Redirect: "/contact"}, false},
+		{"unknown", "captcha=true", Policy{}, true},
+		{"no-value", "challenge", Policy{}, true},
+		{"invalid-value", "window=an hour", Policy{}, true},
+		{"no-window", "window=0s", Policy{}, true},
+	}
+
+	for _, e := range parseTests {
+		p, err := ParsePolicy(e.settings, base)
+		if e.expectError {
+			if err == nil {
+				t.Errorf("for %s expected an error, but got none", e.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("for %s expected no error, but got %s", e.name, err)
+		}
+		if p != e.expected {
+			t.Errorf("for %s expected %+v, but got %+v", e.name, e.expected, p)
+		}
+	}
+}
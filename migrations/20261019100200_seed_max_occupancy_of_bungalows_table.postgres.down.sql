@@ -0,0 +1 @@
+UPDATE public.bungalows SET max_occupancy = 0;
@@ -0,0 +1,3 @@
+UPDATE public.bungalows SET max_occupancy = 1 WHERE bungalow_name = 'The Solitude Shack';
+UPDATE public.bungalows SET max_occupancy = 2 WHERE bungalow_name = 'The Couple''s Cove';
+UPDATE public.bungalows SET max_occupancy = 6 WHERE bungalow_name = 'The Family Fiesta Bungalow';
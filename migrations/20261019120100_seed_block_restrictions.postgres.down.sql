@@ -0,0 +1,3 @@
+update bungalow_restrictions set restriction_id = 2 where restriction_id in (4, 5);
+delete from restrictions where id in (4, 5);
+update restrictions set restriction_name = 'Blocked by Owner' where id = 2;
@@ -0,0 +1,5 @@
+UPDATE public.restrictions SET restriction_name = 'Owner Stay' WHERE id = 2;
+INSERT INTO public.restrictions (id,restriction_name,created_at,updated_at) VALUES
+	 (4,'Maintenance','2020-01-01 00:00:00.000','2020-01-01 00:00:00.000'),
+	 (5,'Seasonal Closure','2020-01-01 00:00:00.000','2020-01-01 00:00:00.000');
+SELECT setval(pg_get_serial_sequence('restrictions', 'id'), (SELECT max(id) FROM restrictions));
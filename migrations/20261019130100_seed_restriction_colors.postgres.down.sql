@@ -0,0 +1 @@
+update restrictions set color = '#6c757d' where id in (1, 2, 3, 4, 5);
@@ -0,0 +1,5 @@
+UPDATE public.restrictions SET color = '#dc3545' WHERE id = 1;
+UPDATE public.restrictions SET color = '#ffc107' WHERE id = 2;
+UPDATE public.restrictions SET color = '#0dcaf0' WHERE id = 3;
+UPDATE public.restrictions SET color = '#fd7e14' WHERE id = 4;
+UPDATE public.restrictions SET color = '#6f42c1' WHERE id = 5;
@@ -0,0 +1,4 @@
+ALTER TABLE public.reservations ADD COLUMN notes text NOT NULL DEFAULT '';
+UPDATE public.reservations r SET notes = n.body
+  FROM (SELECT DISTINCT ON (reservation_id) reservation_id, body FROM public.reservation_notes ORDER BY reservation_id, id) n
+  WHERE n.reservation_id = r.id;
@@ -0,0 +1,3 @@
+INSERT INTO public.reservation_notes (reservation_id,body,created_at,updated_at)
+  SELECT id, notes, created_at, created_at FROM public.reservations WHERE notes <> '';
+ALTER TABLE public.reservations DROP COLUMN notes;
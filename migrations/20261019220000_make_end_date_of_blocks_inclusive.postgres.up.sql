@@ -0,0 +1,2 @@
+UPDATE public.bungalow_restrictions SET end_date = end_date - 1
+  WHERE reservation_id IS NULL AND expires_at IS NULL AND end_date > start_date;
@@ -0,0 +1 @@
+ALTER TABLE public.stay_rules DROP CONSTRAINT stay_rules_arrival_weekdays_check;
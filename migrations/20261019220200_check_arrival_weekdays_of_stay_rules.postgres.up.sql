@@ -0,0 +1,2 @@
+ALTER TABLE public.stay_rules ADD CONSTRAINT stay_rules_arrival_weekdays_check
+  CHECK (arrival_weekdays ~ '^\s*([0-6]\s*(,\s*[0-6]\s*)*)?$') NOT VALID;